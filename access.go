@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Roles of the users of a theatre. Role is issued as the "role" attribute of the user certificate
+const (
+	roleAttribute = "role"
+	roleManager   = "manager"
+	roleCashier   = "cashier"
+	roleAdmin     = "admin"
+)
+
+// functionRoles has the roles allowed to invoke each function. Functions not listed are queries open to all the clients
+var functionRoles = map[string][]string{
+	"athd":   {roleManager},
+	"asd":    {roleManager},
+	"asm":    {roleManager},
+	"asp":    {roleManager},
+	"cep":    {roleManager},
+	"mig":    {roleAdmin},
+	"rst":    {roleManager},
+	"rcnt":   {roleManager},
+	"uthd":   {roleManager},
+	"dact":   {roleManager},
+	"ract":   {roleManager},
+	"apl":    {roleManager},
+	"acpn":   {roleManager},
+	"atax":   {roleManager},
+	"sweep":  {roleManager, roleCashier},
+	"sell":   {roleManager, roleCashier},
+	"sells":  {roleManager, roleCashier},
+	"hold":   {roleManager, roleCashier},
+	"chold":  {roleManager, roleCashier},
+	"rhold":  {roleManager, roleCashier},
+	"cancel": {roleManager, roleCashier},
+	"ctkt":   {roleManager, roleCashier},
+	"xfer":   {roleManager, roleCashier},
+	"redeem": {roleManager, roleCashier},
+	"exs":    {roleManager, roleCashier},
+	"cart":   {roleManager, roleCashier},
+}
+
+// InitRequest is the optional input of the instantiation or upgrade of the chaincode
+type InitRequest struct {
+	LegacyOwners map[string]string `json:"legacyowners"` // Organizations owning the theatres added before the access control, by theatre ID
+}
+
+// LegacyOwner is the organization allowed to bind a theatre added before the access control through "mig". Owners are
+// registered only at the instantiation or upgrade of the chaincode, which is approved by the channel
+type LegacyOwner struct {
+	ObjType   string `json:"obj"`
+	TheatreID string `json:"thid"`     // Alphanumeric
+	OwnerMSP  string `json:"ownermsp"` // MSP ID of the organization owning the theatre
+}
+
+// saveLegacyOwners registers the owners of the theatres added before the access control
+func saveLegacyOwners(stub shim.ChaincodeStubInterface, arg string) error {
+	var req InitRequest
+	err := json.Unmarshal([]byte(arg), &req)
+	if err != nil {
+		return newError(codeInvalidInput, "Invalid json provided as input")
+	}
+	thids := []string{}
+	for thid := range req.LegacyOwners {
+		thids = append(thids, thid)
+	}
+	sort.Strings(thids)
+	for _, thid := range thids {
+		owner := LegacyOwner{ObjType: "LegacyOwner", TheatreID: thid, OwnerMSP: req.LegacyOwners[thid]}
+		if !idFormat.MatchString(thid) || owner.OwnerMSP == "" {
+			return newError(codeInvalidInput, "Invalid owner %q of the theatre %q", owner.OwnerMSP, thid)
+		}
+		key, err := stateKey(stub, "LegacyOwner", thid)
+		if err != nil {
+			return err
+		}
+		ownerjson, _ := json.Marshal(owner)
+		err = putState(stub, key, ownerjson)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLegacyOwner checks if the client can bind the theatre added before the access control to the owner. Owner must
+// be the organization of the client and must be registered as the owner of the theatre
+func checkLegacyOwner(stub shim.ChaincodeStubInterface, thid string, ownerMSP string, mspID string) error {
+	if ownerMSP == "" {
+		return newError(codeInvalidInput, "Owner of the theatre %s is required to migrate its keys", thid)
+	}
+	if ownerMSP != mspID {
+		return newError(codeUnauthorized, "Theatre %s can be bound only to the organization of the client", thid)
+	}
+	ownerDetails, err := getRecord(stub, "LegacyOwner", thid)
+	if err != nil {
+		return fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	owner := LegacyOwner{}
+	if ownerDetails != nil {
+		err = json.Unmarshal(ownerDetails, &owner)
+		if err != nil {
+			return fmt.Errorf("Existing legacy owner Unmarshalling error")
+		}
+	}
+	if owner.OwnerMSP != ownerMSP {
+		return newError(codeUnauthorized, "Organization %s is not registered as the owner of the theatre %s", ownerMSP, thid)
+	}
+	return nil
+}
+
+// theatreRef is the theatre ID provided in the input of every function
+type theatreRef struct {
+	TheatreID string `json:"thid"`
+}
+
+// authorize checks if the client can invoke the function. The user must have one of the roles allowed for the
+// function and must belong to the organization owning the theatre. Theatres not bound to an organization can only be
+// migrated, which binds the theatre to its registered owner
+func authorize(stub shim.ChaincodeStubInterface, fn string, args []string) error {
+	roles, found := functionRoles[fn]
+	if !found {
+		return nil
+	}
+
+	role, found, err := cid.GetAttributeValue(stub, roleAttribute)
+	if err != nil {
+		return newError(codeUnauthorized, "Unable to get the role of the client :%s", err.Error())
+	}
+	if !found || !contains(roles, role) {
+		return newError(codeUnauthorized, "Client with role %s is not allowed to invoke %s", role, fn)
+	}
+
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return newError(codeUnauthorized, "Unable to get the organization of the client :%s", err.Error())
+	}
+
+	// Invalid input is rejected by the function
+	var ref theatreRef
+	if len(args) != 1 || json.Unmarshal([]byte(args[0]), &ref) != nil {
+		return nil
+	}
+	td, err := getTheatreDetails(stub, ref.TheatreID)
+	if err != nil {
+		return err
+	}
+
+	// Theatres added before the access control was introduced are not bound to an organization until migrated
+	if td != nil && td.OwnerMSP == "" && fn != "mig" {
+		return newError(codeUnauthorized, "Theatre %s is not bound to an organization. Keys of the theatre must be migrated first", ref.TheatreID)
+	}
+	if td != nil && td.OwnerMSP != "" && td.OwnerMSP != mspID {
+		return newError(codeUnauthorized, "Theatre %s is not owned by the organization %s", ref.TheatreID, mspID)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestAuthorize(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+
+	runCases(t, s, []invokeCase{
+		{name: "theatre by a cashier", fn: "athd", arg: `{"thid":"T2","maxsoda":200,"sph":{"SC1":4}}`, code: codeUnauthorized, role: roleCashier},
+		{name: "theatre by an admin", fn: "athd", arg: `{"thid":"T2","maxsoda":200,"sph":{"SC1":4}}`, code: codeUnauthorized, role: roleAdmin},
+		{name: "tickets by a cashier", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, role: roleCashier},
+		{name: "tickets by a guest", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, code: codeUnauthorized, role: "guest"},
+		{name: "theatre queried by a guest", fn: "gth", arg: `{"thid":"T1"}`, role: "guest"},
+		{name: "tickets by a cashier of another organization", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, code: codeUnauthorized, role: roleCashier, msp: "Org2MSP"},
+		{name: "theatre updated by another organization", fn: "uthd", arg: `{"thid":"T1","maxsoda":100}`, code: codeUnauthorized, msp: "Org2MSP"},
+		{name: "theatre of another organization", fn: "athd", arg: `{"thid":"T2","maxsoda":200,"sph":{"SC1":4}}`, msp: "Org2MSP"},
+		{name: "shows of the theatre of another organization", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T2","showcode":["1"]}`, code: codeUnauthorized},
+		{name: "keys migrated by a manager", fn: "mig", arg: `{"thid":"T1"}`, code: codeUnauthorized},
+		{name: "keys migrated by an admin of another organization", fn: "mig", arg: `{"thid":"T1"}`, code: codeUnauthorized, role: roleAdmin, msp: "Org2MSP"},
+		{name: "keys migrated by an admin", fn: "mig", arg: `{"thid":"T1"}`, role: roleAdmin},
+	})
+
+	td := TheatreDetails{}
+	s.record(&td, "TheatreDetails", "T2")
+	if td.OwnerMSP != "Org2MSP" {
+		t.Errorf("Theatre T2 is owned by %q, expected Org2MSP", td.OwnerMSP)
+	}
+}
+
+func TestTheatreWithoutOwner(t *testing.T) {
+	s := newTestStub(t)
+	key, _ := s.CreateCompositeKey("TheatreDetails", []string{"T3"})
+	s.putLegacy(key, `{"obj":"TheatreDetails","thid":"T3","maxsoda":200,"sph":{"SC1":4}}`)
+
+	inits := []struct {
+		name string
+		arg  string
+		code string
+	}{
+		{"invalid json", `{"legacyowners":`, codeInvalidInput},
+		{"invalid theatre ID", `{"legacyowners":{"T 3":"Org1MSP"}}`, codeInvalidInput},
+		{"owner missing", `{"legacyowners":{"T3":""}}`, codeInvalidInput},
+		{"owners", `{"legacyowners":{"T3":"Org1MSP"}}`, ""},
+	}
+	for _, in := range inits {
+		if got := responseCode(s.init(in.arg)); got != in.code {
+			t.Errorf("%s: init got code %q, expected %q", in.name, got, in.code)
+		}
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "shows of a theatre without an owner", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T3","showcode":["1"]}`, code: codeUnauthorized},
+		{name: "theatre without an owner queried", fn: "gth", arg: `{"thid":"T3"}`},
+		{name: "owner taken by another organization", fn: "mig", arg: `{"thid":"T3","ownermsp":"Org2MSP"}`, code: codeUnauthorized, role: roleAdmin, msp: "Org2MSP"},
+		{name: "owner bound by another organization", fn: "mig", arg: `{"thid":"T3","ownermsp":"Org1MSP"}`, code: codeUnauthorized, role: roleAdmin, msp: "Org2MSP"},
+		{name: "owner not named", fn: "mig", arg: `{"thid":"T3"}`, code: codeInvalidInput, role: roleAdmin},
+		{name: "owner bound by an admin of the owner", fn: "mig", arg: `{"thid":"T3","ownermsp":"Org1MSP"}`, role: roleAdmin},
+		{name: "shows by another organization", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T3","showcode":["1"]}`, code: codeUnauthorized, msp: "Org2MSP"},
+		{name: "shows by the owner", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T3","showcode":["1"]}`},
+	})
+
+	td := TheatreDetails{}
+	s.record(&td, "TheatreDetails", "T3")
+	if td.OwnerMSP != testMSP {
+		t.Errorf("Theatre T3 is owned by %q, expected %s", td.OwnerMSP, testMSP)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Cart is the input to buy the tickets of one or more shows of a theatre along with the concessions in a single sale
+type Cart struct {
+	TheatreID   string           `json:"thid"`        // Alphanumeric
+	Lines       []CartLine       `json:"lines"`       // Tickets of the shows
+	Concessions []CartConcession `json:"concessions"` // Concession items bought along with the tickets
+	Customer    string           `json:"customer"`    //
+	Coupon      string           `json:"coupon"`      // Coupon of the theatre. Must be valid for every line. Used once for the cart
+}
+
+// CartLine is the tickets of a show in the cart. Either the ticket count or the seats must be provided
+type CartLine struct {
+	Screen   string   `json:"screen"`     // Alphanumeric
+	ShowDate string   `json:"showdate"`   // YYYY-MM-DD. Defaults to the current date of the theatre
+	ShowCode string   `json:"showcode"`   //
+	Count    uint32   `json:"ticketsold"` // Tickets without seats
+	Seats    []string `json:"seats"`      // Seats (ex: ["A1", "A2"])
+	Price    uint32   `json:"price"`      // Price per ticket. Must match the price list of the screen, if added
+}
+
+// CartConcession is a concession item in the cart. Items are priced by the concession prices of the theatre
+type CartConcession struct {
+	Item     string `json:"item"` // ex: "popcorn"
+	Quantity uint32 `json:"qty"`  // Min 1
+}
+
+// cartShow is the sale of a show in the cart. Lines of the same show are added to a single sale of the show, as the
+// state saved in a transaction can not be read back in the same transaction
+type cartShow struct {
+	tkt       Tickets
+	seats     ShowSeats
+	heldSeats map[string]bool
+	inUse     int  // Tickets sold and held for the show, along with the tickets of the earlier lines of the cart
+	booked    bool // Seats of the show are booked by the cart
+}
+
+// cartLine is a line of the cart accepted for the sale
+type cartLine struct {
+	show   *cartShow
+	quote  *SaleQuote
+	issued int // Tickets of the earlier lines of the cart
+}
+
+// addCartLine validates a line of the cart and adds it to the sale of its show. Returns the coupon of the cart, if
+// any, applied to the tickets of the line
+func addCartLine(stub shim.ChaincodeStubInterface, td TheatreDetails, shows map[string]*cartShow, order *[]*cartShow, line CartLine, sale TicketSale, now int64, issued int) (*cartLine, *Coupon, *CouponUse, error) {
+	thid := td.TheatreID
+	sc := line.Screen
+	st := line.ShowCode
+
+	if td.SeatsPerHall[sc] == 0 {
+		return nil, nil, nil, newError(codeNotFound, "Screen %s does not exists for the theatre", sc)
+	}
+	err := td.salesOpen(sc)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if (line.Count == 0) == (len(line.Seats) == 0) {
+		return nil, nil, nil, newError(codeInvalidInput, "Expected either ticket count or seats")
+	}
+	dt, err := showDateOrToday(stub, td, line.ShowDate)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	quote, err := quoteSale(stub, thid, sc, dt, st, int(line.Count), line.Seats, line.Price)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	coupon, use, err := applyCoupon(stub, thid, sc, dt, quote, sale)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key := sc + "/" + dt + "/" + st
+	show, found := shows[key]
+	if !found {
+		tkt, err := getTickets(stub, thid, sc, dt, st)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if tkt == nil {
+			tkt = &Tickets{TheatreID: thid, MovieName: quote.MovieName, Screen: sc, ShowDate: dt, ShowCode: st}
+		}
+		tkt.ObjType = "Tickets"
+		holds, err := getShowHolds(stub, thid, sc, dt, st)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		showSeats, err := getShowSeats(stub, thid, sc, dt, st)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		show = &cartShow{tkt: *tkt, seats: showSeats, heldSeats: holds.liveSeats(now), inUse: int(tkt.TicketsSold) + holds.liveCount(now)}
+		shows[key] = show
+		*order = append(*order, show)
+	}
+
+	count := len(quote.Tickets)
+	if !withinCapacity(td.SeatsPerHall[sc], show.inUse, count) {
+		return nil, nil, nil, newError(codeCapacityExceeded, "Enough tickets not available for the showcode %s on %s", st, dt)
+	}
+	picked := map[string]bool{}
+	for _, seat := range line.Seats {
+		_, booked := show.seats.Booked[seat]
+		if booked || show.heldSeats[seat] || picked[seat] {
+			return nil, nil, nil, newError(codeConflict, "Seat %s is not available for the showcode %s on %s", seat, st, dt)
+		}
+		picked[seat] = true
+	}
+
+	err = show.tkt.addSold(uint32(count))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	show.inUse += count
+	for n, priced := range quote.Tickets {
+		if priced.Seat != "" {
+			show.seats.Booked[priced.Seat] = ticketID(stub.GetTxID(), issued+n+1)
+			show.booked = true
+		}
+	}
+	return &cartLine{show: show, quote: quote, issued: issued}, coupon, use, nil
+}
+
+// Buy the tickets of one or more shows of a theatre along with the concessions. Cart is sold in a single sale with a
+// single invoice, or rejected as a whole with the reasons of all the lines that can not be sold
+func (s *ShowsManagement) checkout(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("checkout", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var cart Cart
+	err := json.Unmarshal([]byte(args[0]), &cart)
+	if err != nil {
+		return errorResponse("checkout", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+	if len(cart.Lines) == 0 && len(cart.Concessions) == 0 {
+		return errorResponse("checkout", codeInvalidInput, cart.TheatreID, "Invalid request to checkout. Expected 1 or more lines or concessions")
+	}
+
+	thid := cart.TheatreID
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err == nil && td.Inactive {
+		err = td.salesOpen("")
+	}
+	if err != nil {
+		return failedResponse("checkout", thid, err)
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("checkout:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("checkout", errorCode(err), thid, "Unable to checkout the cart")
+	}
+
+	// Every line is validated so that all the reasons are returned when the cart is rejected
+	sale := TicketSale{Customer: cart.Customer, Coupon: cart.Coupon}
+	shows := map[string]*cartShow{}
+	var order []*cartShow
+	var lines []*cartLine
+	var rejected []Violation
+	var coupon *Coupon
+	var use *CouponUse
+	issued := 0
+	for i, line := range cart.Lines {
+		cl, lineCoupon, lineUse, err := addCartLine(stub, *td, shows, &order, line, sale, now, issued)
+		if err != nil {
+			if errorCode(err) == codeLedgerError {
+				return failedResponse("checkout", thid, err)
+			}
+			rejected = append(rejected, Violation{Field: "lines[" + strconv.Itoa(i) + "]", Reason: err.Error(), Code: errorCode(err)})
+			continue
+		}
+		coupon, use = lineCoupon, lineUse
+		issued += len(cl.quote.Tickets)
+		lines = append(lines, cl)
+	}
+
+	var invoiceLines []InvoiceLine
+	var concessionLines []InvoiceLine
+	for i, item := range cart.Concessions {
+		price, found := td.Concessions[item.Item]
+		if !found || item.Quantity == 0 {
+			rejected = append(rejected, Violation{Field: "concessions[" + strconv.Itoa(i) + "]", Reason: "Concession item " + item.Item + " is not sold by the theatre or the quantity is 0", Code: codeNotFound})
+			continue
+		}
+		concessionLines = append(concessionLines, InvoiceLine{Kind: lineConcession, Ref: item.Item, Quantity: item.Quantity, Amount: uint64(price) * uint64(item.Quantity)})
+	}
+
+	if len(rejected) > 0 {
+		return failedResponse("checkout", thid, &codedError{code: codeCartRejected, message: "Cart can not be sold", violations: rejected})
+	}
+
+	// Shows are saved once with all the lines of the show
+	for _, show := range order {
+		tktjson, _ := json.Marshal(show.tkt)
+		err = putRecord(stub, *td, tktjson, "Tickets", thid, show.tkt.Screen, show.tkt.ShowDate, show.tkt.ShowCode)
+		if err == nil && show.booked {
+			err = putShowSeats(stub, *td, show.seats)
+		}
+		if err != nil {
+			_logger.Errorf("checkout:PutState is Failed :" + string(err.Error()))
+			return errorResponse("checkout", errorCode(err), thid, "Unable to checkout the cart")
+		}
+	}
+
+	sold := []map[string]interface{}{}
+	for _, cl := range lines {
+		tickets, err := issueTickets(stub, *td, cl.show.tkt, *cl.quote, cart.Customer, cl.issued)
+		if err != nil {
+			_logger.Errorf("checkout:PutState is Failed :" + string(err.Error()))
+			return errorResponse("checkout", errorCode(err), thid, "Unable to issue the tickets")
+		}
+		invoiceLines = append(invoiceLines, ticketLines(*cl.quote, tickets)...)
+		sold = append(sold, map[string]interface{}{
+			"screen":   cl.show.tkt.Screen,
+			"showdate": cl.show.tkt.ShowDate,
+			"showcode": cl.show.tkt.ShowCode,
+			"tickets":  tickets,
+			"amount":   cl.quote.Total,
+		})
+	}
+
+	err = useCoupon(stub, *td, coupon, use)
+	var invoice *Invoice
+	if err == nil {
+		invoice, err = issueInvoice(stub, *td, cart.Customer, append(invoiceLines, concessionLines...))
+	}
+	if err != nil {
+		_logger.Errorf("checkout:PutState is Failed :" + string(err.Error()))
+		return errorResponse("checkout", errorCode(err), thid, "Unable to checkout the cart")
+	}
+	_logger.Infof("checkout:Cart sold successfully")
+
+	result := map[string]interface{}{
+		"trxnid":    stub.GetTxID(),
+		"lines":     sold,
+		"invoiceno": invoice.InvoiceNo,
+		"subtotal":  invoice.Subtotal,
+		"tax":       invoice.Tax,
+		"total":     invoice.Total,
+		"message":   "Checkout successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
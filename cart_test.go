@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// addCartTheatre adds the theatre T1 with the screens SC1 and SC2 of 4 seats, a seat map of SC1, shows "1" and "2" of
+// Lucy on SC1 and show "1" of Tenet on SC2, coffee and tea, and the taxes
+func (s *testStub) addCartTheatre() {
+	s.t.Helper()
+	s.mustInvoke("athd", `{"thid":"T1","maxsoda":200,"sph":{"SC1":4,"SC2":4},"concessions":{"coffee":500,"tea":200}}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showcode":["1","2"]}`)
+	s.mustInvoke("asd", `{"moviename":"Tenet","screen":"SC2","thid":"T1","showcode":["1"]}`)
+	s.mustInvoke("asm", `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":2},{"row":"B","seats":2}]}`)
+	s.mustInvoke("atax", taxes)
+}
+
+func TestCheckout(t *testing.T) {
+	s := newTestStub(t)
+	s.addCartTheatre()
+
+	runCases(t, s, []invokeCase{
+		{name: "empty cart", fn: "cart", arg: `{"thid":"T1"}`, code: codeInvalidInput},
+		{name: "unknown theatre", fn: "cart", arg: `{"thid":"T9","concessions":[{"item":"coffee","qty":1}]}`, code: codeNotFound},
+		{name: "by another organization", fn: "cart", arg: `{"thid":"T1","concessions":[{"item":"coffee","qty":1}]}`, code: codeUnauthorized, msp: "Org2MSP"},
+		{name: "unknown field of a line", fn: "cart", arg: `{"thid":"T1","lines":[{"screen":"SC1","showcode":"1","ticketsold":1,"price":10000,"popcorn":1}]}`, code: codeInvalidInput},
+	})
+
+	cart := `{"thid":"T1","customer":"CUST01","lines":[` +
+		`{"screen":"SC1","showcode":"2","seats":["A1","A2"],"price":10000},` +
+		`{"screen":"SC2","showcode":"1","ticketsold":2,"price":10000},` +
+		`{"screen":"SC1","showcode":"2","ticketsold":1,"price":10000}],` +
+		`"concessions":[{"item":"coffee","qty":2}]}`
+	s.as(testMSP, roleCashier)
+	result := s.mustInvoke("cart", cart)
+	s.as(testMSP, roleManager)
+
+	// Tickets 3 x 10000 + 2 x 10000 taxed at 12%, coffee 2 x 500 taxed at 2.5%
+	if result["subtotal"] != float64(51000) || result["tax"] != float64(6025) || result["total"] != float64(57025) || result["invoiceno"] != "T1-000000001" {
+		t.Errorf("Checkout :%v, expected the total 57025 on a single invoice", result)
+	}
+	ids := []string{}
+	for _, line := range result["lines"].([]interface{}) {
+		for _, id := range line.(map[string]interface{})["tickets"].([]interface{}) {
+			ids = append(ids, id.(string))
+		}
+	}
+	txid := result["trxnid"].(string)
+	if len(ids) != 5 {
+		t.Fatalf("Tickets of the cart :%v, expected 5", ids)
+	}
+	for n, id := range ids {
+		if id != ticketID(txid, n+1) {
+			t.Errorf("Tickets of the cart :%v, expected the tickets 1 to 5 of %s", ids, txid)
+			break
+		}
+	}
+
+	sold := []struct {
+		screen   string
+		showCode string
+		count    uint32
+	}{
+		{"SC1", "2", 3},
+		{"SC2", "1", 2},
+	}
+	for _, sd := range sold {
+		tkt := Tickets{}
+		if !s.record(&tkt, "Tickets", "T1", sd.screen, date0, sd.showCode) || tkt.TicketsSold != sd.count || tkt.PopCornSold != sd.count {
+			t.Errorf("Tickets of %s show %s :%+v, expected %d sold", sd.screen, sd.showCode, tkt, sd.count)
+		}
+	}
+	seats := ShowSeats{}
+	s.record(&seats, "ShowSeats", "T1", "SC1", date0, "2")
+	if seats.Booked["A1"] != ids[0] || seats.Booked["A2"] != ids[1] || len(seats.Booked) != 2 {
+		t.Errorf("Seats booked by the cart :%v", seats.Booked)
+	}
+	inv := Invoice{}
+	s.record(&inv, "Invoice", "T1", date0, "T1-000000001")
+	if len(inv.Lines) != 6 || inv.Lines[5].Kind != lineConcession || inv.Lines[5].Quantity != 2 || inv.Lines[5].Taxes[0].Amount != 25 {
+		t.Errorf("Invoice lines of the cart :%+v, expected 5 tickets and the coffee", inv.Lines)
+	}
+}
+
+func TestCheckoutRejected(t *testing.T) {
+	s := newTestStub(t)
+	s.addCartTheatre()
+	s.mustInvoke("sells", `{"thid":"T1","screen":"SC1","showcode":"1","seats":["B1"],"price":10000}`)
+	s.mustInvoke("acpn", `{"thid":"T1","code":"TENET","discount":"AMOUNT","value":1000,"movies":["Tenet"]}`)
+
+	tests := []struct {
+		name       string
+		cart       string
+		violations string
+	}{
+		{"every line rejected", `{"thid":"T1","lines":[` +
+			`{"screen":"SC1","showcode":"1","ticketsold":1,"price":10000},` +
+			`{"screen":"SC9","showcode":"1","ticketsold":1,"price":10000},` +
+			`{"screen":"SC1","showcode":"1","ticketsold":1,"seats":["A1"],"price":10000},` +
+			`{"screen":"SC1","showcode":"3","ticketsold":1,"price":10000},` +
+			`{"screen":"SC1","showcode":"1","seats":["B1"],"price":10000}],` +
+			`"concessions":[{"item":"nachos","qty":1},{"item":"tea","qty":0}]}`,
+			"lines[1]:NOT_FOUND lines[2]:INVALID_INPUT lines[3]:NOT_FOUND lines[4]:CONFLICT concessions[0]:NOT_FOUND concessions[1]:NOT_FOUND"},
+		{"lines of a show over the capacity", `{"thid":"T1","lines":[` +
+			`{"screen":"SC2","showcode":"1","ticketsold":3,"price":10000},` +
+			`{"screen":"SC2","showcode":"1","ticketsold":2,"price":10000}]}`,
+			"lines[1]:CAPACITY_EXCEEDED"},
+		{"seat in two lines", `{"thid":"T1","lines":[` +
+			`{"screen":"SC1","showcode":"2","seats":["A1"],"price":10000},` +
+			`{"screen":"SC1","showcode":"2","seats":["A2","A1"],"price":10000}]}`,
+			"lines[1]:CONFLICT"},
+		{"coupon not valid for a line", `{"thid":"T1","coupon":"TENET","lines":[` +
+			`{"screen":"SC2","showcode":"1","ticketsold":1,"price":10000},` +
+			`{"screen":"SC1","showcode":"2","ticketsold":1,"price":10000}]}`,
+			"lines[1]:CONFLICT"},
+	}
+	saved := len(s.State)
+	for _, tt := range tests {
+		r := s.invoke("cart", tt.cart)
+		er := ErrorResponse{}
+		json.Unmarshal([]byte(r.Message), &er)
+		violations := []string{}
+		for _, v := range er.Violations {
+			violations = append(violations, v.Field+":"+v.Code)
+		}
+		if er.Code != codeCartRejected || strings.Join(violations, " ") != tt.violations {
+			t.Errorf("%s: response %s, expected the violations %s", tt.name, r.Message, tt.violations)
+		}
+	}
+	if len(s.State) != saved {
+		t.Errorf("Rejected carts saved %d records", len(s.State)-saved)
+	}
+
+	s.mustInvoke("dact", `{"thid":"T1","screen":"SC2"}`)
+	runCases(t, s, []invokeCase{
+		{name: "line of a deactivated screen", fn: "cart", arg: `{"thid":"T1","lines":[{"screen":"SC2","showcode":"1","ticketsold":1,"price":10000}]}`, code: codeCartRejected},
+		{name: "deactivated theatre", fn: "dact", arg: `{"thid":"T1"}`},
+		{name: "concessions of a deactivated theatre", fn: "cart", arg: `{"thid":"T1","concessions":[{"item":"coffee","qty":1}]}`, code: codeInactive},
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// addCount adds the counts. Returns an error instead of wrapping around if the sum does not fit in the count
+func addCount(count uint32, more uint32) (uint32, error) {
+	if count > math.MaxUint32-more {
+		return 0, newError(codeCapacityExceeded, "Count %d can not be increased by %d", count, more)
+	}
+	return count + more, nil
+}
+
+// subCount subtracts the counts. Count does not go below 0
+func subCount(count uint32, less uint32) uint32 {
+	if less > count {
+		return 0
+	}
+	return count - less
+}
+
+// minCount returns the smaller of the counts
+func capCount(count uint32, limit uint32) uint32 {
+	if count > limit {
+		return limit
+	}
+	return count
+}
+
+// withinCapacity checks if the counts added together do not exceed the capacity
+func withinCapacity(capacity uint32, counts ...int) bool {
+	total := uint64(0)
+	for _, count := range counts {
+		if count < 0 {
+			return false
+		}
+		total += uint64(count)
+	}
+	return total <= uint64(capacity)
+}
+
+// addSold adds the tickets sold along with the popcorn and water issued per ticket
+func (tkt *Tickets) addSold(count uint32) error {
+	sold, err := addCount(tkt.TicketsSold, count)
+	if err != nil {
+		return err
+	}
+	popcorn, err := addCount(tkt.PopCornSold, count)
+	if err != nil {
+		return err
+	}
+	water, err := addCount(tkt.WaterSold, count)
+	if err != nil {
+		return err
+	}
+	tkt.TicketsSold, tkt.PopCornSold, tkt.WaterSold = sold, popcorn, water
+	return nil
+}
+
+// RecountRequest is the input to recount the tickets sold for the shows of a theatre
+type RecountRequest struct {
+	TheatreID string `json:"thid"` // Alphanumeric
+}
+
+// showCount is the count of the tickets issued for a show from the ticket records
+type showCount struct {
+	issued    uint32
+	exchanged uint32
+}
+
+// Migrate the tickets sold for the shows of a theatre to the wider counts. Counts saved before the counts were
+// widened could wrap around after 255. Such counts are corrected from the ticket records of the show. Shows without
+// the ticket records can not be recounted. Their counts over the seats of the screen are capped at the seats and
+// returned as "anomalies" to be checked
+func (s *ShowsManagement) recountTickets(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("recountTickets", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var req RecountRequest
+	err := json.Unmarshal([]byte(args[0]), &req)
+	if err != nil {
+		return errorResponse("recountTickets", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+	thid := req.TheatreID
+
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("recountTickets", thid, err)
+	}
+
+	// Tickets sold and the water exchanged with soda as per the ticket records of each show
+	counts := map[string]*showCount{}
+	ticketIterator, err := stub.GetStateByPartialCompositeKey("Ticket", []string{thid})
+	if err != nil {
+		_logger.Errorf("recountTickets:GetStateByPartialCompositeKey is Failed :" + string(err.Error()))
+		return errorResponse("recountTickets", errorCode(err), thid, "Unable to recount the tickets")
+	}
+	defer ticketIterator.Close()
+	for ticketIterator.HasNext() {
+		record, err := ticketIterator.Next()
+		if err != nil {
+			_logger.Errorf("recountTickets:Query iteration is Failed :" + string(err.Error()))
+			return errorResponse("recountTickets", errorCode(err), thid, "Unable to recount the tickets")
+		}
+		t := Ticket{}
+		if json.Unmarshal(record.Value, &t) != nil || (t.Status != ticketSold && t.Status != ticketRedeemed) {
+			continue
+		}
+		show := t.Screen + "|" + t.ShowDate + "|" + t.ShowCode
+		if counts[show] == nil {
+			counts[show] = &showCount{}
+		}
+		counts[show].issued++
+		if t.WaterExch {
+			counts[show].exchanged++
+		}
+	}
+
+	tktIterator, err := stub.GetStateByPartialCompositeKey("Tickets", []string{thid})
+	if err != nil {
+		_logger.Errorf("recountTickets:GetStateByPartialCompositeKey is Failed :" + string(err.Error()))
+		return errorResponse("recountTickets", errorCode(err), thid, "Unable to recount the tickets")
+	}
+	defer tktIterator.Close()
+
+	checked, corrected, anomalies := 0, []string{}, []string{}
+	for tktIterator.HasNext() {
+		record, err := tktIterator.Next()
+		if err != nil {
+			_logger.Errorf("recountTickets:Query iteration is Failed :" + string(err.Error()))
+			return errorResponse("recountTickets", errorCode(err), thid, "Unable to recount the tickets")
+		}
+		tkt := Tickets{}
+		err = json.Unmarshal(record.Value, &tkt)
+		if err != nil {
+			return errorResponse("recountTickets", codeLedgerError, thid, "Existing ticket details Unmarshalling error")
+		}
+		checked++
+
+		show := tkt.Screen + " " + tkt.ShowDate + " " + tkt.ShowCode
+		count := counts[tkt.Screen+"|"+tkt.ShowDate+"|"+tkt.ShowCode]
+		if count == nil {
+			seats := td.SeatsPerHall[tkt.Screen]
+			if tkt.TicketsSold <= seats && tkt.PopCornSold <= seats && tkt.WaterSold <= seats && tkt.SodaSold <= seats {
+				continue
+			}
+			tkt.TicketsSold, tkt.PopCornSold = capCount(tkt.TicketsSold, seats), capCount(tkt.PopCornSold, seats)
+			tkt.WaterSold, tkt.SodaSold = capCount(tkt.WaterSold, seats), capCount(tkt.SodaSold, seats)
+			anomalies = append(anomalies, show)
+		} else {
+			if tkt.TicketsSold >= count.issued {
+				continue
+			}
+			tkt.TicketsSold = count.issued
+			if tkt.PopCornSold < count.issued {
+				tkt.PopCornSold = count.issued
+			}
+			if tkt.WaterSold < subCount(count.issued, count.exchanged) {
+				tkt.WaterSold = subCount(count.issued, count.exchanged)
+			}
+			if tkt.SodaSold < count.exchanged {
+				tkt.SodaSold = count.exchanged
+			}
+			corrected = append(corrected, show)
+		}
+		tktjson, _ := json.Marshal(tkt)
+		err = putTheatreState(stub, *td, record.Key, tktjson)
+		if err != nil {
+			_logger.Errorf("recountTickets:PutState is Failed :" + string(err.Error()))
+			return errorResponse("recountTickets", errorCode(err), thid, "Unable to recount the tickets")
+		}
+	}
+	_logger.Infof("recountTickets:Tickets recounted successfully for :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":    stub.GetTxID(),
+		"checked":   checked,
+		"corrected": corrected,
+		"anomalies": anomalies,
+		"message":   "Recount tickets successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCounts(t *testing.T) {
+	adds := []struct {
+		count, more, sum uint32
+		code             string
+	}{
+		{255, 1, 256, ""},
+		{math.MaxUint32 - 1, 1, math.MaxUint32, ""},
+		{math.MaxUint32, 1, 0, codeCapacityExceeded},
+		{1, math.MaxUint32, 0, codeCapacityExceeded},
+	}
+	for _, a := range adds {
+		sum, err := addCount(a.count, a.more)
+		code := ""
+		if err != nil {
+			code = errorCode(err)
+		}
+		if sum != a.sum || code != a.code {
+			t.Errorf("%d + %d = %d, %v. Expected %d with the code %q", a.count, a.more, sum, err, a.sum, a.code)
+		}
+	}
+	if subCount(3, 5) != 0 || subCount(300, 45) != 255 {
+		t.Error("Counts are not subtracted upto 0")
+	}
+
+	capacities := []struct {
+		capacity uint32
+		counts   []int
+		within   bool
+	}{
+		{300, []int{256, 44}, true},
+		{300, []int{256, 45}, false},
+		{300, []int{301, -1}, false},
+		{math.MaxUint32, []int{math.MaxInt32, math.MaxInt32}, true},
+	}
+	for _, c := range capacities {
+		if withinCapacity(c.capacity, c.counts...) != c.within {
+			t.Errorf("Counts %v within the capacity %d, expected %v", c.counts, c.capacity, c.within)
+		}
+	}
+}
+
+func TestCapacityOver255(t *testing.T) {
+	s := newTestStub(t)
+	s.mustInvoke("athd", `{"thid":"T1","maxsoda":1000,"sph":{"SC1":300}}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showcode":["1"]}`)
+
+	runCases(t, s, []invokeCase{
+		{name: "screen over 255 seats", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":280,"price":10000}`},
+		{name: "over the capacity", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":21,"price":10000}`, code: codeCapacityExceeded},
+		{name: "count wrapping around 256", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":236,"price":10000}`, code: codeCapacityExceeded},
+		{name: "upto the capacity", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":20,"price":10000}`},
+	})
+
+	tkt := Tickets{}
+	s.record(&tkt, "Tickets", "T1", "SC1", date0, "1")
+	if tkt.TicketsSold != 300 || tkt.PopCornSold != 300 || tkt.WaterSold != 300 {
+		t.Errorf("Tickets sold :%+v, expected 300", tkt)
+	}
+	td := TheatreDetails{}
+	s.record(&td, "TheatreDetails", "T1")
+	if td.SeatsPerHall["SC1"] != 300 || td.MaxSodaPerDay != 1000 {
+		t.Errorf("Theatre :%+v, expected 300 seats and 1000 soda", td)
+	}
+}
+
+func TestRecountTickets(t *testing.T) {
+	s := newTestStub(t)
+	s.mustInvoke("athd", `{"thid":"T1","maxsoda":1000,"sph":{"SC1":300}}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showcode":["1","2"]}`)
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":260,"price":10000}`)
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":3,"price":10000}`)
+
+	// Counts saved before the counts were widened wrapped around after 255. Shows sold before the ticket records have
+	// only the counts
+	key, _ := s.CreateCompositeKey("Tickets", []string{"T1", "SC1", date0, "1"})
+	s.putLegacy(key, `{"obj":"Tickets","thid":"T1","screen":"SC1","showdate":"`+date0+`","showcode":"1","ticketsold":4,"pcsold":4,"watersold":4}`)
+	for showCode, sold := range map[string]string{"3": "400", "4": "20"} {
+		key, _ = s.CreateCompositeKey("Tickets", []string{"T1", "SC1", date1, showCode})
+		s.putLegacy(key, `{"obj":"Tickets","thid":"T1","screen":"SC1","showdate":"`+date1+`","showcode":"`+showCode+`","ticketsold":`+sold+`,"pcsold":`+sold+`,"watersold":`+sold+`}`)
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "unknown theatre", fn: "rcnt", arg: `{"thid":"T9"}`, code: codeNotFound},
+		{name: "by a cashier", fn: "rcnt", arg: `{"thid":"T1"}`, code: codeUnauthorized, role: roleCashier},
+		{name: "by another organization", fn: "rcnt", arg: `{"thid":"T1"}`, code: codeUnauthorized, msp: "Org2MSP"},
+	})
+
+	result := s.mustInvoke("rcnt", `{"thid":"T1"}`)
+	corrected, _ := result["corrected"].([]interface{})
+	anomalies, _ := result["anomalies"].([]interface{})
+	if result["checked"] != float64(4) || len(corrected) != 1 || corrected[0] != "SC1 "+date0+" 1" {
+		t.Errorf("Recount :%v, expected the show 1 corrected", result)
+	}
+	if len(anomalies) != 1 || anomalies[0] != "SC1 "+date1+" 3" {
+		t.Errorf("Anomalies of the recount :%v, expected the show 3 over the seats", anomalies)
+	}
+	counts := []struct {
+		showDate string
+		showCode string
+		sold     uint32
+	}{
+		{date0, "1", 260},
+		{date0, "2", 3},
+		{date1, "3", 300},
+		{date1, "4", 20},
+	}
+	for _, c := range counts {
+		tkt := Tickets{}
+		s.record(&tkt, "Tickets", "T1", "SC1", c.showDate, c.showCode)
+		if tkt.TicketsSold != c.sold || tkt.PopCornSold != c.sold || tkt.WaterSold != c.sold {
+			t.Errorf("Tickets of the show %s on %s recounted :%+v, expected %d", c.showCode, c.showDate, tkt, c.sold)
+		}
+	}
+
+	result = s.mustInvoke("rcnt", `{"thid":"T1"}`)
+	corrected, _ = result["corrected"].([]interface{})
+	anomalies, _ = result["anomalies"].([]interface{})
+	if len(corrected) != 0 || len(anomalies) != 0 {
+		t.Errorf("Recount again corrected %v and capped %v, expected none", corrected, anomalies)
+	}
+}
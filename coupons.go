@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Discount rules of the coupons
+const (
+	discountPercent = "PERCENT" // Value percent off each ticket
+	discountAmount  = "AMOUNT"  // Value off each ticket, leaving a price of at least 1
+	discountFixed   = "FIXED"   // Each ticket at the price Value (ex: student price)
+	discountBuyGet  = "BUYGET"  // For every Buy tickets, Get more tickets free. Cheapest tickets of the sale are free
+)
+
+var discountRules = []string{discountPercent, discountAmount, discountFixed, discountBuyGet}
+
+// Coupon is a discount code of a theatre. A coupon is used once per sale irrespective of the tickets in the sale
+type Coupon struct {
+	ObjType        string   `json:"obj"`
+	TheatreID      string   `json:"thid"`           // Alphanumeric
+	Code           string   `json:"code"`           // Alphanumeric. Unique for the theatre
+	Description    string   `json:"description"`    //
+	ValidFrom      int64    `json:"validfrom"`      // epoch format. Coupon can be used from the transaction timestamp
+	ValidTo        int64    `json:"validto"`        // epoch format. Coupon can be used till the transaction timestamp. 0 if there is no end
+	Weekdays       []int    `json:"weekdays"`       // Days of the show date (0 - Sunday to 6 - Saturday). All the days if not provided
+	Movies         []string `json:"movies"`         // Eligible movies. All the movies if not provided
+	Screens        []string `json:"screens"`        // Eligible screens. All the screens if not provided
+	Discount       string   `json:"discount"`       // PERCENT, AMOUNT, FIXED or BUYGET
+	Value          uint32   `json:"value"`          // Percent, amount off or fixed price. Not used for BUYGET
+	Buy            uint32   `json:"buy"`            // Tickets to buy for BUYGET
+	Get            uint32   `json:"get"`            // Free tickets for BUYGET
+	MinTickets     uint32   `json:"mintickets"`     // Min tickets in the sale
+	MaxUses        uint32   `json:"maxuses"`        // Max sales the coupon can be used for. 0 if not limited
+	MaxPerCustomer uint32   `json:"maxpercustomer"` // Max sales of a customer the coupon can be used for. 0 if not limited
+	Used           uint32   `json:"used"`           // Sales the coupon is used for. Set by the chaincode
+	CreateTs       string   `json:"cts"`            // RFC3339. Transaction timestamp of the first save
+	UpdateTs       string   `json:"uts"`            // RFC3339. Transaction timestamp of the latest save
+}
+
+// CouponUse is the number of sales of a customer a coupon is used for
+type CouponUse struct {
+	ObjType   string `json:"obj"`
+	TheatreID string `json:"thid"`     // Alphanumeric
+	Code      string `json:"code"`     //
+	Customer  string `json:"customer"` //
+	Used      uint32 `json:"used"`     //
+	CreateTs  string `json:"cts"`      // RFC3339. Transaction timestamp of the first save
+	UpdateTs  string `json:"uts"`      // RFC3339. Transaction timestamp of the latest save
+}
+
+// getCoupon fetches a coupon of the theatre. Returns nil if the coupon does not exists
+func getCoupon(stub shim.ChaincodeStubInterface, thid string, code string) (*Coupon, error) {
+	couponDetails, err := getRecord(stub, "Coupon", thid, code)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if couponDetails == nil {
+		return nil, nil
+	}
+	cp := Coupon{}
+	err = json.Unmarshal(couponDetails, &cp)
+	if err != nil {
+		return nil, fmt.Errorf("Existing coupon Unmarshalling error")
+	}
+	return &cp, nil
+}
+
+// getCouponUse fetches the use of a coupon by a customer. Returns no use if the customer has not used the coupon
+func getCouponUse(stub shim.ChaincodeStubInterface, thid string, code string, customer string) (CouponUse, error) {
+	use := CouponUse{ObjType: "CouponUse", TheatreID: thid, Code: code, Customer: customer}
+	useDetails, err := getRecord(stub, "CouponUse", thid, code, customer)
+	if err != nil {
+		return use, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if useDetails == nil {
+		return use, nil
+	}
+	err = json.Unmarshal(useDetails, &use)
+	if err != nil {
+		return use, fmt.Errorf("Existing coupon use Unmarshalling error")
+	}
+	return use, nil
+}
+
+// eligible checks if the coupon can be used for the sale of the show at the given time
+func (cp Coupon) eligible(sc string, dt string, quote SaleQuote, now int64) error {
+	if now < cp.ValidFrom || (cp.ValidTo > 0 && now > cp.ValidTo) {
+		return newError(codeConflict, "Coupon %s is not valid at the time of the sale", cp.Code)
+	}
+	if len(cp.Screens) > 0 && !contains(cp.Screens, sc) {
+		return newError(codeConflict, "Coupon %s is not valid for the screen %s", cp.Code, sc)
+	}
+	if len(cp.Movies) > 0 && !contains(cp.Movies, quote.MovieName) {
+		return newError(codeConflict, "Coupon %s is not valid for the movie %s", cp.Code, quote.MovieName)
+	}
+	if len(cp.Weekdays) > 0 {
+		date, _ := time.Parse(dateFormat, dt)
+		found := false
+		for _, day := range cp.Weekdays {
+			found = found || day == int(date.Weekday())
+		}
+		if !found {
+			return newError(codeConflict, "Coupon %s is not valid for the shows on %s", cp.Code, date.Weekday())
+		}
+	}
+	if len(quote.Tickets) < int(cp.MinTickets) {
+		return newError(codeConflict, "Coupon %s requires %d or more tickets in the sale", cp.Code, cp.MinTickets)
+	}
+	return nil
+}
+
+// discounts returns the discount on each ticket of the quote
+func (cp Coupon) discounts(quote SaleQuote) []uint32 {
+	discounts := make([]uint32, len(quote.Tickets))
+	if cp.Discount == discountBuyGet {
+		// Cheapest tickets are free. Tickets of the same price are taken in the order of the sale
+		order := make([]int, len(quote.Tickets))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return quote.Tickets[order[i]].Price < quote.Tickets[order[j]].Price })
+		free := len(quote.Tickets) / int(cp.Buy+cp.Get) * int(cp.Get)
+		for _, i := range order[:free] {
+			discounts[i] = quote.Tickets[i].Price
+		}
+		return discounts
+	}
+	for i, ticket := range quote.Tickets {
+		switch cp.Discount {
+		case discountPercent:
+			discounts[i] = uint32(uint64(ticket.Price) * uint64(cp.Value) / 100)
+		case discountAmount:
+			// Amount off a ticket priced at or below the amount leaves the smallest unit of the price
+			discounts[i] = cp.Value
+			if discounts[i] >= ticket.Price {
+				discounts[i] = subCount(ticket.Price, 1)
+			}
+		case discountFixed:
+			discounts[i] = subCount(ticket.Price, cp.Value)
+		}
+		if discounts[i] > ticket.Price {
+			discounts[i] = ticket.Price
+		}
+	}
+	return discounts
+}
+
+// applyCoupon discounts the quoted tickets with the coupon provided with the sale. Returns the coupon and the use of
+// the customer to be counted when the sale is saved. Returns nil if no coupon is provided
+func applyCoupon(stub shim.ChaincodeStubInterface, thid string, sc string, dt string, quote *SaleQuote, sale TicketSale) (*Coupon, *CouponUse, error) {
+	if sale.Coupon == "" {
+		return nil, nil, nil
+	}
+	cp, err := getCoupon(stub, thid, sale.Coupon)
+	if err == nil && cp == nil {
+		err = newError(codeNotFound, "Coupon %s does not exists for the theatre", sale.Coupon)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = cp.eligible(sc, dt, *quote, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cp.MaxUses > 0 && cp.Used >= cp.MaxUses {
+		return nil, nil, newError(codeCapacityExceeded, "Coupon %s is used up", cp.Code)
+	}
+
+	var use *CouponUse
+	if cp.MaxPerCustomer > 0 {
+		if sale.Customer == "" {
+			return nil, nil, newError(codeInvalidInput, "Customer is required to use the coupon %s", cp.Code)
+		}
+		customerUse, err := getCouponUse(stub, thid, cp.Code, sale.Customer)
+		if err != nil {
+			return nil, nil, err
+		}
+		if customerUse.Used >= cp.MaxPerCustomer {
+			return nil, nil, newError(codeCapacityExceeded, "Coupon %s is used up by the customer %s", cp.Code, sale.Customer)
+		}
+		use = &customerUse
+	}
+
+	quote.Coupon = cp.Code
+	quote.Total = 0
+	for i, discount := range cp.discounts(*quote) {
+		quote.Tickets[i].Discount = discount
+		quote.Discount += uint64(discount)
+		quote.Total += uint64(quote.Tickets[i].Price - discount)
+	}
+	return cp, use, nil
+}
+
+// useCoupon counts the use of the coupon for the sale
+func useCoupon(stub shim.ChaincodeStubInterface, td TheatreDetails, cp *Coupon, use *CouponUse) error {
+	if cp == nil {
+		return nil
+	}
+	used, err := addCount(cp.Used, 1)
+	if err != nil {
+		return err
+	}
+	cp.Used = used
+	cpjson, _ := json.Marshal(cp)
+	err = putRecord(stub, td, cpjson, "Coupon", cp.TheatreID, cp.Code)
+	if err != nil || use == nil {
+		return err
+	}
+	use.Used++
+	usejson, _ := json.Marshal(use)
+	return putRecord(stub, td, usejson, "CouponUse", use.TheatreID, use.Code, use.Customer)
+}
+
+// Add or replace a coupon of a theatre. Uses of the coupon are carried forward when the coupon is replaced
+func (s *ShowsManagement) addCoupon(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("addCoupon", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var cp Coupon
+	err := json.Unmarshal([]byte(args[0]), &cp)
+	if err != nil {
+		return errorResponse("addCoupon", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := cp.TheatreID
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("addCoupon", thid, err)
+	}
+
+	// Validate the discount rule and the eligibility of the coupon
+	if !contains(discountRules, cp.Discount) {
+		return errorResponse("addCoupon", codeInvalidInput, cp.Discount, "Invalid discount. Expected PERCENT, AMOUNT, FIXED or BUYGET")
+	}
+	// Tickets are given free only by BUYGET
+	if cp.Discount == discountPercent && (cp.Value == 0 || cp.Value >= 100) {
+		return errorResponse("addCoupon", codeInvalidInput, strconv.Itoa(int(cp.Value)), "Invalid percent. Expected 1 to 99")
+	}
+	if (cp.Discount == discountAmount || cp.Discount == discountFixed) && cp.Value == 0 {
+		return errorResponse("addCoupon", codeInvalidInput, strconv.Itoa(int(cp.Value)), "Invalid amount. Expected 1 or more")
+	}
+	if cp.Discount == discountBuyGet && (cp.Buy == 0 || cp.Get == 0) {
+		return errorResponse("addCoupon", codeInvalidInput, cp.Code, "Buy and get tickets are required for BUYGET")
+	}
+	if cp.ValidTo > 0 && cp.ValidTo < cp.ValidFrom {
+		return errorResponse("addCoupon", codeInvalidInput, strconv.FormatInt(cp.ValidTo, 10), "Invalid validity. Expected validto after validfrom")
+	}
+	for _, day := range cp.Weekdays {
+		if day < 0 || day > 6 {
+			return errorResponse("addCoupon", codeInvalidInput, strconv.Itoa(day), "Invalid weekday. Expected 0 (Sunday) to 6 (Saturday)")
+		}
+	}
+	for _, sc := range cp.Screens {
+		if td.SeatsPerHall[sc] == 0 {
+			return errorResponse("addCoupon", codeNotFound, sc, "Screen does not exists for the theatre")
+		}
+	}
+
+	existing, err := getCoupon(stub, thid, cp.Code)
+	if err != nil {
+		return failedResponse("addCoupon", thid, err)
+	}
+	cp.Used = 0
+	if existing != nil {
+		cp.Used = existing.Used
+	}
+
+	cp.ObjType = "Coupon"
+	cpjson, _ := json.Marshal(cp)
+	err = putRecord(stub, *td, cpjson, "Coupon", thid, cp.Code)
+	if err != nil {
+		_logger.Errorf("addCoupon:PutState is Failed :" + string(err.Error()))
+		return errorResponse("addCoupon", errorCode(err), thid, "Unable to add the coupon")
+	}
+	_logger.Infof("addCoupon:Coupon added succesfully for :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":  stub.GetTxID(),
+		"code":    cp.Code,
+		"used":    cp.Used,
+		"message": "Add Coupon Success",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCouponDiscounts(t *testing.T) {
+	quote := SaleQuote{Tickets: []PricedTicket{{Price: 15000}, {Price: 10000}, {Price: 10000}, {Price: 12000}}}
+	tests := []struct {
+		name      string
+		coupon    Coupon
+		discounts []uint32
+	}{
+		{"10 percent", Coupon{Discount: discountPercent, Value: 10}, []uint32{1500, 1000, 1000, 1200}},
+		{"amount off", Coupon{Discount: discountAmount, Value: 11000}, []uint32{11000, 9999, 9999, 11000}},
+		{"amount off the price", Coupon{Discount: discountAmount, Value: 12000}, []uint32{12000, 9999, 9999, 11999}},
+		{"student price", Coupon{Discount: discountFixed, Value: 12000}, []uint32{3000, 0, 0, 0}},
+		{"buy 1 get 1", Coupon{Discount: discountBuyGet, Buy: 1, Get: 1}, []uint32{0, 10000, 10000, 0}},
+		{"buy 2 get 1", Coupon{Discount: discountBuyGet, Buy: 2, Get: 1}, []uint32{0, 10000, 0, 0}},
+		{"buy 4 get 1", Coupon{Discount: discountBuyGet, Buy: 4, Get: 1}, []uint32{0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		discounts := tt.coupon.discounts(quote)
+		for i := range discounts {
+			if discounts[i] != tt.discounts[i] {
+				t.Errorf("%s: discounts %v, expected %v", tt.name, discounts, tt.discounts)
+				break
+			}
+		}
+	}
+	// Unpriced tickets are not discounted
+	unpriced := SaleQuote{Tickets: []PricedTicket{{Price: 0}, {Price: 1}}}
+	if discounts := (Coupon{Discount: discountAmount, Value: 500}).discounts(unpriced); discounts[0] != 0 || discounts[1] != 0 {
+		t.Errorf("Amount off the tickets priced at 0 and 1 :%v, expected no discount", discounts)
+	}
+}
+
+func TestAddCoupon(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	coupon := func(fields string) string {
+		return `{"thid":"T1","code":"PROMO",` + fields + `}`
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "unknown theatre", fn: "acpn", arg: `{"thid":"T9","code":"PROMO","discount":"PERCENT","value":10}`, code: codeNotFound},
+		{name: "unknown discount", fn: "acpn", arg: coupon(`"discount":"HALF","value":50`), code: codeInvalidInput},
+		{name: "no percent", fn: "acpn", arg: coupon(`"discount":"PERCENT","value":0`), code: codeInvalidInput},
+		{name: "100 percent", fn: "acpn", arg: coupon(`"discount":"PERCENT","value":100`), code: codeInvalidInput},
+		{name: "no amount", fn: "acpn", arg: coupon(`"discount":"AMOUNT"`), code: codeInvalidInput},
+		{name: "free tickets at a fixed price", fn: "acpn", arg: coupon(`"discount":"FIXED","value":0`), code: codeInvalidInput},
+		{name: "buy without get", fn: "acpn", arg: coupon(`"discount":"BUYGET","buy":2`), code: codeInvalidInput},
+		{name: "validity ending before the start", fn: "acpn", arg: coupon(`"discount":"PERCENT","value":10,"validfrom":200,"validto":100`), code: codeInvalidInput},
+		{name: "unknown weekday", fn: "acpn", arg: coupon(`"discount":"PERCENT","value":10,"weekdays":[7]`), code: codeInvalidInput},
+		{name: "unknown screen", fn: "acpn", arg: coupon(`"discount":"PERCENT","value":10,"screens":["SC9"]`), code: codeNotFound},
+		{name: "uses by the client", fn: "acpn", arg: coupon(`"discount":"PERCENT","value":10,"used":5`), code: codeInvalidInput},
+		{name: "by a cashier", fn: "acpn", arg: coupon(`"discount":"PERCENT","value":10`), code: codeUnauthorized, role: roleCashier},
+		{name: "coupon", fn: "acpn", arg: coupon(`"discount":"PERCENT","value":10,"maxuses":5`)},
+		{name: "coupon used", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000,"coupon":"PROMO"}`},
+		{name: "coupon replaced", fn: "acpn", arg: coupon(`"discount":"AMOUNT","value":2000,"maxuses":5`)},
+	})
+
+	cp := Coupon{}
+	s.record(&cp, "Coupon", "T1", "PROMO")
+	if cp.Used != 1 || cp.Discount != discountAmount || cp.Value != 2000 {
+		t.Errorf("Coupon replaced :%+v, expected 1 use carried forward", cp)
+	}
+}
+
+func TestCouponSales(t *testing.T) {
+	s := newTestStub(t)
+	s.mustInvoke("athd", `{"thid":"T1","maxsoda":200,"sph":{"SC1":50,"SC2":50}}`)
+	start := strconv.FormatInt(day0+10*3600, 10)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","shows":[{"showcode":"1","start":`+start+`}]}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC2","thid":"T1","shows":[{"showcode":"1","start":`+start+`}]}`)
+	coupons := []string{
+		`{"thid":"T1","code":"STUDENT","discount":"FIXED","value":6000,"maxuses":2,"maxpercustomer":1}`,
+		`{"thid":"T1","code":"TUESDAY","discount":"PERCENT","value":50,"weekdays":[2]}`,
+		`{"thid":"T1","code":"EVENING","discount":"AMOUNT","value":1000,"validfrom":` + strconv.FormatInt(day0+18*3600, 10) + `}`,
+		`{"thid":"T1","code":"TENET","discount":"AMOUNT","value":1000,"movies":["Tenet"]}`,
+		`{"thid":"T1","code":"SCREEN2","discount":"AMOUNT","value":1000,"screens":["SC2"]}`,
+		`{"thid":"T1","code":"FAMILY","discount":"BUYGET","buy":2,"get":1,"mintickets":3}`,
+		`{"thid":"T1","code":"VOUCHER","discount":"AMOUNT","value":25000}`,
+	}
+	for _, cp := range coupons {
+		s.mustInvoke("acpn", cp)
+	}
+	sell := func(sc string, count int, coupon string, customer string) string {
+		return `{"thid":"T1","screen":"` + sc + `","showcode":"1","ticketsold":` + strconv.Itoa(count) + `,"price":10000,"coupon":"` + coupon + `","customer":"` + customer + `"}`
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "unknown coupon", fn: "sell", arg: sell("SC1", 1, "FREE", ""), code: codeNotFound},
+		{name: "coupon of a customer without the customer", fn: "sell", arg: sell("SC1", 1, "STUDENT", ""), code: codeInvalidInput},
+		{name: "student price", fn: "sell", arg: sell("SC1", 2, "STUDENT", "CUST01")},
+		{name: "student price again for the customer", fn: "sell", arg: sell("SC1", 1, "STUDENT", "CUST01"), code: codeCapacityExceeded},
+		{name: "student price for another customer", fn: "sell", arg: sell("SC1", 1, "STUDENT", "CUST02")},
+		{name: "student price used up", fn: "sell", arg: sell("SC1", 1, "STUDENT", "CUST03"), code: codeCapacityExceeded},
+		{name: "show on a friday", fn: "sell", arg: sell("SC1", 1, "TUESDAY", ""), code: codeConflict},
+		{name: "before the coupon is valid", fn: "sell", arg: sell("SC1", 1, "EVENING", ""), code: codeConflict},
+		{name: "another movie", fn: "sell", arg: sell("SC1", 1, "TENET", ""), code: codeConflict},
+		{name: "another screen", fn: "sell", arg: sell("SC1", 1, "SCREEN2", ""), code: codeConflict},
+		{name: "less than the min tickets", fn: "sell", arg: sell("SC1", 2, "FAMILY", ""), code: codeConflict},
+		{name: "eligible screen", fn: "sell", arg: sell("SC2", 1, "SCREEN2", "")},
+		{name: "unpriced quote of a screen without a price list", fn: "quote", arg: `{"thid":"T1","screen":"SC2","showcode":"1","ticketsold":3,"coupon":"FAMILY"}`},
+	})
+
+	result := s.mustInvoke("sell", sell("SC2", 3, "FAMILY", ""))
+	if result["discount"] != float64(10000) || result["amount"] != float64(20000) {
+		t.Errorf("Sale of 3 tickets with the coupon :%v, expected 1 ticket free", result)
+	}
+	s.now = day0 + 18*3600
+	ids := ticketIDs(s.mustInvoke("sell", sell("SC1", 1, "EVENING", "")))
+	tr := Ticket{}
+	if !s.record(&tr, "Ticket", "T1", ids[0]) || tr.Coupon != "EVENING" || tr.Discount != 1000 || tr.Price != 9000 {
+		t.Errorf("Ticket of the sale with the coupon :%+v, expected 9000 after 1000 off", tr)
+	}
+	ids = ticketIDs(s.mustInvoke("sell", sell("SC1", 1, "VOUCHER", "")))
+	if !s.record(&tr, "Ticket", "T1", ids[0]) || tr.Discount != 9999 || tr.Price != 1 {
+		t.Errorf("Ticket of the sale with the amount over the price :%+v, expected the price 1", tr)
+	}
+
+	uses := []struct {
+		code string
+		used uint32
+	}{
+		{"STUDENT", 2},
+		{"SCREEN2", 1},
+		{"FAMILY", 1},
+		{"TENET", 0},
+	}
+	for _, u := range uses {
+		cp := Coupon{}
+		if s.record(&cp, "Coupon", "T1", u.code); cp.Used != u.used {
+			t.Errorf("Coupon %s used %d times, expected %d", u.code, cp.Used, u.used)
+		}
+	}
+	use := CouponUse{}
+	if !s.record(&use, "CouponUse", "T1", "STUDENT", "CUST01") || use.Used != 1 {
+		t.Errorf("Student price used by the customer :%+v", use)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/statebased"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// EndorsementChange is the input to rotate or extend the organizations endorsing the keys of a theatre
+type EndorsementChange struct {
+	TheatreID string   `json:"thid"`     // Alphanumeric
+	Add       []string `json:"add"`      // MSP IDs of the organizations to add
+	Remove    []string `json:"remove"`   // MSP IDs of the organizations to remove
+	StartKey  string   `json:"startkey"` // Key to resume the change from. Returned as "nextkey" by the previous change
+	Limit     int      `json:"limit"`    // Max keys changed in a transaction. Defaults to 100
+}
+
+// endorsers returns the organizations that must endorse the changes to the keys of the theatre
+func (td TheatreDetails) endorsers() []string {
+	if len(td.EndorsingOrgs) > 0 {
+		return td.EndorsingOrgs
+	}
+	// Theatres added before the key-level endorsement was introduced are endorsed by the owning organization
+	if td.OwnerMSP != "" {
+		return []string{td.OwnerMSP}
+	}
+	return nil
+}
+
+// setKeyEndorsers sets the key-level endorsement policy requiring a peer of each of the given organizations
+func setKeyEndorsers(stub shim.ChaincodeStubInterface, key string, orgs []string) error {
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+	err = ep.AddOrgs(statebased.RoleTypePeer, orgs...)
+	if err != nil {
+		return err
+	}
+	policy, err := ep.Policy()
+	if err != nil {
+		return err
+	}
+	return stub.SetStateValidationParameter(key, policy)
+}
+
+// putTheatreState saves a key of the theatre along with the client identity. Keys created without an endorsement policy are bound to the
+// organizations endorsing the theatre
+func putTheatreState(stub shim.ChaincodeStubInterface, td TheatreDetails, key string, value []byte) error {
+	err := putState(stub, key, value)
+	if err != nil {
+		return err
+	}
+	policy, err := stub.GetStateValidationParameter(key)
+	if err != nil {
+		return err
+	}
+	if len(policy) > 0 || len(td.endorsers()) == 0 {
+		return nil
+	}
+	return setKeyEndorsers(stub, key, td.endorsers())
+}
+
+// Rotate or extend the organizations endorsing the keys of a theatre. The new policy is applied to the existing keys
+// of the theatre in batches, in the order of the object types. Change is complete when "nextkey" is not returned. A
+// change is resumed from "nextkey" with the same organizations
+func (s *ShowsManagement) changeEndorsers(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("changeEndorsers", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var ec EndorsementChange
+	err := json.Unmarshal([]byte(args[0]), &ec)
+	if err != nil {
+		return errorResponse("changeEndorsers", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := ec.TheatreID
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("changeEndorsers", thid, err)
+	}
+
+	orgs := []string{}
+	for _, org := range append(td.endorsers(), ec.Add...) {
+		if org != "" && !contains(orgs, org) && !contains(ec.Remove, org) {
+			orgs = append(orgs, org)
+		}
+	}
+	if len(orgs) == 0 {
+		return errorResponse("changeEndorsers", codeInvalidInput, thid, "Atleast one organization must endorse the theatre")
+	}
+
+	limit := ec.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	// Endorsers are saved with the theatre in the first batch. Later batches apply the saved endorsers
+	if ec.StartKey == "" {
+		td.EndorsingOrgs = orgs
+		tdjson, _ := json.Marshal(td)
+		key, err := stateKey(stub, "TheatreDetails", thid)
+		if err == nil {
+			err = putState(stub, key, tdjson)
+		}
+		if err != nil {
+			_logger.Errorf("changeEndorsers:PutState is Failed :" + string(err.Error()))
+			return errorResponse("changeEndorsers", errorCode(err), thid, "Unable to change the endorsers")
+		}
+	} else if strings.Join(orgs, ",") != strings.Join(td.endorsers(), ",") {
+		return errorResponse("changeEndorsers", codeConflict, ec.StartKey, "Endorsers of the theatre are changed after the change started. Start again without the startkey")
+	}
+
+	// Keys are visited in the order of the object types and the keys of an object type. Keys before the start key
+	// were changed by the previous batches
+	start, startType := 0, ""
+	if ec.StartKey != "" {
+		start = -1
+		objType, ids, err := stub.SplitCompositeKey(ec.StartKey)
+		for n := range objTypes {
+			if err == nil && len(ids) > 0 && ids[0] == thid && objTypes[n] == objType {
+				start, startType = n, objType
+			}
+		}
+		if start < 0 {
+			return errorResponse("changeEndorsers", codeInvalidInput, ec.StartKey, "Invalid start key of the theatre")
+		}
+	}
+	changed := map[string]bool{}
+	nextKey := ""
+	for _, objType := range objTypes[start:] {
+		records, err := getTheatreRecords(stub, objType, thid)
+		if err != nil {
+			return failedResponse("changeEndorsers", thid, err)
+		}
+		for _, record := range records {
+			if changed[record.Key] || (objType == startType && record.Key < ec.StartKey) {
+				continue
+			}
+			if len(changed) == limit {
+				nextKey = record.Key
+				break
+			}
+			err = setKeyEndorsers(stub, record.Key, orgs)
+			if err != nil {
+				_logger.Errorf("changeEndorsers:SetStateValidationParameter is Failed :" + string(err.Error()))
+				return errorResponse("changeEndorsers", errorCode(err), thid, "Unable to change the endorsers")
+			}
+			changed[record.Key] = true
+		}
+		if nextKey != "" {
+			break
+		}
+	}
+	_logger.Infof("changeEndorsers:Endorsers changed successfully for :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":    stub.GetTxID(),
+		"endorsers": orgs,
+		"keys":      len(changed),
+		"nextkey":   nextKey,
+		"message":   "Endorsers changed successfully",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/statebased"
+)
+
+// keyEndorsers returns the organizations of the key-level endorsement policy of the key
+func (s *testStub) keyEndorsers(key string) string {
+	s.t.Helper()
+	policy, _ := s.GetStateValidationParameter(key)
+	if len(policy) == 0 {
+		return ""
+	}
+	ep, err := statebased.NewStateEP(policy)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	orgs := ep.ListOrgs()
+	sort.Strings(orgs)
+	return strings.Join(orgs, ",")
+}
+
+// theatreKeys returns the keys of the records of the theatre
+func (s *testStub) theatreKeys(thid string) []string {
+	s.t.Helper()
+	keys := []string{}
+	for _, objType := range objTypes {
+		records, err := getTheatreRecords(s, objType, thid)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		for _, record := range records {
+			keys = append(keys, record.Key)
+		}
+	}
+	return keys
+}
+
+func TestKeyEndorsers(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000}`)
+	s.mustInvoke("athd", `{"thid":"T2","maxsoda":200,"sph":{"SC1":4}}`)
+
+	checkEndorsers := func(stage string, thid string, expected string) {
+		keys := s.theatreKeys(thid)
+		if len(keys) == 0 {
+			t.Fatalf("%s: no keys of the theatre %s", stage, thid)
+		}
+		for _, key := range keys {
+			if got := s.keyEndorsers(key); got != expected {
+				t.Errorf("%s: key %q is endorsed by %q, expected %q", stage, key, got, expected)
+			}
+		}
+	}
+	checkEndorsers("added", "T1", "Org1MSP")
+
+	runCases(t, s, []invokeCase{
+		{name: "unknown theatre", fn: "cep", arg: `{"thid":"T9","add":["Org2MSP"]}`, code: codeNotFound},
+		{name: "by a cashier", fn: "cep", arg: `{"thid":"T1","add":["Org2MSP"]}`, code: codeUnauthorized, role: roleCashier},
+		{name: "by another organization", fn: "cep", arg: `{"thid":"T1","add":["Org2MSP"]}`, code: codeUnauthorized, msp: "Org2MSP"},
+		{name: "no organization left", fn: "cep", arg: `{"thid":"T1","remove":["Org1MSP"]}`, code: codeInvalidInput},
+		{name: "organization added", fn: "cep", arg: `{"thid":"T1","add":["Org2MSP"]}`},
+	})
+	checkEndorsers("extended", "T1", "Org1MSP,Org2MSP")
+	checkEndorsers("other theatre", "T2", "Org1MSP")
+
+	runCases(t, s, []invokeCase{
+		{name: "organization rotated", fn: "cep", arg: `{"thid":"T1","add":["Org3MSP"],"remove":["Org1MSP"]}`},
+		{name: "tickets after the rotation", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":1,"price":10000}`},
+	})
+	checkEndorsers("rotated", "T1", "Org2MSP,Org3MSP")
+
+	td := TheatreDetails{}
+	s.record(&td, "TheatreDetails", "T1")
+	if strings.Join(td.EndorsingOrgs, ",") != "Org2MSP,Org3MSP" || td.OwnerMSP != "Org1MSP" {
+		t.Errorf("Theatre details after the rotation :%+v", td)
+	}
+
+	// Keys are changed in batches resumed from the next key
+	change := `{"thid":"T1","add":["Org4MSP"],"limit":2`
+	startKey := func(key interface{}) string {
+		keyjson, _ := json.Marshal(key)
+		return `,"startkey":` + string(keyjson) + `}`
+	}
+	result := s.mustInvoke("cep", change+`}`)
+	batches, changed := 1, int(result["keys"].(float64))
+	for result["nextkey"] != "" {
+		if batches == 1 {
+			keys := s.theatreKeys("T1")
+			if s.keyEndorsers(keys[0]) != "Org2MSP,Org3MSP,Org4MSP" || s.keyEndorsers(keys[len(keys)-1]) != "Org2MSP,Org3MSP" {
+				t.Errorf("Keys after the first batch :%v", result)
+			}
+			runCases(t, s, []invokeCase{
+				{name: "resumed with other organizations", fn: "cep", arg: `{"thid":"T1","add":["Org5MSP"]` + startKey(result["nextkey"]), code: codeConflict},
+				{name: "resumed from a key of another theatre", fn: "cep", arg: `{"thid":"T1"` + startKey(s.theatreKeys("T2")[0]), code: codeInvalidInput},
+			})
+		}
+		result = s.mustInvoke("cep", change+startKey(result["nextkey"]))
+		batches++
+		changed += int(result["keys"].(float64))
+	}
+	if keys := len(s.theatreKeys("T1")); batches != (keys+1)/2 || changed != keys {
+		t.Errorf("%d keys changed in %d batches, expected %d keys", changed, batches, keys)
+	}
+	checkEndorsers("batches", "T1", "Org2MSP,Org3MSP,Org4MSP")
+	checkEndorsers("other theatre after the batches", "T2", "Org1MSP")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Codes of the error responses. Codes are stable so that the clients can act on them
+const (
+	codeInvalidInput     = "INVALID_INPUT"     // Input is missing, malformed or not valid for the function
+	codeNotFound         = "NOT_FOUND"         // Theatre, show, ticket or other record does not exists
+	codeAlreadyExists    = "ALREADY_EXISTS"    // Record to add is already added
+	codeCapacityExceeded = "CAPACITY_EXCEEDED" // Enough tickets, seats, soda or wins are not available
+	codeUnauthorized     = "UNAUTHORIZED"      // Client is not allowed to invoke the function for the theatre
+	codeConflict         = "CONFLICT"          // Request conflicts with the current state (ex: ticket already redeemed)
+	codeInactive         = "INACTIVE"          // Theatre or screen is deactivated
+	codeCartRejected     = "CART_REJECTED"     // One or more lines of the cart can not be sold. Reasons are in the violations
+	codeLedgerError      = "LEDGER_ERROR"      // Reading or writing the ledger state failed
+)
+
+// codedError is an error with the code of the error response
+type codedError struct {
+	code       string
+	message    string
+	violations []Violation // Fields of the input failing the validation
+}
+
+func (e *codedError) Error() string {
+	return e.message
+}
+
+// newError returns an error with the code of the error response
+func newError(code string, format string, args ...interface{}) error {
+	return &codedError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// errorCode returns the code of the error. Errors without a code are failures to read or write the ledger
+func errorCode(err error) string {
+	if ce, ok := err.(*codedError); ok {
+		return ce.code
+	}
+	return codeLedgerError
+}
+
+// ErrorResponse is the message of the error responses
+type ErrorResponse struct {
+	Code         string      `json:"Code"`
+	Data         string      `json:"Data"`                 // Input or ID the error is about
+	ErrorDetails string      `json:"ErrorDetails"`         // Description of the error
+	Violations   []Violation `json:"Violations,omitempty"` // Fields of the input failing the validation
+}
+
+// errorResponse logs and returns the error response of the function
+func errorResponse(fn string, code string, data string, details string) pb.Response {
+	resp, _ := json.Marshal(ErrorResponse{Code: code, Data: data, ErrorDetails: details})
+	_logger.Error(fn + ":" + string(resp))
+	return shim.Error(string(resp))
+}
+
+// failedResponse logs and returns the error response for an error returned to the function
+func failedResponse(fn string, data string, err error) pb.Response {
+	ce, ok := err.(*codedError)
+	if !ok || len(ce.violations) == 0 {
+		return errorResponse(fn, errorCode(err), data, err.Error())
+	}
+	resp, _ := json.Marshal(ErrorResponse{Code: ce.code, Data: data, ErrorDetails: ce.message, Violations: ce.violations})
+	_logger.Error(fn + ":" + string(resp))
+	return shim.Error(string(resp))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+func TestErrorResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   pb.Response
+		code       string
+		data       string
+		violations int
+	}{
+		{"quotes in the data", errorResponse("test", codeInvalidInput, `{"thid":"T1",`, "Invalid json provided as input"), codeInvalidInput, `{"thid":"T1",`, 0},
+		{"coded error", failedResponse("test", "T1", newError(codeNotFound, "Theatre details does not exists for :%s", "T1")), codeNotFound, "T1", 0},
+		{"error without a code", failedResponse("test", "T1", errors.New("GetState is Failed :\"timeout\"")), codeLedgerError, "T1", 0},
+		{"violations", failedResponse("test", "sell", &codedError{code: codeInvalidInput, message: "Invalid input", violations: []Violation{{Field: "thid", Reason: "Field is required"}}}), codeInvalidInput, "sell", 1},
+	}
+	for _, tt := range tests {
+		if tt.response.Status == shim.OK {
+			t.Errorf("%s: error response has the status 200", tt.name)
+		}
+		er := ErrorResponse{}
+		err := json.Unmarshal([]byte(tt.response.Message), &er)
+		if err != nil {
+			t.Errorf("%s: error response %s is not valid json :%v", tt.name, tt.response.Message, err)
+			continue
+		}
+		if er.Code != tt.code || er.Data != tt.data || er.ErrorDetails == "" || len(er.Violations) != tt.violations {
+			t.Errorf("%s: error response :%+v, expected the code %s for %s", tt.name, er, tt.code, tt.data)
+		}
+	}
+}
+
+func TestInvokeErrors(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	ids := ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`))
+
+	runCases(t, s, []invokeCase{
+		{name: "unknown function", fn: "sellall", arg: `{"thid":"T1"}`, code: codeInvalidInput},
+		{name: "invalid json", fn: "sell", arg: `{"thid":`, code: codeInvalidInput},
+		{name: "sale for an unknown theatre", fn: "sell", arg: `{"thid":"T9","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, code: codeNotFound},
+		{name: "soda of an unknown theatre", fn: "exs", arg: `{"thid":"T9","inventoryid":"ES13","ticketid":"` + ids[0] + `"}`, code: codeNotFound},
+		{name: "soda without the inventory", fn: "exs", arg: `{"thid":"T1","ticketid":"` + ids[0] + `"}`, code: codeInvalidInput},
+		{name: "soda of an unknown ticket", fn: "exs", arg: `{"thid":"T1","inventoryid":"ES13","ticketid":"tx9-1"}`, code: codeNotFound},
+		{name: "over the capacity", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":4,"price":10000}`, code: codeCapacityExceeded},
+		{name: "theatre added again", fn: "athd", arg: `{"thid":"T1","maxsoda":200,"sph":{"SC1":4}}`, code: codeAlreadyExists},
+		{name: "by a client without a role", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, code: codeUnauthorized, role: "none"},
+	})
+
+	// Functions take a single json argument
+	s.args = [][]byte{[]byte("gth"), []byte(`{"thid":"T1"}`), []byte(`{"thid":"T2"}`)}
+	s.MockTransactionStart("args")
+	r := new(ShowsManagement).Invoke(s)
+	s.MockTransactionEnd("args")
+	if code := responseCode(r); code != codeInvalidInput {
+		t.Errorf("Two arguments got the code %q, expected %q. Response :%s", code, codeInvalidInput, r.Message)
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Business day of the tests. Theatres of the tests are in UTC
+const (
+	day0    = int64(1709251200) // 2024-03-01T00:00:00Z
+	date0   = "2024-03-01"
+	date1   = "2024-03-02"
+	testMSP = "Org1MSP"
+)
+
+// attrOID is the certificate extension with the attributes of the user issued by the Fabric CA
+var attrOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+var testKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+// testStub is the mock stub of the tests. MockStub does not have a client identity, transient data, rich queries or
+// key history, so they are provided by the test stub. Transaction timestamp is set by the test
+type testStub struct {
+	*shim.MockStub
+	t         *testing.T
+	creator   []byte
+	transient map[string][]byte
+	now       int64
+	args      [][]byte
+	txn       int
+	history   map[string][]*queryresult.KeyModification
+}
+
+// newTestStub returns a stub with a manager of Org1MSP as the client at the start of the business day
+func newTestStub(t *testing.T) *testStub {
+	s := &testStub{
+		MockStub: shim.NewMockStub("moviecc", new(ShowsManagement)),
+		t:        t,
+		now:      day0,
+		history:  map[string][]*queryresult.KeyModification{},
+	}
+	s.as(testMSP, roleManager)
+	return s
+}
+
+// as sets the client of the next transactions. Role is not issued if empty
+func (s *testStub) as(mspID string, role string) {
+	attrs := map[string]map[string]string{"attrs": {}}
+	if role != "" {
+		attrs["attrs"][roleAttribute] = role
+	}
+	attrjson, _ := json.Marshal(attrs)
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: role + "@" + mspID},
+		NotBefore:       time.Unix(0, 0),
+		NotAfter:        time.Unix(day0+100*24*60*60, 0),
+		ExtraExtensions: []pkix.Extension{{Id: attrOID, Value: attrjson}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &testKey.PublicKey, testKey)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	s.creator, _ = proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: certPEM})
+}
+
+// invoke runs the function as a transaction of the current client
+func (s *testStub) invoke(fn string, arg string) pb.Response {
+	s.txn++
+	txid := "tx" + strconv.Itoa(s.txn)
+	s.args = [][]byte{[]byte(fn), []byte(arg)}
+	s.MockTransactionStart(txid)
+	defer s.MockTransactionEnd(txid)
+	return new(ShowsManagement).Invoke(s)
+}
+
+// init runs the instantiation of the chaincode with the argument as the current client
+func (s *testStub) init(arg string) pb.Response {
+	s.txn++
+	txid := "tx" + strconv.Itoa(s.txn)
+	s.args = [][]byte{[]byte("init"), []byte(arg)}
+	s.MockTransactionStart(txid)
+	defer s.MockTransactionEnd(txid)
+	return new(ShowsManagement).Init(s)
+}
+
+func (s *testStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *testStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.now}, nil
+}
+
+func (s *testStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *testStub) GetStringArgs() []string {
+	args := []string{}
+	for _, arg := range s.args {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	return args[0], args[1:]
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	err := s.MockStub.PutState(key, value)
+	if err == nil {
+		s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, Value: value, Timestamp: &timestamp.Timestamp{Seconds: s.now}})
+	}
+	return err
+}
+
+func (s *testStub) DelState(key string) error {
+	err := s.MockStub.DelState(key)
+	if err == nil {
+		s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, IsDelete: true, Timestamp: &timestamp.Timestamp{Seconds: s.now}})
+	}
+	return err
+}
+
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{mods: s.history[key]}, nil
+}
+
+func (s *testStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	records, err := s.query(query)
+	if err != nil {
+		return nil, err
+	}
+	return &recordIterator{records: records}, nil
+}
+
+func (s *testStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	records, err := s.query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	start, _ := strconv.Atoi(bookmark)
+	if start > len(records) {
+		start = len(records)
+	}
+	end := start + int(pageSize)
+	if end > len(records) {
+		end = len(records)
+	}
+	page := records[start:end]
+	return &recordIterator{records: page}, &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(page)), Bookmark: strconv.Itoa(end)}, nil
+}
+
+// query serves the CouchDB selectors used by the chaincode from the mock state, in the order of the keys
+func (s *testStub) query(query string) ([]*queryresult.KV, error) {
+	var q struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	err := json.Unmarshal([]byte(query), &q)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for key := range s.State {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	records := []*queryresult.KV{}
+	for _, key := range keys {
+		doc := map[string]interface{}{}
+		if json.Unmarshal(s.State[key], &doc) == nil && matches(doc, q.Selector) {
+			records = append(records, &queryresult.KV{Key: key, Value: s.State[key]})
+		}
+	}
+	return records, nil
+}
+
+// matches checks the document against the selector
+func matches(doc map[string]interface{}, selector map[string]interface{}) bool {
+	for field, condition := range selector {
+		value, present := doc[field]
+		operators, ok := condition.(map[string]interface{})
+		if !ok {
+			operators = map[string]interface{}{"$eq": condition}
+		}
+		for op, want := range operators {
+			if !present || !matchesOperator(op, value, want) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesOperator(op string, value interface{}, want interface{}) bool {
+	switch op {
+	case "$eq":
+		return compareValues(value, want) == 0
+	case "$ne":
+		return compareValues(value, want) != 0
+	case "$gt":
+		return compareValues(value, want) > 0
+	case "$gte":
+		return compareValues(value, want) >= 0
+	case "$lt":
+		return compareValues(value, want) < 0
+	case "$lte":
+		return compareValues(value, want) <= 0
+	case "$in":
+		for _, w := range want.([]interface{}) {
+			if compareValues(value, w) == 0 {
+				return true
+			}
+		}
+		return false
+	case "$elemMatch":
+		elems, _ := value.([]interface{})
+		for _, elem := range elems {
+			if doc, ok := elem.(map[string]interface{}); ok && matches(doc, want.(map[string]interface{})) {
+				return true
+			}
+		}
+		return false
+	}
+	panic("operator not supported by the test stub: " + op)
+}
+
+func compareValues(a interface{}, b interface{}) int {
+	fa, aok := a.(float64)
+	fb, bok := b.(float64)
+	if aok && bok {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+type recordIterator struct {
+	records []*queryresult.KV
+}
+
+func (it *recordIterator) HasNext() bool {
+	return len(it.records) > 0
+}
+
+func (it *recordIterator) Next() (*queryresult.KV, error) {
+	record := it.records[0]
+	it.records = it.records[1:]
+	return record, nil
+}
+
+func (it *recordIterator) Close() error {
+	return nil
+}
+
+type historyIterator struct {
+	mods []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool {
+	return len(it.mods) > 0
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	mod := it.mods[0]
+	it.mods = it.mods[1:]
+	return mod, nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
+
+// invokeCase is a transaction of a table-driven test. Cases of a table run in order on the same ledger
+type invokeCase struct {
+	name string
+	fn   string
+	arg  string
+	code string // Code of the error response. Success if empty
+	at   int64  // Transaction timestamp. Timestamp of the previous case if not set
+	role string // Role of the client. Manager if not set
+	msp  string // Organization of the client. Org1MSP if not set
+}
+
+// runCases runs the cases in order and checks the code of each response. Manager of Org1MSP is the client after the
+// cases
+func runCases(t *testing.T, s *testStub, cases []invokeCase) {
+	t.Helper()
+	defer s.as(testMSP, roleManager)
+	for _, c := range cases {
+		if c.at != 0 {
+			s.now = c.at
+		}
+		mspID, role := c.msp, c.role
+		if mspID == "" {
+			mspID = testMSP
+		}
+		if role == "" {
+			role = roleManager
+		}
+		s.as(mspID, role)
+		r := s.invoke(c.fn, c.arg)
+		if got := responseCode(r); got != c.code {
+			t.Errorf("%s: %s got code %q, expected %q. Response :%s%s", c.name, c.fn, got, c.code, r.Message, r.Payload)
+		}
+	}
+}
+
+// responseCode returns the code of the error response. Empty for a success
+func responseCode(r pb.Response) string {
+	if r.Status == shim.OK {
+		return ""
+	}
+	var er ErrorResponse
+	if json.Unmarshal([]byte(r.Message), &er) != nil {
+		return "UNPARSED"
+	}
+	return er.Code
+}
+
+// mustInvoke runs the function and returns the payload of the successful response
+func (s *testStub) mustInvoke(fn string, arg string) map[string]interface{} {
+	s.t.Helper()
+	r := s.invoke(fn, arg)
+	if r.Status != shim.OK {
+		s.t.Fatalf("%s %s failed :%s", fn, arg, r.Message)
+	}
+	result := map[string]interface{}{}
+	json.Unmarshal(r.Payload, &result)
+	return result
+}
+
+// result runs the query and decodes the payload of the successful response
+func (s *testStub) result(fn string, arg string, v interface{}) {
+	s.t.Helper()
+	r := s.invoke(fn, arg)
+	if r.Status != shim.OK {
+		s.t.Fatalf("%s %s failed :%s", fn, arg, r.Message)
+	}
+	if err := json.Unmarshal(r.Payload, v); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// record reads the record saved under the composite key
+func (s *testStub) record(v interface{}, objType string, ids ...string) bool {
+	s.t.Helper()
+	key, _ := s.CreateCompositeKey(objType, ids)
+	value, found := s.State[key]
+	if !found {
+		return false
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		s.t.Fatal(err)
+	}
+	return true
+}
+
+// theatreRecords reads the records saved under the partial composite key
+func (s *testStub) theatreRecords(objType string, ids ...string) []*queryresult.KV {
+	s.t.Helper()
+	resultsIterator, err := s.GetStateByPartialCompositeKey(objType, ids)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resultsIterator.Close()
+	var records []*queryresult.KV
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// putLegacy saves the record as saved by the earlier versions of the chaincode, without the stamps of the client
+func (s *testStub) putLegacy(key string, value string) {
+	s.MockTransactionStart("legacy")
+	defer s.MockTransactionEnd("legacy")
+	if err := s.MockStub.PutState(key, []byte(value)); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// addTheatre adds the theatre with a screen SC1 of 4 seats and shows "1" and "2" of Lucy on SC1 for the business
+// day, starting 10 and 20 hours after the start of the day
+func (s *testStub) addTheatre(thid string, extra string) {
+	s.t.Helper()
+	s.mustInvoke("athd", `{"thid":"`+thid+`","maxsoda":200,"sph":{"SC1":4}`+extra+`}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"`+thid+`","showdate":"`+date0+`","shows":[{"showcode":"1","start":`+
+		strconv.FormatInt(day0+10*3600, 10)+`},{"showcode":"2","start":`+strconv.FormatInt(day0+20*3600, 10)+`}]}`)
+}
+
+// testSeed is the seed of the soda draws of the tests
+var testSeed = []byte("0123456789abcdef")
+
+// startSoda saves the soda promotion of the theatre along with the seed of the soda draws
+func (s *testStub) startSoda(thid string, winPercent int) {
+	s.t.Helper()
+	s.transient = map[string][]byte{sodaSeedKey: testSeed}
+	defer func() { s.transient = nil }()
+	s.mustInvoke("asp", `{"thid":"`+thid+`","enabled":true,"winpercent":`+strconv.Itoa(winPercent)+`}`)
+}
+
+// ticketIDs returns the ticket IDs of a sale. Tickets of the seats sold are returned with the seats
+func ticketIDs(result map[string]interface{}) []string {
+	ids := []string{}
+	tickets, _ := result["tickets"].([]interface{})
+	for _, id := range tickets {
+		ids = append(ids, id.(string))
+	}
+	seats, _ := result["seats"].([]interface{})
+	for _, seat := range seats {
+		ids = append(ids, seat.(map[string]interface{})["ticketid"].(string))
+	}
+	return ids
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// historyRules lists the object types with the key history available. Value is true when only the organization
+// owning the theatre can query the history
+var historyRules = map[string]bool{
+	"TheatreDetails": false,
+	"ShowDetails":    false,
+	"Tickets":        false,
+	"Ticket":         true,
+	"SodaInventory":  false,
+	"PriceList":      false,
+	"Coupon":         false,
+	"TaxConfig":      false,
+}
+
+// KeyVersion is a version of a key as recorded on the ledger
+type KeyVersion struct {
+	TxID        string          `json:"txid"`
+	Timestamp   string          `json:"timestamp"` // RFC3339. Timestamp of the transaction
+	IsDelete    bool            `json:"isdelete"`  // Key is deleted by the transaction
+	ModifiedBy  string          `json:"modby"`     // Client identity submitting the transaction. Empty for the deleted keys
+	ModifiedMSP string          `json:"modmsp"`    // Organization of the client submitting the transaction
+	Value       json.RawMessage `json:"value"`     // Record saved by the transaction
+}
+
+// writerStamp is the client identity saved with every record
+type writerStamp struct {
+	ModifiedBy  string `json:"modby"`
+	ModifiedMSP string `json:"modmsp"`
+}
+
+// Get the versions of a theatre, show, tickets, ticket or soda inventory record from the oldest to the latest.
+// Record is identified by the object type and the IDs of its key as in the record
+func (s *ShowsManagement) getKeyHistory(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("getKeyHistory", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var ref recordRef
+	err := json.Unmarshal([]byte(args[0]), &ref)
+	if err != nil {
+		return errorResponse("getKeyHistory", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	ownerOnly, found := historyRules[ref.ObjType]
+	ids, valid := ref.ids()
+	if !found || !valid {
+		return errorResponse("getKeyHistory", codeInvalidInput, ref.ObjType, "History is not available for the object type")
+	}
+	for _, id := range ids {
+		if id == "" {
+			return errorResponse("getKeyHistory", codeInvalidInput, ref.ObjType, "All the IDs of the record are required")
+		}
+	}
+	if ownerOnly {
+		err = checkOwnerOrg(stub, ref.TheatreID)
+		if err != nil {
+			return failedResponse("getKeyHistory", ref.TheatreID, err)
+		}
+	}
+
+	key, err := stateKey(stub, ref.ObjType, ids...)
+	if err != nil {
+		return failedResponse("getKeyHistory", ref.TheatreID, err)
+	}
+
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		_logger.Errorf("getKeyHistory:GetHistoryForKey is Failed :" + string(err.Error()))
+		return errorResponse("getKeyHistory", errorCode(err), ref.TheatreID, "Unable to get the history")
+	}
+	defer resultsIterator.Close()
+
+	versions := []KeyVersion{}
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			_logger.Errorf("getKeyHistory:History iteration is Failed :" + string(err.Error()))
+			return errorResponse("getKeyHistory", errorCode(err), ref.TheatreID, "Unable to get the history")
+		}
+		version := KeyVersion{
+			TxID:     modification.TxId,
+			IsDelete: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			ts := time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos))
+			version.Timestamp = ts.UTC().Format(time.RFC3339)
+		}
+		// Records saved before the client identity was recorded do not have the writer
+		if !modification.IsDelete && len(modification.Value) > 0 {
+			version.Value = json.RawMessage(modification.Value)
+			var stamp writerStamp
+			if json.Unmarshal(modification.Value, &stamp) == nil {
+				version.ModifiedBy = stamp.ModifiedBy
+				version.ModifiedMSP = stamp.ModifiedMSP
+			}
+		}
+		versions = append(versions, version)
+	}
+
+	result := map[string]interface{}{
+		"status":   "true",
+		"key":      ids,
+		"obj":      ref.ObjType,
+		"versions": versions,
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+)
+
+// keyHistory returns the versions of the record from the "hist" API
+func (s *testStub) keyHistory(ref string) []KeyVersion {
+	s.t.Helper()
+	var result struct {
+		Versions []KeyVersion `json:"versions"`
+	}
+	s.result("hist", ref, &result)
+	return result.Versions
+}
+
+func TestKeyHistory(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	ids := ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`))
+	s.as(testMSP, roleCashier)
+	s.now = day0 + 3600
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`)
+	s.as(testMSP, roleManager)
+	s.now = day0 + 24*3600
+	s.mustInvoke("rst", `{"thid":"T1"}`)
+
+	tickets := `{"obj":"Tickets","thid":"T1","screen":"SC1","showdate":"` + date0 + `","showcode":"1"}`
+	runCases(t, s, []invokeCase{
+		{name: "show details", fn: "hist", arg: `{"obj":"ShowDetails","thid":"T1","screen":"SC1","showdate":"` + date0 + `"}`, role: "none"},
+		{name: "tickets of another organization", fn: "hist", arg: tickets, msp: "Org2MSP"},
+		{name: "ticket", fn: "hist", arg: `{"obj":"Ticket","thid":"T1","ticketid":"` + ids[0] + `"}`, role: roleCashier},
+		{name: "ticket of another organization", fn: "hist", arg: `{"obj":"Ticket","thid":"T1","ticketid":"` + ids[0] + `"}`, code: codeUnauthorized, msp: "Org2MSP"},
+		{name: "invalid json", fn: "hist", arg: `{"obj":`, code: codeInvalidInput},
+		{name: "object type without the history", fn: "hist", arg: `{"obj":"SodaDraw","thid":"T1","drawid":"tx1"}`, code: codeInvalidInput},
+		{name: "unknown object type", fn: "hist", arg: `{"obj":"Screen","thid":"T1"}`, code: codeInvalidInput},
+		{name: "missing ID", fn: "hist", arg: `{"obj":"Tickets","thid":"T1","screen":"SC1","showcode":"1"}`, code: codeInvalidInput},
+		{name: "list of showcodes", fn: "hist", arg: `{"obj":"Tickets","thid":"T1","screen":"SC1","showdate":"` + date0 + `","showcode":["1"]}`, code: codeInvalidInput},
+		{name: "without the theatre", fn: "hist", arg: `{"obj":"TheatreDetails"}`, code: codeInvalidInput},
+	})
+
+	versions := s.keyHistory(tickets)
+	if len(versions) != 3 {
+		t.Fatalf("Versions of the tickets :%+v, expected 2 sales and the reset", versions)
+	}
+	manager, cashier, reset := versions[0], versions[1], versions[2]
+	if manager.TxID != "tx3" || manager.Timestamp != "2024-03-01T00:00:00Z" || manager.ModifiedMSP != testMSP || manager.ModifiedBy == "" {
+		t.Errorf("Version of the first sale :%+v", manager)
+	}
+	if cashier.Timestamp != "2024-03-01T01:00:00Z" || cashier.ModifiedMSP != testMSP || cashier.ModifiedBy == manager.ModifiedBy {
+		t.Errorf("Version of the sale by the cashier :%+v, expected another client than %s", cashier, manager.ModifiedBy)
+	}
+	sold := Tickets{}
+	if json.Unmarshal(cashier.Value, &sold) != nil || sold.TicketsSold != 2 {
+		t.Errorf("Tickets saved by the cashier :%s, expected 2 tickets sold", cashier.Value)
+	}
+	if !reset.IsDelete || reset.ModifiedBy != "" || reset.Timestamp != "2024-03-02T00:00:00Z" {
+		t.Errorf("Version of the reset :%+v, expected a delete", reset)
+	}
+}
+
+func TestKeyHistoryOfLegacyRecords(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+
+	// Records saved before the client identity was recorded have no writer in the history
+	key, _ := s.CreateCompositeKey("TheatreDetails", []string{"T1"})
+	legacy := `{"docType":"TheatreDetails","thid":"T1","maxsoda":200}`
+	s.history[key] = append([]*queryresult.KeyModification{{TxId: "legacy", Value: []byte(legacy), Timestamp: &timestamp.Timestamp{Seconds: day0 - 3600}}}, s.history[key]...)
+
+	versions := s.keyHistory(`{"obj":"TheatreDetails","thid":"T1"}`)
+	if len(versions) != 2 {
+		t.Fatalf("Versions of the theatre :%+v, expected the legacy and the new record", versions)
+	}
+	if versions[0].TxID != "legacy" || versions[0].ModifiedBy != "" || versions[0].ModifiedMSP != "" {
+		t.Errorf("Version of the legacy record :%+v, expected no writer", versions[0])
+	}
+	if string(versions[0].Value) != legacy {
+		t.Errorf("Legacy record in the history :%s, expected the record as saved", versions[0].Value)
+	}
+	if versions[1].ModifiedMSP != testMSP {
+		t.Errorf("Version of the theatre setup :%+v", versions[1])
+	}
+}
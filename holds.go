@@ -0,0 +1,487 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const defaultHoldSeconds = 300
+
+// maxHoldSeconds is the longest a hold on tickets can be valid
+const maxHoldSeconds = 24 * 60 * 60
+
+// SeatHold is a time-limited hold on tickets of a show while the customer is paying
+type SeatHold struct {
+	HoldID    string   `json:"holdid"`    // Transaction ID of the hold
+	MovieName string   `json:"moviename"` //
+	Count     uint32   `json:"count"`     // Number of tickets on hold
+	Seats     []string `json:"seats"`     // Seats on hold. Empty if tickets are held without seats
+	ExpiresAt int64    `json:"expiresat"` // epoch format. Transaction timestamp of the hold + hold duration of the theatre
+	TicketSale
+}
+
+// ShowHolds keeps track of the holds on a show.
+type ShowHolds struct {
+	ObjType   string              `json:"obj"`
+	TheatreID string              `json:"thid"`     // Alphanumeric
+	Screen    string              `json:"screen"`   // Alphanumeric
+	ShowDate  string              `json:"showdate"` // YYYY-MM-DD
+	ShowCode  string              `json:"showcode"` //
+	Holds     map[string]SeatHold `json:"holds"`    // HoldID -> hold
+	CreateTs  string              `json:"cts"`      // RFC3339. Transaction timestamp of the first save
+	UpdateTs  string              `json:"uts"`      // RFC3339. Transaction timestamp of the latest save
+}
+
+// HoldRequest is the input to hold, confirm or release tickets of a show
+type HoldRequest struct {
+	TheatreID string   `json:"thid"`      // Alphanumeric
+	MovieName string   `json:"moviename"` //
+	Screen    string   `json:"screen"`    // Alphanumeric
+	ShowDate  string   `json:"showdate"`  // YYYY-MM-DD. Defaults to the current date of the theatre
+	ShowCode  string   `json:"showcode"`  //
+	HoldID    string   `json:"holdid"`    // Required to confirm or release a hold
+	Count     uint32   `json:"count"`     // Tickets to hold without seats
+	Seats     []string `json:"seats"`     // Seats to hold
+	TicketSale
+}
+
+// liveCount returns the number of tickets on holds which are not expired at the given time
+func (sh ShowHolds) liveCount(now int64) int {
+	count := 0
+	for _, hold := range sh.Holds {
+		if hold.ExpiresAt > now {
+			count += int(hold.Count)
+		}
+	}
+	return count
+}
+
+// liveSeats returns the seats on holds which are not expired at the given time
+func (sh ShowHolds) liveSeats(now int64) map[string]bool {
+	seats := make(map[string]bool)
+	for _, hold := range sh.Holds {
+		if hold.ExpiresAt > now {
+			for _, seat := range hold.Seats {
+				seats[seat] = true
+			}
+		}
+	}
+	return seats
+}
+
+// getShowHolds fetches the holds on a show. Returns an empty record if there are no holds on the show
+func getShowHolds(stub shim.ChaincodeStubInterface, thid string, sc string, dt string, st string) (ShowHolds, error) {
+	holds := ShowHolds{ObjType: "ShowHolds", TheatreID: thid, Screen: sc, ShowDate: dt, ShowCode: st}
+	holdDetails, err := getRecord(stub, "ShowHolds", thid, sc, dt, st)
+	if err != nil {
+		return holds, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if holdDetails != nil {
+		err = json.Unmarshal(holdDetails, &holds)
+		if err != nil {
+			return holds, fmt.Errorf("Existing hold details Unmarshalling error")
+		}
+	}
+	if holds.Holds == nil {
+		holds.Holds = make(map[string]SeatHold)
+	}
+	return holds, nil
+}
+
+// putShowHolds saves the holds on a show
+func putShowHolds(stub shim.ChaincodeStubInterface, td TheatreDetails, holds ShowHolds) error {
+	holds.ObjType = "ShowHolds"
+	holdsjson, _ := json.Marshal(holds)
+	return putRecord(stub, td, holdsjson, "ShowHolds", holds.TheatreID, holds.Screen, holds.ShowDate, holds.ShowCode)
+}
+
+// Hold tickets or seats of a show without selling them. The hold expires after the hold duration of the theatre
+func (s *ShowsManagement) holdTickets(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("holdTickets", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var req HoldRequest
+	err := json.Unmarshal([]byte(args[0]), &req)
+	if err != nil {
+		return errorResponse("holdTickets", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := req.TheatreID
+	sc := req.Screen
+	st := req.ShowCode
+
+	dt, err := resolveShowDate(stub, thid, req.ShowDate)
+	if err != nil {
+		return failedResponse("holdTickets", thid, err)
+	}
+
+	// Either the ticket count or the seats to hold must be provided
+	if (req.Count == 0) == (len(req.Seats) == 0) {
+		return errorResponse("holdTickets", codeInvalidInput, strconv.Itoa(int(req.Count)), "Invalid request to hold tickets. Expected either ticket count or seats")
+	}
+	count := int(req.Count)
+	if len(req.Seats) > 0 {
+		count = len(req.Seats)
+	}
+
+	// Check if the show details are available
+	showDetails, err := getRecord(stub, "ShowDetails", thid, sc, dt)
+	if err != nil {
+		return errorResponse("holdTickets", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+	if showDetails == nil {
+		return errorResponse("holdTickets", codeNotFound, thid, "Show details does not exists for the given details")
+	}
+
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("holdTickets", thid, err)
+	}
+	err = td.salesOpen(sc)
+	if err != nil {
+		return failedResponse("holdTickets", thid, err)
+	}
+
+	holds, err := getShowHolds(stub, thid, sc, dt, st)
+	if err != nil {
+		return failedResponse("holdTickets", thid, err)
+	}
+
+	tkt, err := getTickets(stub, thid, sc, dt, st)
+	if err != nil {
+		return failedResponse("holdTickets", thid, err)
+	}
+	sold := 0
+	if tkt != nil {
+		sold = int(tkt.TicketsSold)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("holdTickets:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("holdTickets", errorCode(err), "", "Unable to hold the tickets")
+	}
+
+	if !withinCapacity(td.SeatsPerHall[sc], sold, holds.liveCount(now), count) {
+		return errorResponse("holdTickets", codeCapacityExceeded, "", "Enough tickets not available")
+	}
+
+	// Seats to hold must be on the seat map and neither booked nor on a live hold
+	if len(req.Seats) > 0 {
+		sm, err := getSeatMap(stub, thid, sc)
+		if err == nil && sm == nil {
+			err = newError(codeNotFound, "Seat map does not exists for the screen")
+		}
+		if err != nil {
+			return failedResponse("holdTickets", sc, err)
+		}
+		showSeats, err := getShowSeats(stub, thid, sc, dt, st)
+		if err != nil {
+			return failedResponse("holdTickets", thid, err)
+		}
+
+		categories := sm.seatCategories()
+		heldSeats := holds.liveSeats(now)
+		for _, seat := range req.Seats {
+			_, booked := showSeats.Booked[seat]
+			if _, found := categories[seat]; !found || booked || heldSeats[seat] {
+				return errorResponse("holdTickets", codeNotFound, seat, "Seat does not exists or not available for the show")
+			}
+			heldSeats[seat] = true
+		}
+	}
+
+	holdSeconds := td.HoldSeconds
+	if holdSeconds <= 0 {
+		holdSeconds = defaultHoldSeconds
+	}
+	hold := SeatHold{
+		HoldID:     stub.GetTxID(),
+		MovieName:  req.MovieName,
+		Count:      uint32(count),
+		Seats:      req.Seats,
+		ExpiresAt:  now + holdSeconds,
+		TicketSale: req.TicketSale,
+	}
+	holds.Holds[hold.HoldID] = hold
+
+	err = putShowHolds(stub, *td, holds)
+	if err != nil {
+		_logger.Errorf("holdTickets:PutState is Failed :" + string(err.Error()))
+		return errorResponse("holdTickets", errorCode(err), thid, "Unable to hold the tickets")
+	}
+	_logger.Infof("holdTickets:Tickets held successfully")
+
+	result := map[string]interface{}{
+		"trxnid":    stub.GetTxID(),
+		"holdid":    hold.HoldID,
+		"expiresat": hold.ExpiresAt,
+		"message":   "Hold tickets successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Confirm a live hold. The held tickets and seats are sold to the customer
+func (s *ShowsManagement) confirmHold(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("confirmHold", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var req HoldRequest
+	err := json.Unmarshal([]byte(args[0]), &req)
+	if err != nil {
+		return errorResponse("confirmHold", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := req.TheatreID
+	sc := req.Screen
+	st := req.ShowCode
+
+	dt, err := resolveShowDate(stub, thid, req.ShowDate)
+	if err != nil {
+		return failedResponse("confirmHold", thid, err)
+	}
+
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err == nil {
+		err = td.salesOpen(sc)
+	}
+	if err != nil {
+		return failedResponse("confirmHold", thid, err)
+	}
+
+	holds, err := getShowHolds(stub, thid, sc, dt, st)
+	if err != nil {
+		return failedResponse("confirmHold", thid, err)
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("confirmHold:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("confirmHold", errorCode(err), "", "Unable to confirm the hold")
+	}
+
+	hold, found := holds.Holds[req.HoldID]
+	if !found {
+		return errorResponse("confirmHold", codeNotFound, req.HoldID, "Hold does not exists for the show")
+	}
+	if hold.ExpiresAt <= now {
+		return errorResponse("confirmHold", codeConflict, req.HoldID, "Hold is expired")
+	}
+
+	// Held tickets are priced from the price list of the screen at the time of the confirmation
+	unseated := int(hold.Count) - len(hold.Seats)
+	quote, err := quoteSale(stub, thid, sc, dt, st, unseated, hold.Seats, hold.Price)
+	if err != nil {
+		return failedResponse("confirmHold", thid, err)
+	}
+	coupon, use, err := applyCoupon(stub, thid, sc, dt, quote, hold.TicketSale)
+	if err != nil {
+		return failedResponse("confirmHold", hold.Coupon, err)
+	}
+
+	// Held tickets were counted against the screen capacity, so no capacity check is required
+	tkt, err := getTickets(stub, thid, sc, dt, st)
+	if err != nil {
+		return failedResponse("confirmHold", thid, err)
+	}
+	if tkt == nil {
+		tkt = &Tickets{TheatreID: thid, MovieName: hold.MovieName, Screen: sc, ShowDate: dt, ShowCode: st}
+	}
+	tkt.ObjType = "Tickets"
+	err = tkt.addSold(hold.Count)
+	if err != nil {
+		return failedResponse("confirmHold", thid, err)
+	}
+
+	if len(hold.Seats) > 0 {
+		showSeats, err := getShowSeats(stub, thid, sc, dt, st)
+		if err != nil {
+			return failedResponse("confirmHold", thid, err)
+		}
+		for n, seat := range hold.Seats {
+			showSeats.Booked[seat] = ticketID(stub.GetTxID(), n+1)
+		}
+		err = putShowSeats(stub, *td, showSeats)
+		if err != nil {
+			_logger.Errorf("confirmHold:PutState is Failed :" + string(err.Error()))
+			return errorResponse("confirmHold", errorCode(err), thid, "Unable to confirm the hold")
+		}
+	}
+
+	tktjson, _ := json.Marshal(tkt)
+	err = putRecord(stub, *td, tktjson, "Tickets", thid, sc, dt, st)
+	if err != nil {
+		_logger.Errorf("confirmHold:PutState is Failed :" + string(err.Error()))
+		return errorResponse("confirmHold", errorCode(err), thid, "Unable to confirm the hold")
+	}
+
+	var invoice *Invoice
+	tickets, err := issueTickets(stub, *td, *tkt, *quote, hold.Customer, 0)
+	if err == nil {
+		err = useCoupon(stub, *td, coupon, use)
+	}
+	if err == nil {
+		invoice, err = issueInvoice(stub, *td, hold.Customer, ticketLines(*quote, tickets))
+	}
+	if err != nil {
+		_logger.Errorf("confirmHold:PutState is Failed :" + string(err.Error()))
+		return errorResponse("confirmHold", errorCode(err), thid, "Unable to issue the tickets")
+	}
+
+	delete(holds.Holds, req.HoldID)
+	err = putShowHolds(stub, *td, holds)
+	if err != nil {
+		_logger.Errorf("confirmHold:PutState is Failed :" + string(err.Error()))
+		return errorResponse("confirmHold", errorCode(err), thid, "Unable to confirm the hold")
+	}
+	_logger.Infof("confirmHold:Hold confirmed successfully")
+
+	result := map[string]interface{}{
+		"trxnid":     stub.GetTxID(),
+		"holdid":     req.HoldID,
+		"ticketSold": hold.Count,
+		"seats":      hold.Seats,
+		"tickets":    tickets,
+		"format":     quote.Format,
+		"discount":   quote.Discount,
+		"amount":     quote.Total,
+		"invoiceno":  invoice.InvoiceNo,
+		"tax":        invoice.Tax,
+		"total":      invoice.Total,
+		"message":    "Confirm hold successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Release a hold before it expires. The held tickets and seats are available for sale again
+func (s *ShowsManagement) releaseHold(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("releaseHold", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var req HoldRequest
+	err := json.Unmarshal([]byte(args[0]), &req)
+	if err != nil {
+		return errorResponse("releaseHold", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := req.TheatreID
+
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	var dt string
+	if err == nil {
+		dt, err = showDateOrToday(stub, *td, req.ShowDate)
+	}
+	if err != nil {
+		return failedResponse("releaseHold", thid, err)
+	}
+
+	holds, err := getShowHolds(stub, thid, req.Screen, dt, req.ShowCode)
+	if err != nil {
+		return failedResponse("releaseHold", req.TheatreID, err)
+	}
+	if _, found := holds.Holds[req.HoldID]; !found {
+		return errorResponse("releaseHold", codeNotFound, req.HoldID, "Hold does not exists for the show")
+	}
+
+	delete(holds.Holds, req.HoldID)
+	err = putShowHolds(stub, *td, holds)
+	if err != nil {
+		_logger.Errorf("releaseHold:PutState is Failed :" + string(err.Error()))
+		return errorResponse("releaseHold", errorCode(err), req.TheatreID, "Unable to release the hold")
+	}
+	_logger.Infof("releaseHold:Hold released successfully")
+
+	result := map[string]interface{}{
+		"trxnid":  stub.GetTxID(),
+		"holdid":  req.HoldID,
+		"message": "Release hold successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Release all the expired holds on a show
+func (s *ShowsManagement) sweepHolds(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("sweepHolds", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var req HoldRequest
+	err := json.Unmarshal([]byte(args[0]), &req)
+	if err != nil {
+		return errorResponse("sweepHolds", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := req.TheatreID
+
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	var dt string
+	if err == nil {
+		dt, err = showDateOrToday(stub, *td, req.ShowDate)
+	}
+	if err != nil {
+		return failedResponse("sweepHolds", thid, err)
+	}
+
+	holds, err := getShowHolds(stub, thid, req.Screen, dt, req.ShowCode)
+	if err != nil {
+		return failedResponse("sweepHolds", req.TheatreID, err)
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("sweepHolds:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("sweepHolds", errorCode(err), "", "Unable to release the holds")
+	}
+
+	released := []string{}
+	for holdID, hold := range holds.Holds {
+		if hold.ExpiresAt <= now {
+			released = append(released, holdID)
+			delete(holds.Holds, holdID)
+		}
+	}
+	sort.Strings(released)
+
+	// Nothing to write if there are no expired holds
+	if len(released) > 0 {
+		err = putShowHolds(stub, *td, holds)
+		if err != nil {
+			_logger.Errorf("sweepHolds:PutState is Failed :" + string(err.Error()))
+			return errorResponse("sweepHolds", errorCode(err), req.TheatreID, "Unable to release the holds")
+		}
+	}
+	_logger.Infof("sweepHolds:" + strconv.Itoa(len(released)) + " expired holds released")
+
+	result := map[string]interface{}{
+		"trxnid":   stub.GetTxID(),
+		"released": released,
+		"message":  "Release expired holds successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestHolds(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", `,"holdsecs":60`)
+	s.mustInvoke("asm", `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":2,"category":"Silver"},{"row":"B","seats":2,"category":"Gold"}]}`)
+	seatHold := s.mustInvoke("hold", `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1","A2"],"price":10000}`)["holdid"].(string)
+	countHold := s.mustInvoke("hold", `{"thid":"T1","screen":"SC1","showcode":"1","count":1,"price":10000}`)["holdid"].(string)
+
+	runCases(t, s, []invokeCase{
+		{name: "held seat", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"],"price":10000}`, code: codeConflict, at: day0 + 10},
+		{name: "tickets over the held capacity", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000}`, code: codeCapacityExceeded},
+		{name: "both count and seats", fn: "hold", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":1,"seats":["B1"]}`, code: codeInvalidInput},
+		{name: "held seat again", fn: "hold", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A2"]}`, code: codeNotFound},
+		{name: "hold over the capacity", fn: "hold", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":2}`, code: codeCapacityExceeded},
+		{name: "unknown hold", fn: "chold", arg: `{"thid":"T1","screen":"SC1","showcode":"1","holdid":"tx0"}`, code: codeNotFound},
+		{name: "seat hold", fn: "chold", arg: `{"thid":"T1","screen":"SC1","showcode":"1","holdid":"` + seatHold + `"}`, at: day0 + 20},
+		{name: "seat hold again", fn: "chold", arg: `{"thid":"T1","screen":"SC1","showcode":"1","holdid":"` + seatHold + `"}`, code: codeNotFound},
+		{name: "sold seat", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"],"price":10000}`, code: codeConflict},
+		{name: "tickets before the count hold expires", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000}`, code: codeCapacityExceeded, at: day0 + 59},
+		{name: "expired hold", fn: "chold", arg: `{"thid":"T1","screen":"SC1","showcode":"1","holdid":"` + countHold + `"}`, code: codeConflict, at: day0 + 60},
+		{name: "tickets after the count hold expires", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000}`},
+		{name: "sweep by a cashier", fn: "sweep", arg: `{"thid":"T1","screen":"SC1","showcode":"1"}`, role: roleCashier},
+		{name: "swept hold", fn: "rhold", arg: `{"thid":"T1","screen":"SC1","showcode":"1","holdid":"` + countHold + `"}`, code: codeNotFound},
+		{name: "hold of an unknown show", fn: "hold", arg: `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"1","count":1}`, code: codeInvalidInput},
+	})
+
+	tkt := Tickets{}
+	s.record(&tkt, "Tickets", "T1", "SC1", date0, "1")
+	if tkt.TicketsSold != 4 {
+		t.Errorf("Tickets sold :%d, expected 4", tkt.TicketsSold)
+	}
+	holds := ShowHolds{}
+	s.record(&holds, "ShowHolds", "T1", "SC1", date0, "1")
+	if len(holds.Holds) != 0 {
+		t.Errorf("Holds left after the sweep :%v", holds.Holds)
+	}
+}
+
+func TestReleaseHold(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	holdID := s.mustInvoke("hold", `{"thid":"T1","screen":"SC1","showcode":"2","count":4,"price":10000}`)["holdid"].(string)
+
+	runCases(t, s, []invokeCase{
+		{name: "tickets of a fully held show", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":1,"price":10000}`, code: codeCapacityExceeded},
+		{name: "release", fn: "rhold", arg: `{"thid":"T1","screen":"SC1","showcode":"2","holdid":"` + holdID + `"}`, role: roleCashier},
+		{name: "released hold", fn: "rhold", arg: `{"thid":"T1","screen":"SC1","showcode":"2","holdid":"` + holdID + `"}`, code: codeNotFound},
+		{name: "tickets after the release", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":4,"price":10000}`},
+	})
+}
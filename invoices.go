@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Kinds of the invoice lines
+const (
+	lineTicket     = "TICKET"
+	lineConcession = "CONCESSION"
+)
+
+const (
+	maxTaxRate      = 10000 // Rates are in basis points. 10000 is 100%
+	maxInvoiceDays  = 92    // Max days of the invoices queried at a time
+	invoiceNoDigits = 9     // Invoice numbers are padded to 9 digits (ex: "Theatre1-000000001")
+)
+
+// TaxConfig has the taxes of a theatre. Taxes are added to the price of the tickets and the concessions
+type TaxConfig struct {
+	ObjType     string    `json:"obj"`
+	TheatreID   string    `json:"thid"`        // Alphanumeric
+	TicketSlabs []TaxSlab `json:"ticketslabs"` // Taxes of a ticket by the price of the ticket, in the ascending order of "upto"
+	Concession  []TaxRate `json:"concession"`  // Taxes of popcorn, water and soda
+	CreateTs    string    `json:"cts"`         // RFC3339. Transaction timestamp of the first save
+	UpdateTs    string    `json:"uts"`         // RFC3339. Transaction timestamp of the latest save
+}
+
+// TaxSlab is the taxes of the tickets priced upto the given price
+type TaxSlab struct {
+	UpTo  uint32    `json:"upto"`  // Price of the ticket after the discount upto which the slab applies. 0 for the last slab
+	Rates []TaxRate `json:"rates"` //
+}
+
+// TaxRate is a single tax (ex: CGST, SGST, entertainment tax)
+type TaxRate struct {
+	Name string `json:"name"` // Unique in a slab (ex: "CGST")
+	Rate uint32 `json:"rate"` // Basis points (ex: 900 for 9%)
+}
+
+// TaxAmount is a tax charged on an invoice line or on the invoice
+type TaxAmount struct {
+	Name   string `json:"name"`
+	Rate   uint32 `json:"rate"`   // Basis points
+	Amount uint64 `json:"amount"` //
+}
+
+// InvoiceLine is a ticket or a concession item of an invoice
+type InvoiceLine struct {
+	Kind     string      `json:"kind"`     // TICKET or CONCESSION
+	Ref      string      `json:"ref"`      // Ticket ID or the concession item (ex: "popcorn")
+	Quantity uint32      `json:"qty"`      //
+	Discount uint64      `json:"discount"` // Discount of the coupon
+	Amount   uint64      `json:"amount"`   // Amount before the taxes, after the discount
+	Taxes    []TaxAmount `json:"taxes"`    //
+}
+
+// Invoice is the tax invoice of a sale. Invoices are not changed once saved
+type Invoice struct {
+	ObjType     string        `json:"obj"`
+	TheatreID   string        `json:"thid"`      // Alphanumeric
+	InvoiceNo   string        `json:"invoiceno"` // Theatre ID + "-" + invoice number (ex: "Theatre1-000000001")
+	Number      uint64        `json:"number"`    // Sequential number of the invoice for the theatre
+	InvoiceDate string        `json:"invdate"`   // YYYY-MM-DD. Current date of the theatre at the time of the sale
+	TrxnID      string        `json:"trxnid"`    // Transaction of the sale
+	Customer    string        `json:"customer"`  //
+	Lines       []InvoiceLine `json:"lines"`     //
+	Taxes       []TaxAmount   `json:"taxes"`     // Totals of each tax of the lines
+	Subtotal    uint64        `json:"subtotal"`  // Amount before the taxes
+	Tax         uint64        `json:"tax"`       //
+	Total       uint64        `json:"total"`     // Amount to pay
+	CreateTs    string        `json:"cts"`       // RFC3339. Transaction timestamp of the first save
+	UpdateTs    string        `json:"uts"`       // RFC3339. Transaction timestamp of the latest save
+}
+
+// InvoiceCounter is the last invoice number of a theatre
+type InvoiceCounter struct {
+	ObjType   string `json:"obj"`
+	TheatreID string `json:"thid"` // Alphanumeric
+	Last      uint64 `json:"last"` //
+	CreateTs  string `json:"cts"`  // RFC3339. Transaction timestamp of the first save
+	UpdateTs  string `json:"uts"`  // RFC3339. Transaction timestamp of the latest save
+}
+
+// InvoiceQuery is the input to get the invoices of a theatre by the invoice date
+type InvoiceQuery struct {
+	TheatreID string `json:"thid"`     // Alphanumeric
+	FromDate  string `json:"fromdate"` // YYYY-MM-DD
+	ToDate    string `json:"todate"`   // YYYY-MM-DD. Included. Upto 92 days from the from date
+}
+
+// slabRates returns the taxes of a ticket of the given price
+func (tc TaxConfig) slabRates(price uint64) []TaxRate {
+	for _, slab := range tc.TicketSlabs {
+		if slab.UpTo == 0 || price <= uint64(slab.UpTo) {
+			return slab.Rates
+		}
+	}
+	return nil
+}
+
+// taxAmounts returns the taxes of the amount. Taxes are rounded to the nearest unit
+func taxAmounts(amount uint64, rates []TaxRate) []TaxAmount {
+	taxes := []TaxAmount{}
+	for _, rate := range rates {
+		taxes = append(taxes, TaxAmount{Name: rate.Name, Rate: rate.Rate, Amount: (amount*uint64(rate.Rate) + maxTaxRate/2) / maxTaxRate})
+	}
+	return taxes
+}
+
+// validateRates checks the rates of a slab or of the concession
+func validateRates(rates []TaxRate) error {
+	names := []string{}
+	for _, rate := range rates {
+		if rate.Name == "" || contains(names, rate.Name) {
+			return newError(codeInvalidInput, "Tax names are required and must be unique")
+		}
+		if rate.Rate > maxTaxRate {
+			return newError(codeInvalidInput, "Invalid rate %d for the tax %s. Expected upto 10000 basis points", rate.Rate, rate.Name)
+		}
+		names = append(names, rate.Name)
+	}
+	return nil
+}
+
+// getTaxConfig fetches the taxes of a theatre. Returns no taxes if the taxes are not added for the theatre
+func getTaxConfig(stub shim.ChaincodeStubInterface, thid string) (TaxConfig, error) {
+	tc := TaxConfig{ObjType: "TaxConfig", TheatreID: thid}
+	taxDetails, err := getRecord(stub, "TaxConfig", thid)
+	if err != nil {
+		return tc, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if taxDetails == nil {
+		return tc, nil
+	}
+	err = json.Unmarshal(taxDetails, &tc)
+	if err != nil {
+		return tc, fmt.Errorf("Existing tax config Unmarshalling error")
+	}
+	return tc, nil
+}
+
+// ticketLines returns the invoice lines of the tickets of a sale
+func ticketLines(quote SaleQuote, ids []string) []InvoiceLine {
+	var lines []InvoiceLine
+	for n, priced := range quote.Tickets {
+		lines = append(lines, InvoiceLine{
+			Kind:     lineTicket,
+			Ref:      ids[n],
+			Quantity: 1,
+			Discount: uint64(priced.Discount),
+			Amount:   uint64(priced.Price - priced.Discount),
+		})
+	}
+	return lines
+}
+
+// issueInvoice adds the taxes to the lines of a sale and saves the invoice under the next invoice number of the
+// theatre. Invoice counter is updated by every sale of the theatre, so the sales of a theatre are not endorsed in
+// parallel
+func issueInvoice(stub shim.ChaincodeStubInterface, td TheatreDetails, customer string, lines []InvoiceLine) (*Invoice, error) {
+	tc, err := getTaxConfig(stub, td.TheatreID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := Invoice{
+		ObjType:     "Invoice",
+		TheatreID:   td.TheatreID,
+		InvoiceDate: theatreDate(td, now),
+		TrxnID:      stub.GetTxID(),
+		Customer:    customer,
+		Taxes:       []TaxAmount{},
+	}
+	for _, line := range lines {
+		rates := tc.Concession
+		if line.Kind == lineTicket {
+			rates = tc.slabRates(line.Amount)
+		}
+		line.Taxes = taxAmounts(line.Amount, rates)
+		inv.Subtotal += line.Amount
+		for _, tax := range line.Taxes {
+			inv.Tax += tax.Amount
+			found := false
+			for i := range inv.Taxes {
+				if inv.Taxes[i].Name == tax.Name && inv.Taxes[i].Rate == tax.Rate {
+					inv.Taxes[i].Amount += tax.Amount
+					found = true
+				}
+			}
+			if !found {
+				inv.Taxes = append(inv.Taxes, tax)
+			}
+		}
+		inv.Lines = append(inv.Lines, line)
+	}
+	inv.Total = inv.Subtotal + inv.Tax
+
+	counter := InvoiceCounter{ObjType: "InvoiceCounter", TheatreID: td.TheatreID}
+	counterDetails, err := getRecord(stub, "InvoiceCounter", td.TheatreID)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if counterDetails != nil {
+		err = json.Unmarshal(counterDetails, &counter)
+		if err != nil {
+			return nil, fmt.Errorf("Existing invoice counter Unmarshalling error")
+		}
+	}
+	counter.Last++
+	inv.Number = counter.Last
+	inv.InvoiceNo = fmt.Sprintf("%s-%0*d", td.TheatreID, invoiceNoDigits, inv.Number)
+
+	counterjson, _ := json.Marshal(counter)
+	err = putRecord(stub, td, counterjson, "InvoiceCounter", td.TheatreID)
+	if err != nil {
+		return nil, err
+	}
+	invjson, _ := json.Marshal(inv)
+	err = putRecord(stub, td, invjson, "Invoice", td.TheatreID, inv.InvoiceDate, inv.InvoiceNo)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// Add or replace the taxes of a theatre. Taxes apply to the sales from the transaction. Saved invoices are not changed
+func (s *ShowsManagement) addTaxConfig(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("addTaxConfig", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var tc TaxConfig
+	err := json.Unmarshal([]byte(args[0]), &tc)
+	if err != nil {
+		return errorResponse("addTaxConfig", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := tc.TheatreID
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("addTaxConfig", thid, err)
+	}
+
+	// Slabs are in the ascending order of the price. Only the last slab can be without a price
+	for i, slab := range tc.TicketSlabs {
+		last := i == len(tc.TicketSlabs)-1
+		if (slab.UpTo == 0 && !last) || (i > 0 && slab.UpTo != 0 && slab.UpTo <= tc.TicketSlabs[i-1].UpTo) {
+			return errorResponse("addTaxConfig", codeInvalidInput, strconv.Itoa(int(slab.UpTo)), "Invalid slabs. Expected slabs in the ascending order of upto, with upto 0 only for the last slab")
+		}
+		err = validateRates(slab.Rates)
+		if err != nil {
+			return failedResponse("addTaxConfig", strconv.Itoa(int(slab.UpTo)), err)
+		}
+	}
+	err = validateRates(tc.Concession)
+	if err != nil {
+		return failedResponse("addTaxConfig", "concession", err)
+	}
+
+	tc.ObjType = "TaxConfig"
+	tcjson, _ := json.Marshal(tc)
+	err = putRecord(stub, *td, tcjson, "TaxConfig", thid)
+	if err != nil {
+		_logger.Errorf("addTaxConfig:PutState is Failed :" + string(err.Error()))
+		return errorResponse("addTaxConfig", errorCode(err), thid, "Unable to add the taxes")
+	}
+	_logger.Infof("addTaxConfig:Taxes added succesfully for :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":  stub.GetTxID(),
+		"message": "Add Tax Config Success",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Get the invoices of a theatre by the invoice date, in the order of the invoice numbers. Invoices can be queried
+// only by the organization owning the theatre
+func (s *ShowsManagement) getInvoices(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("getInvoices", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var query InvoiceQuery
+	err := json.Unmarshal([]byte(args[0]), &query)
+	if err != nil {
+		return errorResponse("getInvoices", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	from, err := time.Parse(dateFormat, query.FromDate)
+	if err != nil {
+		return errorResponse("getInvoices", codeInvalidInput, query.FromDate, "Invalid from date. Expected YYYY-MM-DD format")
+	}
+	to, err := time.Parse(dateFormat, query.ToDate)
+	if err != nil {
+		return errorResponse("getInvoices", codeInvalidInput, query.ToDate, "Invalid to date. Expected YYYY-MM-DD format")
+	}
+	days := int(to.Sub(from).Hours()/24) + 1
+	if days < 1 || days > maxInvoiceDays {
+		return errorResponse("getInvoices", codeInvalidInput, query.ToDate, "Invalid date range. Expected the to date upto 92 days from the from date")
+	}
+
+	err = checkOwnerOrg(stub, query.TheatreID)
+	if err != nil {
+		return failedResponse("getInvoices", query.TheatreID, err)
+	}
+
+	// Invoices are keyed by the invoice date, so each day of the range is read by the partial key
+	invoices := []Invoice{}
+	for day := 0; day < days; day++ {
+		dt := from.AddDate(0, 0, day).Format(dateFormat)
+		resultsIterator, err := stub.GetStateByPartialCompositeKey("Invoice", []string{query.TheatreID, dt})
+		if err != nil {
+			_logger.Errorf("getInvoices:GetStateByPartialCompositeKey is Failed :" + string(err.Error()))
+			return errorResponse("getInvoices", codeLedgerError, query.TheatreID, "Unable to get the invoices")
+		}
+		records, err := collectRecords(resultsIterator)
+		resultsIterator.Close()
+		if err != nil {
+			return failedResponse("getInvoices", query.TheatreID, err)
+		}
+		for _, record := range records {
+			inv := Invoice{}
+			err = json.Unmarshal(record, &inv)
+			if err != nil {
+				return errorResponse("getInvoices", codeLedgerError, query.TheatreID, "Existing invoice Unmarshalling error")
+			}
+			invoices = append(invoices, inv)
+		}
+	}
+	sort.Slice(invoices, func(i, j int) bool { return invoices[i].Number < invoices[j].Number })
+
+	resultData := map[string]interface{}{
+		"status":   "true",
+		"invoices": invoices,
+	}
+	respjson, _ := json.Marshal(resultData)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const taxes = `{"thid":"T1","ticketslabs":[{"upto":10000,"rates":[{"name":"CGST","rate":600},{"name":"SGST","rate":600}]},` +
+	`{"upto":0,"rates":[{"name":"CGST","rate":900},{"name":"SGST","rate":900}]}],"concession":[{"name":"GST","rate":250}]}`
+
+func TestTaxAmounts(t *testing.T) {
+	tc := TaxConfig{TicketSlabs: []TaxSlab{
+		{UpTo: 10000, Rates: []TaxRate{{Name: "GST", Rate: 1200}}},
+		{UpTo: 0, Rates: []TaxRate{{Name: "GST", Rate: 1800}}},
+	}}
+	tests := []struct {
+		price uint64
+		rate  uint32
+		tax   uint64
+	}{
+		{9999, 1200, 1200},
+		{10000, 1200, 1200},
+		{10001, 1800, 1800},
+		{15000, 1800, 2700},
+		{4, 1200, 0},
+		{5, 1200, 1},
+	}
+	for _, tt := range tests {
+		rates := tc.slabRates(tt.price)
+		taxes := taxAmounts(tt.price, rates)
+		if len(taxes) != 1 || taxes[0].Rate != tt.rate || taxes[0].Amount != tt.tax {
+			t.Errorf("Taxes of %d :%+v, expected %d at %d", tt.price, taxes, tt.tax, tt.rate)
+		}
+	}
+	if rates := (TaxConfig{}).slabRates(10000); len(rates) != 0 {
+		t.Errorf("Taxes without slabs :%v", rates)
+	}
+}
+
+func TestTaxConfig(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	slabs := func(slabs string) string {
+		return `{"thid":"T1","ticketslabs":[` + slabs + `]}`
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "unknown theatre", fn: "atax", arg: `{"thid":"T9","concession":[{"name":"GST","rate":500}]}`, code: codeNotFound},
+		{name: "slab without a price before the last", fn: "atax", arg: slabs(`{"upto":0,"rates":[]},{"upto":10000,"rates":[]}`), code: codeInvalidInput},
+		{name: "slabs not in the ascending order", fn: "atax", arg: slabs(`{"upto":20000,"rates":[]},{"upto":10000,"rates":[]}`), code: codeInvalidInput},
+		{name: "tax without a name", fn: "atax", arg: slabs(`{"upto":0,"rates":[{"rate":500}]}`), code: codeInvalidInput},
+		{name: "tax named twice", fn: "atax", arg: slabs(`{"upto":0,"rates":[{"name":"GST","rate":500},{"name":"GST","rate":500}]}`), code: codeInvalidInput},
+		{name: "rate over 100 percent", fn: "atax", arg: `{"thid":"T1","concession":[{"name":"GST","rate":10001}]}`, code: codeInvalidInput},
+		{name: "by a cashier", fn: "atax", arg: taxes, code: codeUnauthorized, role: roleCashier},
+		{name: "taxes", fn: "atax", arg: taxes},
+	})
+}
+
+func TestInvoices(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	s.addTheatre("T2", `,"utcoffset":330`)
+	s.mustInvoke("atax", taxes)
+	s.mustInvoke("acpn", `{"thid":"T1","code":"PROMO","discount":"PERCENT","value":10}`)
+
+	sales := []struct {
+		arg       string
+		invoiceNo string
+		tax       float64
+		total     float64
+	}{
+		{`{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000,"customer":"CUST01"}`, "T1-000000001", 2400, 22400},
+		{`{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":15000}`, "T1-000000002", 2700, 17700},
+		{`{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":1,"price":11000,"coupon":"PROMO"}`, "T1-000000003", 1188, 11088},
+		{`{"thid":"T2","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, "T2-000000001", 0, 10000},
+	}
+	for _, sale := range sales {
+		result := s.mustInvoke("sell", sale.arg)
+		if result["invoiceno"] != sale.invoiceNo || result["tax"] != sale.tax || result["total"] != sale.total {
+			t.Errorf("Sale %s :%v, expected the invoice %s with the tax %v", sale.arg, result, sale.invoiceNo, sale.tax)
+		}
+	}
+	// Invoices are dated by the current date of the theatre
+	s.now = day0 + 19*3600
+	s.mustInvoke("sell", `{"thid":"T2","screen":"SC1","showdate":"`+date0+`","showcode":"2","ticketsold":1,"price":10000}`)
+	// Saved invoices are not changed by the new taxes
+	s.mustInvoke("atax", `{"thid":"T1","concession":[{"name":"GST","rate":500}]}`)
+
+	runCases(t, s, []invokeCase{
+		{name: "to date before the from date", fn: "ginv", arg: `{"thid":"T1","fromdate":"` + date1 + `","todate":"` + date0 + `"}`, code: codeInvalidInput},
+		{name: "over 92 days", fn: "ginv", arg: `{"thid":"T1","fromdate":"2024-01-01","todate":"2024-04-02"}`, code: codeInvalidInput},
+		{name: "invalid date", fn: "ginv", arg: `{"thid":"T1","fromdate":"2024-02-30","todate":"` + date0 + `"}`, code: codeInvalidInput},
+		{name: "by another organization", fn: "ginv", arg: `{"thid":"T1","fromdate":"` + date0 + `","todate":"` + date0 + `"}`, code: codeUnauthorized, msp: "Org2MSP"},
+		{name: "by a cashier", fn: "ginv", arg: `{"thid":"T1","fromdate":"` + date0 + `","todate":"` + date0 + `"}`, role: roleCashier},
+	})
+
+	ranges := []struct {
+		thid     string
+		from, to string
+		numbers  string
+	}{
+		{"T1", date0, date0, "T1-000000001 T1-000000002 T1-000000003"},
+		{"T1", "2024-01-01", "2024-03-31", "T1-000000001 T1-000000002 T1-000000003"},
+		{"T1", date1, date1, ""},
+		{"T2", date0, date0, "T2-000000001"},
+		{"T2", date1, date1, "T2-000000002"},
+		{"T9", date0, date0, ""},
+	}
+	for _, r := range ranges {
+		var result struct {
+			Invoices []Invoice `json:"invoices"`
+		}
+		s.result("ginv", `{"thid":"`+r.thid+`","fromdate":"`+r.from+`","todate":"`+r.to+`"}`, &result)
+		numbers := []string{}
+		for _, inv := range result.Invoices {
+			numbers = append(numbers, inv.InvoiceNo)
+		}
+		if strings.Join(numbers, " ") != r.numbers {
+			t.Errorf("Invoices of %s from %s to %s :%q, expected %q", r.thid, r.from, r.to, numbers, r.numbers)
+		}
+		if r.numbers == "" || r.thid != "T1" || r.from != date0 {
+			continue
+		}
+		first := result.Invoices[0]
+		if first.Customer != "CUST01" || len(first.Lines) != 2 || first.Subtotal != 20000 || first.Tax != 2400 || first.Total != 22400 {
+			t.Errorf("First invoice :%+v", first)
+		}
+		if len(first.Taxes) != 2 || first.Taxes[0].Name != "CGST" || first.Taxes[0].Amount != 1200 || first.Lines[0].Kind != lineTicket {
+			t.Errorf("Taxes of the first invoice :%+v, lines :%+v", first.Taxes, first.Lines)
+		}
+		if coupon := result.Invoices[2].Lines[0]; coupon.Discount != 1100 || coupon.Amount != 9900 {
+			t.Errorf("Invoice line of the sale with the coupon :%+v", coupon)
+		}
+	}
+}
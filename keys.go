@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Every record is saved under the composite key of its object type and IDs. Theatre ID is the first ID of the
+// keys of all the object types so that the records of a theatre can be queried by the partial key
+//
+//	TheatreDetails - thid
+//	ShowDetails    - thid, screen, showdate
+//	Tickets        - thid, screen, showdate, showcode
+//	ShowSeats      - thid, screen, showdate, showcode
+//	ShowHolds      - thid, screen, showdate, showcode
+//	SeatMap        - thid, screen
+//	SodaInventory  - thid, inventoryid
+//	Ticket         - thid, ticketid
+//	ShowTicket     - thid, screen, showdate, showcode, ticketid (tickets sold without seats, until cancelled)
+//	Refund         - thid, refundid
+//	DailyArchive   - thid, bizdate
+//	SodaDraw       - thid, drawid
+//	SodaPromotion  - thid
+//	SodaWins       - thid, windate
+//	PriceList      - thid, screen
+//	Coupon         - thid, code
+//	CouponUse      - thid, code, customer
+//	TaxConfig      - thid
+//	InvoiceCounter - thid
+//	Invoice        - thid, invdate, invoiceno
+//	RevealedSeed   - thid, seedhash
+//	LegacyOwner    - thid (registered at the instantiation or upgrade)
+//	SodaSeed       - thid (private data of the "sodaSeeds<MSP ID>" collection of the organization owning the theatre)
+
+// objTypes are the object types of the records of a theatre
+var objTypes = []string{"TheatreDetails", "ShowDetails", "Tickets", "ShowSeats", "ShowHolds", "SeatMap", "SodaInventory",
+	"Ticket", "ShowTicket", "Refund", "DailyArchive", "SodaDraw", "SodaPromotion", "SodaWins", "PriceList", "Coupon", "CouponUse",
+	"TaxConfig", "InvoiceCounter", "Invoice", "RevealedSeed"}
+
+// stateKey returns the composite key of the record
+func stateKey(stub shim.ChaincodeStubInterface, objType string, ids ...string) (string, error) {
+	key, err := stub.CreateCompositeKey(objType, ids)
+	if err != nil {
+		return "", fmt.Errorf("Invalid %s key :%s", objType, err.Error())
+	}
+	return key, nil
+}
+
+// getTheatreRecords returns the records of the object type of a theatre. Records are read by the partial composite
+// key so that the records added by the other transactions are detected when the transaction is validated, unlike
+// the rich queries
+func getTheatreRecords(stub shim.ChaincodeStubInterface, objType string, thid string) ([]*queryresult.KV, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(objType, []string{thid})
+	if err != nil {
+		return nil, fmt.Errorf("GetStateByPartialCompositeKey is Failed :%s", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	var records []*queryresult.KV
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Query iteration is Failed :%s", err.Error())
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// getRecord fetches the record of the object type. Returns nil if the record does not exists
+func getRecord(stub shim.ChaincodeStubInterface, objType string, ids ...string) ([]byte, error) {
+	key, err := stateKey(stub, objType, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return stub.GetState(key)
+}
+
+// putRecord saves the record of the object type. First ID of the key is the theatre owning the record
+func putRecord(stub shim.ChaincodeStubInterface, td TheatreDetails, value []byte, objType string, ids ...string) error {
+	key, err := stateKey(stub, objType, ids...)
+	if err != nil {
+		return err
+	}
+	return putTheatreState(stub, td, key, value)
+}
+
+// delRecord removes the record of the object type
+func delRecord(stub shim.ChaincodeStubInterface, objType string, ids ...string) error {
+	key, err := stateKey(stub, objType, ids...)
+	if err != nil {
+		return err
+	}
+	return stub.DelState(key)
+}
+
+// ledgerTime formats the transaction timestamp in RFC3339
+func ledgerTime(secs int64) string {
+	return time.Unix(secs, 0).UTC().Format(time.RFC3339)
+}
+
+// stampRecord adds the identity of the client submitting the transaction and the transaction timestamp to the
+// record. Create timestamp of an existing record is preserved
+func stampRecord(stub shim.ChaincodeStubInterface, key string, value []byte) ([]byte, error) {
+	record := map[string]json.RawMessage{}
+	err := json.Unmarshal(value, &record)
+	if err != nil {
+		return nil, fmt.Errorf("Record to save is not a json object :%s", err.Error())
+	}
+	id, err := cid.GetID(stub)
+	if err != nil {
+		return nil, newError(codeUnauthorized, "Unable to get the identity of the client :%s", err.Error())
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return nil, newError(codeUnauthorized, "Unable to get the organization of the client :%s", err.Error())
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, fmt.Errorf("GetTxTimestamp is Failed :%s", err.Error())
+	}
+
+	createTs := ledgerTime(now)
+	existing, err := stub.GetState(key)
+	if err != nil {
+		return nil, err
+	}
+	var saved struct {
+		CreateTs string `json:"cts"`
+	}
+	if len(existing) > 0 && json.Unmarshal(existing, &saved) == nil && saved.CreateTs != "" {
+		// Records saved before the ledger timestamps were introduced have the epoch time provided by the client
+		secs, err := strconv.ParseInt(saved.CreateTs, 10, 64)
+		if err == nil {
+			createTs = ledgerTime(secs)
+		} else {
+			createTs = saved.CreateTs
+		}
+	}
+
+	record["modby"], _ = json.Marshal(id)
+	record["modmsp"], _ = json.Marshal(mspID)
+	record["cts"], _ = json.Marshal(createTs)
+	record["uts"], _ = json.Marshal(ledgerTime(now))
+	return json.Marshal(record)
+}
+
+// putState saves the record along with the identity of the client and the timestamps of the transaction
+func putState(stub shim.ChaincodeStubInterface, key string, value []byte) error {
+	value, err := stampRecord(stub, key, value)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, value)
+}
+
+// recordRef has the IDs of a record used to build its composite key
+type recordRef struct {
+	ObjType      string      `json:"obj"`
+	TheatreID    string      `json:"thid"`
+	Screen       string      `json:"screen"`
+	ShowDate     string      `json:"showdate"`
+	ShowCode     interface{} `json:"showcode"` // Showcodes of the show details are a list
+	InventoryID  string      `json:"inventoryid"`
+	TicketID     string      `json:"ticketid"`
+	RefundID     string      `json:"refundid"`
+	BusinessDate string      `json:"bizdate"`
+	DrawID       string      `json:"drawid"`
+	Code         string      `json:"code"`
+	WinDate      string      `json:"windate"`
+}
+
+// ids returns the IDs of the composite key of the record. Returns false for the unknown object types
+func (ref recordRef) ids() ([]string, bool) {
+	switch ref.ObjType {
+	case "TheatreDetails", "SodaPromotion", "TaxConfig":
+		return []string{ref.TheatreID}, true
+	case "ShowDetails":
+		return []string{ref.TheatreID, ref.Screen, ref.ShowDate}, true
+	case "Tickets", "ShowSeats", "ShowHolds":
+		showCode, found := ref.ShowCode.(string)
+		return []string{ref.TheatreID, ref.Screen, ref.ShowDate, showCode}, found
+	case "SeatMap", "PriceList":
+		return []string{ref.TheatreID, ref.Screen}, true
+	case "SodaInventory":
+		return []string{ref.TheatreID, ref.InventoryID}, true
+	case "Ticket":
+		return []string{ref.TheatreID, ref.TicketID}, true
+	case "Refund":
+		return []string{ref.TheatreID, ref.RefundID}, true
+	case "DailyArchive":
+		return []string{ref.TheatreID, ref.BusinessDate}, true
+	case "SodaDraw":
+		return []string{ref.TheatreID, ref.DrawID}, true
+	case "SodaWins":
+		return []string{ref.TheatreID, ref.WinDate}, true
+	case "Coupon":
+		return []string{ref.TheatreID, ref.Code}, true
+	}
+	return nil, false
+}
+
+// setRecordField sets the field of a record saved by the earlier versions of the chaincode
+func setRecordField(value []byte, field string, fieldValue interface{}) ([]byte, error) {
+	record := map[string]json.RawMessage{}
+	err := json.Unmarshal(value, &record)
+	if err != nil {
+		return nil, fmt.Errorf("Existing record Unmarshalling error")
+	}
+	record[field], _ = json.Marshal(fieldValue)
+	return json.Marshal(record)
+}
+
+// dateRecord sets the show date of the show details or tickets saved before the show date was introduced. Showcodes
+// of the show details are converted to the shows
+func dateRecord(value []byte, objType string, showDate string) ([]byte, error) {
+	value, err := setRecordField(value, "showdate", showDate)
+	if err != nil || objType != "ShowDetails" {
+		return value, err
+	}
+	sd := ShowDetails{}
+	err = json.Unmarshal(value, &sd)
+	if err == nil {
+		err = sd.normalizeShows()
+	}
+	if err == nil {
+		value, err = setRecordField(value, "shows", sd.Shows)
+	}
+	if err == nil {
+		value, err = setRecordField(value, "showcode", sd.ShowCode)
+	}
+	return value, err
+}
+
+// bindOwner sets the owning organization of the theatre details saved before the access control was introduced
+func bindOwner(value []byte, mspID string) ([]byte, error) {
+	record := map[string]json.RawMessage{}
+	err := json.Unmarshal(value, &record)
+	if err != nil {
+		return nil, fmt.Errorf("Existing theatre details Unmarshalling error")
+	}
+	record["ownermsp"], _ = json.Marshal(mspID)
+	return json.Marshal(record)
+}
+
+// MigrationRequest is the input to move the records of a theatre saved under the string keys to the composite keys
+type MigrationRequest struct {
+	TheatreID string `json:"thid"`     // Alphanumeric
+	StartKey  string `json:"startkey"` // Key to resume the migration from. Returned as "nextkey" by the previous migration
+	ShowDate  string `json:"showdate"` // YYYY-MM-DD. Date of the shows saved without a show date. Defaults to the business day of the theatre
+	OwnerMSP  string `json:"ownermsp"` // Organization binding the theatre added before the access control. Must be the registered owner
+	Limit     int    `json:"limit"`    // Max keys processed in a transaction. Defaults to 100
+}
+
+// Move the records of a theatre saved under the string keys (ex: thid+sc+showdate) to the composite keys. Records
+// are moved in batches. Migration is complete when "nextkey" is not returned. Shows and tickets saved without a show
+// date are keyed by the show date of the request, or the business day of the theatre, and returned as "dated". Theatre
+// not bound to an organization is bound to the owner of the request, which must be the organization of the admin and
+// the owner registered at the instantiation
+func (s *ShowsManagement) migrateKeys(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("migrateKeys", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var req MigrationRequest
+	err := json.Unmarshal([]byte(args[0]), &req)
+	if err != nil || req.TheatreID == "" {
+		return errorResponse("migrateKeys", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+	thid := req.TheatreID
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	// Migrated keys are endorsed by the organizations endorsing the theatre, which may itself be under the old key
+	td, err := getTheatreDetails(stub, thid)
+	composite := td != nil
+	if err == nil && td == nil {
+		var legacy []byte
+		legacy, err = stub.GetState(thid)
+		if err == nil && legacy != nil {
+			td = &TheatreDetails{}
+			err = json.Unmarshal(legacy, td)
+		}
+	}
+	if err != nil {
+		return failedResponse("migrateKeys", thid, err)
+	}
+
+	showDate := req.ShowDate
+	theatre := TheatreDetails{}
+	if td != nil {
+		theatre = *td
+	}
+	if showDate == "" {
+		showDate = theatre.BusinessDate
+	}
+	showDate, err = showDateOrToday(stub, theatre, showDate)
+	if err != nil {
+		return failedResponse("migrateKeys", thid, err)
+	}
+
+	// Theatre details under the old key is the first key of the theatre and is bound in the first batch
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return failedResponse("migrateKeys", thid, newError(codeUnauthorized, "Unable to get the organization of the client :%s", err.Error()))
+	}
+	if td != nil && td.OwnerMSP != "" && td.OwnerMSP != mspID {
+		return failedResponse("migrateKeys", thid, newError(codeUnauthorized, "Theatre %s is not owned by the organization %s", thid, mspID))
+	}
+	bind := td != nil && td.OwnerMSP == ""
+	if bind {
+		err = checkLegacyOwner(stub, thid, req.OwnerMSP, mspID)
+		if err != nil {
+			return failedResponse("migrateKeys", thid, err)
+		}
+		td.OwnerMSP = req.OwnerMSP
+	}
+	var endorsers []string
+	if td != nil {
+		endorsers = td.endorsers()
+	}
+	if bind && composite {
+		tdjson, _ := json.Marshal(td)
+		key, err := stateKey(stub, "TheatreDetails", thid)
+		if err == nil {
+			err = putState(stub, key, tdjson)
+		}
+		if err == nil {
+			err = setKeyEndorsers(stub, key, endorsers)
+		}
+		if err != nil {
+			_logger.Errorf("migrateKeys:PutState is Failed :" + string(err.Error()))
+			return errorResponse("migrateKeys", errorCode(err), thid, "Unable to bind the theatre to the organization")
+		}
+	}
+
+	// Old keys of the theatre start with the theatre ID. Range query does not return the composite keys
+	startKey := thid
+	if req.StartKey > startKey {
+		startKey = req.StartKey
+	}
+	resultsIterator, err := stub.GetStateByRange(startKey, thid+string(utf8.MaxRune))
+	if err != nil {
+		_logger.Errorf("migrateKeys:GetStateByRange is Failed :" + string(err.Error()))
+		return errorResponse("migrateKeys", errorCode(err), thid, "Unable to migrate the keys")
+	}
+	defer resultsIterator.Close()
+
+	migrated, skipped := 0, 0
+	dated := []string{}
+	nextKey := ""
+	for resultsIterator.HasNext() {
+		if migrated+skipped == limit {
+			nextKey = startKey
+			break
+		}
+		record, err := resultsIterator.Next()
+		if err != nil {
+			_logger.Errorf("migrateKeys:Query iteration is Failed :" + string(err.Error()))
+			return errorResponse("migrateKeys", errorCode(err), thid, "Unable to migrate the keys")
+		}
+		startKey = record.Key + "\x00"
+
+		// Keys of the other theatres with the same prefix (ex: "T11" for "T1") and unknown records are left as is
+		var ref recordRef
+		ids, found := []string(nil), false
+		if json.Unmarshal(record.Value, &ref) == nil && ref.TheatreID == thid {
+			ids, found = ref.ids()
+		}
+		if !found {
+			skipped++
+			continue
+		}
+		// Record is moved as is to keep the identity of the client who saved it. Shows saved before the show date was
+		// introduced are given the show date of the migration
+		value := record.Value
+		undated := len(ids) > 2 && ids[2] == ""
+		if undated {
+			ids[2] = showDate
+		}
+		key, err := stateKey(stub, ref.ObjType, ids...)
+		if err == nil && undated {
+			var existing []byte
+			existing, err = stub.GetState(key)
+			if err == nil && existing != nil {
+				err = newError(codeConflict, "Record of the key %s already exists for the show date %s. Provide another show date", record.Key, showDate)
+			}
+			if err == nil {
+				value, err = dateRecord(value, ref.ObjType, showDate)
+			}
+		}
+		if err == nil && bind && ref.ObjType == "TheatreDetails" {
+			value, err = bindOwner(value, td.OwnerMSP)
+		}
+		if err == nil {
+			err = stub.PutState(key, value)
+		}
+		if err == nil && len(endorsers) > 0 {
+			err = setKeyEndorsers(stub, key, endorsers)
+		}
+		if err == nil {
+			err = stub.DelState(record.Key)
+		}
+		if err != nil {
+			return errorResponse("migrateKeys", errorCode(err), thid, "Unable to migrate the key "+err.Error())
+		}
+		if undated {
+			dated = append(dated, record.Key)
+		}
+		migrated++
+	}
+	_logger.Infof("migrateKeys:Keys migrated successfully for :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":   stub.GetTxID(),
+		"migrated": migrated,
+		"skipped":  skipped,
+		"dated":    dated,
+		"showdate": showDate,
+		"nextkey":  nextKey,
+		"message":  "Migrate keys successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMigrateKeys(t *testing.T) {
+	s := newTestStub(t)
+	legacy := []struct {
+		key   string
+		value string
+	}{
+		{"T1", `{"obj":"TheatreDetails","thid":"T1","maxsoda":200,"sph":{"SC1":4}}`},
+		{"T11", `{"obj":"TheatreDetails","thid":"T11","maxsoda":200,"sph":{"SC1":4}}`},
+		{"T1SC1", `{"obj":"ShowDetails","thid":"T1","screen":"SC1","moviename":"Lucy","showcode":["1","2","3","4"]}`},
+		{"T1SC11", `{"obj":"Tickets","thid":"T1","screen":"SC1","showdate":"` + date0 + `","showcode":"1","ticketsold":2}`},
+		{"T1SC1" + date0, `{"obj":"ShowDetails","thid":"T1","screen":"SC1","showdate":"` + date0 + `","moviename":"Lucy","showcode":["1"],"shows":[{"showcode":"1","start":1709287200}]}`},
+	}
+	for _, l := range legacy {
+		s.putLegacy(l.key, l.value)
+	}
+	s.init(`{"legacyowners":{"T1":"Org1MSP"}}`)
+
+	batches := []struct {
+		startKey string
+		migrated float64
+		skipped  float64
+		dated    int
+		nextKey  string
+	}{
+		{"", 1, 1, 0, "T11\x00"},
+		{"T11\x00", 2, 0, 1, "T1SC11\x00"},
+		{"T1SC11\x00", 1, 0, 0, ""},
+	}
+	s.as(testMSP, roleAdmin)
+	for n, b := range batches {
+		startKey, _ := json.Marshal(b.startKey)
+		result := s.mustInvoke("mig", `{"thid":"T1","limit":2,"showdate":"`+date1+`","ownermsp":"Org1MSP","startkey":`+string(startKey)+`}`)
+		dated, _ := result["dated"].([]interface{})
+		if result["migrated"] != b.migrated || result["skipped"] != b.skipped || len(dated) != b.dated || result["nextkey"] != b.nextKey {
+			t.Errorf("Batch %d :%v, expected %+v", n+1, result, b)
+		}
+	}
+
+	for _, key := range []string{"T1", "T1SC1", "T1SC11", "T1SC1" + date0} {
+		if _, found := s.State[key]; found {
+			t.Errorf("Key %q is not migrated", key)
+		}
+	}
+	if _, found := s.State["T11"]; !found {
+		t.Error("Key of another theatre is migrated")
+	}
+	td := TheatreDetails{}
+	if !s.record(&td, "TheatreDetails", "T1") || td.OwnerMSP != testMSP {
+		t.Errorf("Migrated theatre details :%+v", td)
+	}
+	tkt := Tickets{}
+	if !s.record(&tkt, "Tickets", "T1", "SC1", date0, "1") || tkt.TicketsSold != 2 {
+		t.Errorf("Migrated tickets :%+v", tkt)
+	}
+	sd := ShowDetails{}
+	if !s.record(&sd, "ShowDetails", "T1", "SC1", date0) || len(sd.Shows) != 1 {
+		t.Errorf("Migrated show details :%+v", sd)
+	}
+	if !s.record(&sd, "ShowDetails", "T1", "SC1", date1) || sd.ShowDate != date1 {
+		t.Errorf("Migrated show details without a show date :%+v, expected the show date of the migration", sd)
+	}
+	if got := s.keyEndorsers(s.theatreKeys("T1")[0]); got != testMSP {
+		t.Errorf("Migrated keys are endorsed by %q", got)
+	}
+
+	// Migrated records are used by the functions
+	runCases(t, s, []invokeCase{
+		{name: "theatre id missing", fn: "mig", arg: `{"limit":2}`, code: codeInvalidInput, role: roleAdmin},
+		{name: "tickets of the migrated show", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000}`},
+		{name: "over the migrated count", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, code: codeCapacityExceeded},
+	})
+}
+
+func TestMigrateUndatedShows(t *testing.T) {
+	s := newTestStub(t)
+	// Records as saved by the first version of the chaincode, without the show dates
+	legacy := []struct {
+		key   string
+		value string
+	}{
+		{"T1", `{"obj":"TheatreDetails","thid":"T1","sph":{"SC1":4},"maxsoda":200,"ownermsp":"Org1MSP","cts":"1606791828","uts":"1606791828"}`},
+		{"T1SC1", `{"obj":"ShowDetails","moviename":"Lucy","screen":"SC1","thid":"T1","showcode":["1","2","",""],"cts":"1606791828","uts":"1606791828"}`},
+		{"T1SC11", `{"obj":"Tickets","thid":"T1","moviename":"Lucy","screen":"SC1","showcode":"1","ticketsold":3,"pcsold":3,"watersold":3,"cts":"1606791828","uts":"1606791828"}`},
+		{"T2SC1", `{"obj":"ShowDetails","moviename":"Lucy","screen":"SC1","thid":"T2","showcode":["1","","",""],"cts":"1606791828","uts":"1606791828"}`},
+	}
+	for _, l := range legacy {
+		s.putLegacy(l.key, l.value)
+	}
+	s.mustInvoke("athd", `{"thid":"T2","maxsoda":200,"sph":{"SC1":4}}`)
+	s.as(testMSP, roleAdmin)
+	result := s.mustInvoke("mig", `{"thid":"T1"}`)
+	s.as(testMSP, roleManager)
+	if result["migrated"] != float64(3) || result["showdate"] != date0 || len(result["dated"].([]interface{})) != 2 {
+		t.Errorf("Migration of the shows without a show date :%v, expected the shows dated %s", result, date0)
+	}
+	tkt := Tickets{}
+	if !s.record(&tkt, "Tickets", "T1", "SC1", date0, "1") || tkt.ShowDate != date0 || tkt.TicketsSold != 3 {
+		t.Errorf("Migrated tickets :%+v", tkt)
+	}
+
+	s.mustInvoke("asd", `{"moviename":"Tenet","screen":"SC1","thid":"T2","showdate":"`+date0+`","showcode":["1"]}`)
+	runCases(t, s, []invokeCase{
+		{name: "tickets of the migrated show", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`},
+		{name: "over the migrated count", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, code: codeCapacityExceeded},
+		{name: "show of the migrated show codes", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":4,"price":10000}`},
+		{name: "show date taken by a show added after", fn: "mig", arg: `{"thid":"T2"}`, code: codeConflict, role: roleAdmin},
+		{name: "another show date", fn: "mig", arg: `{"thid":"T2","showdate":"` + date1 + `"}`, role: roleAdmin},
+		{name: "tickets of the show on the other date", fn: "sell", arg: `{"thid":"T2","screen":"SC1","showdate":"` + date1 + `","showcode":"1","ticketsold":1,"price":10000}`},
+	})
+}
+
+func TestTheatreRecords(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	s.addTheatre("T11", "")
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`)
+	s.mustInvoke("sell", `{"thid":"T11","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`)
+	s.mustInvoke("sell", `{"thid":"T11","screen":"SC1","showcode":"2","ticketsold":1,"price":10000}`)
+
+	tests := []struct {
+		objType string
+		thid    string
+		count   int
+	}{
+		{"TheatreDetails", "T1", 1},
+		{"ShowDetails", "T1", 1},
+		{"Tickets", "T1", 1},
+		{"Tickets", "T11", 2},
+		{"Ticket", "T11", 2},
+		{"Tickets", "T2", 0},
+	}
+	for _, tt := range tests {
+		records, err := getTheatreRecords(s, tt.objType, tt.thid)
+		if err != nil || len(records) != tt.count {
+			t.Errorf("%s records of %s :%d, expected %d. Error :%v", tt.objType, tt.thid, len(records), tt.count, err)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Formats of the shows
+const (
+	format2D   = "2D"
+	format3D   = "3D"
+	formatIMAX = "IMAX"
+)
+
+var showFormats = []string{format2D, format3D, formatIMAX}
+
+// PriceList has the ticket prices of a screen by the seat category and the show format
+type PriceList struct {
+	ObjType   string      `json:"obj"`
+	TheatreID string      `json:"thid"`   // Alphanumeric
+	Screen    string      `json:"screen"` // Alphanumeric
+	Prices    []PriceRule `json:"prices"` // Replaces the prices of the screen
+	CreateTs  string      `json:"cts"`    // RFC3339. Transaction timestamp of the first save
+	UpdateTs  string      `json:"uts"`    // RFC3339. Transaction timestamp of the latest save
+}
+
+// PriceRule is the price of the tickets of a seat category for a show format
+type PriceRule struct {
+	Category string `json:"category"` // Seat category on the seat map. Empty for the tickets sold without seats and the categories without a price
+	Format   string `json:"format"`   // 2D, 3D or IMAX
+	Price    uint32 `json:"price"`    // Price per ticket in the smallest currency unit (ex: paise)
+}
+
+// PriceQuote is the input to get the price of the tickets of a show without buying them
+type PriceQuote struct {
+	TheatreID string   `json:"thid"`       // Alphanumeric
+	Screen    string   `json:"screen"`     // Alphanumeric
+	ShowDate  string   `json:"showdate"`   // YYYY-MM-DD. Defaults to the current date of the theatre
+	ShowCode  string   `json:"showcode"`   //
+	Count     uint32   `json:"ticketsold"` // Tickets without seats
+	Seats     []string `json:"seats"`      // Seats (ex: ["A1", "A2"])
+	TicketSale
+}
+
+// PricedTicket is the price of a ticket of a sale
+type PricedTicket struct {
+	Seat     string `json:"seat,omitempty"` // Empty for a ticket without a seat
+	Category string `json:"category"`       // Seat category. Empty for a ticket without a seat
+	Price    uint32 `json:"price"`          // Price on the price list
+	Discount uint32 `json:"discount"`       // Discount of the coupon on the ticket
+}
+
+// SaleQuote is the price of the tickets of a sale in the order of the tickets
+type SaleQuote struct {
+	MovieName string         `json:"moviename"`        // Movie of the show
+	Format    string         `json:"format"`           // Format of the show
+	Listed    bool           `json:"listed"`           // Prices are from the price list of the screen. Otherwise the price provided with the sale, if any, is used
+	Coupon    string         `json:"coupon,omitempty"` // Coupon applied to the sale, if any
+	Tickets   []PricedTicket `json:"tickets"`          //
+	Discount  uint64         `json:"discount"`         // Total discount of the coupon
+	Total     uint64         `json:"total"`            // Amount to pay after the discount
+}
+
+// price returns the price of the seat category for the show format. Price without a category applies to the
+// categories without a price
+func (pl PriceList) price(category string, format string) (uint32, error) {
+	fallback, found := uint32(0), false
+	for _, rule := range pl.Prices {
+		if rule.Format != format {
+			continue
+		}
+		if rule.Category == category {
+			return rule.Price, nil
+		}
+		if rule.Category == "" {
+			fallback, found = rule.Price, true
+		}
+	}
+	if !found {
+		return 0, newError(codeNotFound, "Price is not added for the category %q and the format %s of the screen %s", category, format, pl.Screen)
+	}
+	return fallback, nil
+}
+
+// getPriceList fetches the price list of a screen. Returns nil if the price list is not added for the screen
+func getPriceList(stub shim.ChaincodeStubInterface, thid string, sc string) (*PriceList, error) {
+	priceDetails, err := getRecord(stub, "PriceList", thid, sc)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if priceDetails == nil {
+		return nil, nil
+	}
+	pl := PriceList{}
+	err = json.Unmarshal(priceDetails, &pl)
+	if err != nil {
+		return nil, fmt.Errorf("Existing price list Unmarshalling error")
+	}
+	return &pl, nil
+}
+
+// quoteSale prices the tickets of a sale from the price list of the screen. Tickets without seats come first, followed
+// by the seats in the given order. Screens without a price list are priced at the price provided with the sale, or left
+// unpriced (price 0) as the sales before the price lists. Price provided for a screen with a price list must match the
+// price of every ticket
+func quoteSale(stub shim.ChaincodeStubInterface, thid string, sc string, dt string, st string, count int, seats []string, provided uint32) (*SaleQuote, error) {
+	sd, err := getShowDetailsOfScreen(stub, thid, sc, dt)
+	if err == nil && sd == nil {
+		err = newError(codeNotFound, "Show details does not exists for the given details")
+	}
+	if err != nil {
+		return nil, err
+	}
+	show, found := sd.show(st)
+	if !found {
+		return nil, newError(codeNotFound, "Showcode %s is not added for the screen on %s", st, dt)
+	}
+
+	quote := SaleQuote{MovieName: show.MovieName, Format: show.Format}
+	if quote.MovieName == "" {
+		quote.MovieName = sd.MovieName
+	}
+	if quote.Format == "" {
+		quote.Format = format2D
+	}
+	for n := 0; n < count; n++ {
+		quote.Tickets = append(quote.Tickets, PricedTicket{})
+	}
+	if len(seats) > 0 {
+		sm, err := getSeatMap(stub, thid, sc)
+		if err == nil && sm == nil {
+			err = newError(codeNotFound, "Seat map does not exists for the screen")
+		}
+		if err != nil {
+			return nil, err
+		}
+		categories := sm.seatCategories()
+		for _, seat := range seats {
+			category, found := categories[seat]
+			if !found {
+				return nil, newError(codeNotFound, "Seat %s does not exists on the screen", seat)
+			}
+			quote.Tickets = append(quote.Tickets, PricedTicket{Seat: seat, Category: category})
+		}
+	}
+
+	pl, err := getPriceList(stub, thid, sc)
+	if err != nil {
+		return nil, err
+	}
+	quote.Listed = pl != nil
+	for i := range quote.Tickets {
+		price := provided
+		if pl != nil {
+			price, err = pl.price(quote.Tickets[i].Category, quote.Format)
+			if err != nil {
+				return nil, err
+			}
+			if provided != 0 && provided != price {
+				return nil, newError(codeConflict, "Price provided does not match the price %d of the ticket", price)
+			}
+		}
+		quote.Tickets[i].Price = price
+		quote.Total += uint64(price)
+	}
+	return &quote, nil
+}
+
+// Add or replace the price list of a screen in a theatre
+func (s *ShowsManagement) addPriceList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("addPriceList", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var pl PriceList
+	err := json.Unmarshal([]byte(args[0]), &pl)
+	if err != nil {
+		return errorResponse("addPriceList", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := pl.TheatreID
+	sc := pl.Screen
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("addPriceList", thid, err)
+	}
+	if td.SeatsPerHall[sc] == 0 {
+		return errorResponse("addPriceList", codeNotFound, sc, "Screen does not exists for the theatre")
+	}
+
+	// Only one price for a seat category and a show format
+	if len(pl.Prices) == 0 {
+		return errorResponse("addPriceList", codeInvalidInput, sc, "Expected 1 or more prices for the screen")
+	}
+	priced := map[string]bool{}
+	for _, rule := range pl.Prices {
+		if !contains(showFormats, rule.Format) {
+			return errorResponse("addPriceList", codeInvalidInput, rule.Format, "Invalid show format. Expected 2D, 3D or IMAX")
+		}
+		if rule.Price == 0 {
+			return errorResponse("addPriceList", codeInvalidInput, rule.Category, "Invalid price. Expected a price of 1 or more")
+		}
+		if priced[rule.Category+"/"+rule.Format] {
+			return errorResponse("addPriceList", codeInvalidInput, rule.Category, "Category is priced more than once for the format "+rule.Format)
+		}
+		priced[rule.Category+"/"+rule.Format] = true
+	}
+
+	pl.ObjType = "PriceList"
+	pljson, _ := json.Marshal(pl)
+	err = putRecord(stub, *td, pljson, "PriceList", thid, sc)
+	if err != nil {
+		_logger.Errorf("addPriceList:PutState is Failed :" + string(err.Error()))
+		return errorResponse("addPriceList", errorCode(err), thid, "Unable to add the price list")
+	}
+	_logger.Infof("addPriceList:Price list added succesfully for :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":  stub.GetTxID(),
+		"message": "Add Price List Success",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Get the price of the tickets of a show without buying them. Coupon, if provided, is applied without counting its
+// use. Nothing is saved on the ledger
+func (s *ShowsManagement) quoteTickets(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("quoteTickets", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var pq PriceQuote
+	err := json.Unmarshal([]byte(args[0]), &pq)
+	if err != nil {
+		return errorResponse("quoteTickets", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+	if pq.Count == 0 && len(pq.Seats) == 0 {
+		return errorResponse("quoteTickets", codeInvalidInput, pq.ShowCode, "Invalid request to quote tickets. Expected ticket count or seats")
+	}
+
+	td, err := getTheatreDetails(stub, pq.TheatreID)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", pq.TheatreID)
+	}
+	if err != nil {
+		return failedResponse("quoteTickets", pq.TheatreID, err)
+	}
+	if !withinCapacity(td.SeatsPerHall[pq.Screen], int(pq.Count), len(pq.Seats)) {
+		return errorResponse("quoteTickets", codeCapacityExceeded, pq.Screen, "Tickets to quote are more than the screen capacity")
+	}
+
+	dt, err := showDateOrToday(stub, *td, pq.ShowDate)
+	if err != nil {
+		return failedResponse("quoteTickets", pq.TheatreID, err)
+	}
+	quote, err := quoteSale(stub, pq.TheatreID, pq.Screen, dt, pq.ShowCode, int(pq.Count), pq.Seats, 0)
+	if err == nil {
+		_, _, err = applyCoupon(stub, pq.TheatreID, pq.Screen, dt, quote, pq.TicketSale)
+	}
+	if err != nil {
+		return failedResponse("quoteTickets", pq.Screen, err)
+	}
+
+	resultData := map[string]interface{}{
+		"status":   "true",
+		"showdate": dt,
+		"quote":    quote,
+	}
+	respjson, _ := json.Marshal(resultData)
+	return shim.Success(respjson)
+}
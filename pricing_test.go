@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPriceRules(t *testing.T) {
+	pl := PriceList{Screen: "SC1", Prices: []PriceRule{
+		{Category: "Gold", Format: format2D, Price: 30000},
+		{Category: "", Format: format2D, Price: 15000},
+		{Category: "Gold", Format: formatIMAX, Price: 50000},
+	}}
+	tests := []struct {
+		category string
+		format   string
+		price    uint32
+		code     string
+	}{
+		{"Gold", format2D, 30000, ""},
+		{"Silver", format2D, 15000, ""},
+		{"", format2D, 15000, ""},
+		{"Gold", formatIMAX, 50000, ""},
+		{"Silver", formatIMAX, 0, codeNotFound},
+		{"Gold", format3D, 0, codeNotFound},
+	}
+	for _, tt := range tests {
+		price, err := pl.price(tt.category, tt.format)
+		code := ""
+		if err != nil {
+			code = errorCode(err)
+		}
+		if price != tt.price || code != tt.code {
+			t.Errorf("Price of %q for %s :%d, %v. Expected %d with the code %q", tt.category, tt.format, price, err, tt.price, tt.code)
+		}
+	}
+}
+
+func TestPriceList(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	s.mustInvoke("asm", `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":2,"category":"Gold"},{"row":"B","seats":2,"category":"Silver"}]}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"`+date1+`","shows":[{"showcode":"1","start":`+strconv.FormatInt(day0+34*3600, 10)+`,"format":"IMAX"}]}`)
+	prices := `{"thid":"T1","screen":"SC1","prices":[{"category":"Gold","format":"2D","price":30000},{"format":"2D","price":15000},{"format":"IMAX","price":40000}]}`
+
+	runCases(t, s, []invokeCase{
+		{name: "unknown theatre", fn: "apl", arg: `{"thid":"T9","screen":"SC1","prices":[{"format":"2D","price":100}]}`, code: codeNotFound},
+		{name: "unknown screen", fn: "apl", arg: `{"thid":"T1","screen":"SC9","prices":[{"format":"2D","price":100}]}`, code: codeNotFound},
+		{name: "unknown format", fn: "apl", arg: `{"thid":"T1","screen":"SC1","prices":[{"format":"4D","price":100}]}`, code: codeInvalidInput},
+		{name: "free tickets", fn: "apl", arg: `{"thid":"T1","screen":"SC1","prices":[{"format":"2D","price":0}]}`, code: codeInvalidInput},
+		{name: "category priced twice", fn: "apl", arg: `{"thid":"T1","screen":"SC1","prices":[{"format":"2D","price":100},{"format":"2D","price":200}]}`, code: codeInvalidInput},
+		{name: "by a cashier", fn: "apl", arg: prices, code: codeUnauthorized, role: roleCashier},
+		{name: "price list", fn: "apl", arg: prices},
+		{name: "quote without tickets", fn: "quote", arg: `{"thid":"T1","screen":"SC1","showcode":"1"}`, code: codeInvalidInput},
+		{name: "quote over the capacity", fn: "quote", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":5}`, code: codeCapacityExceeded},
+		{name: "quote of an unknown seat", fn: "quote", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["C1"]}`, code: codeNotFound},
+		{name: "quote of an unknown show", fn: "quote", arg: `{"thid":"T1","screen":"SC1","showcode":"3","ticketsold":1}`, code: codeInvalidInput},
+	})
+
+	quotes := []struct {
+		name  string
+		arg   string
+		total uint64
+	}{
+		{"tickets and seats", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"seats":["A1","B1"]}`, 15000 + 30000 + 15000},
+		{"show in IMAX", `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"1","seats":["A1","B2"]}`, 40000 + 40000},
+	}
+	saved := len(s.State)
+	for _, q := range quotes {
+		var result struct {
+			Quote SaleQuote `json:"quote"`
+		}
+		s.as(testMSP, "none")
+		s.result("quote", q.arg, &result)
+		if result.Quote.Total != q.total || !result.Quote.Listed {
+			t.Errorf("%s: quote :%+v, expected the total %d", q.name, result.Quote, q.total)
+		}
+	}
+	s.as(testMSP, roleManager)
+	if len(s.State) != saved {
+		t.Errorf("Quotes saved %d records", len(s.State)-saved)
+	}
+}
+
+func TestPricedSales(t *testing.T) {
+	s := newTestStub(t)
+	s.mustInvoke("athd", `{"thid":"T1","maxsoda":200,"sph":{"SC1":4,"SC2":4}`+refundPolicy+`}`)
+	start := strconv.FormatInt(day0+10*3600, 10)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","shows":[{"showcode":"1","start":`+start+`}]}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC2","thid":"T1","shows":[{"showcode":"1","start":`+start+`}]}`)
+	s.mustInvoke("asm", `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":2,"category":"Gold"},{"row":"B","seats":2}]}`)
+	s.mustInvoke("apl", `{"thid":"T1","screen":"SC1","prices":[{"category":"Gold","format":"2D","price":30000},{"format":"2D","price":15000}]}`)
+
+	runCases(t, s, []invokeCase{
+		{name: "price different from the price list", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, code: codeConflict},
+		{name: "price matching the price list", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":15000}`},
+	})
+
+	ids := ticketIDs(s.mustInvoke("sells", `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"]}`))
+	ids = append(ids, ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1}`))...)
+	ids = append(ids, ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC2","showcode":"1","ticketsold":1,"price":12000}`))...)
+	// Tickets of a screen without a price list are unpriced when the price is not provided
+	ids = append(ids, ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC2","showcode":"1","ticketsold":1}`))...)
+	for n, price := range []uint32{30000, 15000, 12000, 0} {
+		tr := Ticket{}
+		if !s.record(&tr, "Ticket", "T1", ids[n]) || tr.Price != price {
+			t.Errorf("Ticket %s :%+v, expected the price %d", ids[n], tr, price)
+		}
+	}
+
+	// Refunds are from the prices of the tickets cancelled
+	result := s.mustInvoke("ctkt", `{"thid":"T1","ticketid":"`+ids[0]+`"}`)
+	refund := Refund{}
+	if !s.record(&refund, "Refund", "T1", result["trxnid"].(string)) || refund.Amount != 30000 || refund.RefundAmount != 15000 {
+		t.Errorf("Refund of the gold seat :%+v, expected 50%% of 30000", refund)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// RecordQuery is the input of the rich queries. Selector must have the object type and the theatre ID
+type RecordQuery struct {
+	Selector map[string]interface{} `json:"selector"` // CouchDB selector on the queryable fields of the object type
+	PageSize int32                  `json:"pagesize"` // Min 1, Max 100. Defaults to 20
+	Bookmark string                 `json:"bookmark"` // Bookmark returned with the previous page
+}
+
+// queryRule has the fields of an object type that can be used in the selector along with "obj" and "thid"
+type queryRule struct {
+	fields    []string
+	ownerOnly bool // Only the organization owning the theatre can query the records
+}
+
+// queryRules lists the object types that can be queried
+var queryRules = map[string]queryRule{
+	"TheatreDetails": {},
+	"ShowDetails":    {fields: []string{"screen", "showdate", "moviename"}},
+	"Tickets":        {fields: []string{"screen", "showdate", "showcode", "moviename"}},
+	"SeatMap":        {fields: []string{"screen"}},
+	"PriceList":      {fields: []string{"screen"}},
+	"Coupon":         {fields: []string{"code"}},
+	"Invoice":        {fields: []string{"invdate", "invoiceno", "customer"}, ownerOnly: true},
+	"SodaDraw":       {fields: []string{"inventoryid", "ticketid"}},
+	"SodaInventory":  {fields: []string{"inventoryid"}},
+	"Ticket":         {fields: []string{"screen", "showdate", "showcode", "moviename", "status", "customer"}, ownerOnly: true},
+	"Refund":         {fields: []string{"screen", "showdate", "showcode"}, ownerOnly: true},
+	"DailyArchive":   {fields: []string{"bizdate"}, ownerOnly: true},
+}
+
+// Operators allowed in the selector. Operators combining conditions ($or, $not, ...) and $regex are not allowed
+var queryOperators = []string{"$eq", "$ne", "$gt", "$gte", "$lt", "$lte", "$in"}
+
+// isScalar checks if the selector value is a string, number or boolean
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case string, float64, bool:
+		return true
+	}
+	return false
+}
+
+// validateCondition checks the condition on a field. Condition is a value or an object of the allowed operators
+func validateCondition(field string, condition interface{}) error {
+	if isScalar(condition) {
+		return nil
+	}
+	operators, found := condition.(map[string]interface{})
+	if !found || len(operators) == 0 {
+		return newError(codeInvalidInput, "Invalid condition on the field %s", field)
+	}
+	for op, value := range operators {
+		if !contains(queryOperators, op) {
+			return newError(codeInvalidInput, "Operator %s is not allowed on the field %s", op, field)
+		}
+		if op != "$in" {
+			if !isScalar(value) {
+				return newError(codeInvalidInput, "Invalid value for %s on the field %s", op, field)
+			}
+			continue
+		}
+		values, found := value.([]interface{})
+		if !found {
+			return newError(codeInvalidInput, "Invalid value for %s on the field %s", op, field)
+		}
+		for _, v := range values {
+			if !isScalar(v) {
+				return newError(codeInvalidInput, "Invalid value for %s on the field %s", op, field)
+			}
+		}
+	}
+	return nil
+}
+
+// checkOwnerOrg checks if the client belongs to the organization owning the theatre
+func checkOwnerOrg(stub shim.ChaincodeStubInterface, thid string) error {
+	td, err := getTheatreDetails(stub, thid)
+	if err != nil {
+		return err
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return newError(codeUnauthorized, "Unable to get the organization of the client :%s", err.Error())
+	}
+	if td != nil && td.OwnerMSP != "" && td.OwnerMSP != mspID {
+		return newError(codeUnauthorized, "Records of the theatre %s can be queried only by the organization owning the theatre", thid)
+	}
+	return nil
+}
+
+// parseRecordQuery validates the rich query against the queryable fields of the object type and returns the
+// CouchDB query. Query is restricted to a single theatre
+func parseRecordQuery(stub shim.ChaincodeStubInterface, arg string) (*RecordQuery, string, error) {
+	var rq RecordQuery
+	err := json.Unmarshal([]byte(arg), &rq)
+	if err != nil || rq.Selector == nil {
+		return nil, "", newError(codeInvalidInput, "Invalid query. Expected the selector with obj and thid")
+	}
+
+	objType, _ := rq.Selector["obj"].(string)
+	rule, found := queryRules[objType]
+	if !found {
+		return nil, "", newError(codeInvalidInput, "Records of the object type %s can not be queried", objType)
+	}
+	thid, _ := rq.Selector["thid"].(string)
+	if thid == "" {
+		return nil, "", newError(codeInvalidInput, "Theatre ID is required to query the records")
+	}
+	for field, condition := range rq.Selector {
+		if field == "obj" || field == "thid" {
+			continue
+		}
+		if !contains(rule.fields, field) {
+			return nil, "", newError(codeInvalidInput, "Field %s can not be queried for %s", field, objType)
+		}
+		err = validateCondition(field, condition)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if rule.ownerOnly {
+		err = checkOwnerOrg(stub, thid)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if rq.PageSize <= 0 {
+		rq.PageSize = defaultPageSize
+	}
+	if rq.PageSize > maxPageSize {
+		rq.PageSize = maxPageSize
+	}
+
+	query, _ := json.Marshal(map[string]interface{}{"selector": rq.Selector})
+	return &rq, string(query), nil
+}
+
+// collectRecords reads all the records returned by the query
+func collectRecords(resultsIterator shim.StateQueryIteratorInterface) ([]json.RawMessage, error) {
+	records := []json.RawMessage{}
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Query iteration is Failed :%s", err.Error())
+		}
+		if len(record.Value) == 0 {
+			continue
+		}
+		records = append(records, json.RawMessage(record.Value))
+	}
+	return records, nil
+}
+
+// Get the records page-wise. Bookmark returned with a page is provided to get the next page
+func (s *ShowsManagement) getShowDetailsPage(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("getShowDetailsPage", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	rq, query, err := parseRecordQuery(stub, args[0])
+	if err != nil {
+		return failedResponse("getShowDetailsPage", "", err)
+	}
+
+	resultsIterator, metadata, err := stub.GetQueryResultWithPagination(query, rq.PageSize, rq.Bookmark)
+	if err != nil {
+		return errorResponse("getShowDetailsPage", codeLedgerError, "", "GetQueryResultWithPagination is Failed :"+err.Error())
+	}
+	defer resultsIterator.Close()
+
+	records, err := collectRecords(resultsIterator)
+	if err != nil {
+		return failedResponse("getShowDetailsPage", "", err)
+	}
+
+	resultData := map[string]interface{}{
+		"status":   "true",
+		"records":  records,
+		"fetched":  metadata.FetchedRecordsCount,
+		"bookmark": metadata.Bookmark,
+	}
+	respjson, _ := json.Marshal(resultData)
+	return shim.Success(respjson)
+}
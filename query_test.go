@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestRecordQueries(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	for _, dt := range []string{date1, "2024-03-03"} {
+		s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"`+dt+`","showcode":["1"]}`)
+	}
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`)
+
+	runCases(t, s, []invokeCase{
+		{name: "shows", fn: "gss", arg: `{"selector":{"obj":"ShowDetails","thid":"T1","showdate":{"$gte":"` + date1 + `"}}}`, role: "guest"},
+		{name: "no selector", fn: "gss", arg: `{"pagesize":10}`, code: codeInvalidInput},
+		{name: "no theatre", fn: "gss", arg: `{"selector":{"obj":"ShowDetails"}}`, code: codeInvalidInput},
+		{name: "object type not queryable", fn: "gss", arg: `{"selector":{"obj":"SodaWins","thid":"T1"}}`, code: codeInvalidInput},
+		{name: "field not queryable", fn: "gss", arg: `{"selector":{"obj":"ShowDetails","thid":"T1","cts":"x"}}`, code: codeInvalidInput},
+		{name: "combined conditions", fn: "gss", arg: `{"selector":{"obj":"ShowDetails","thid":"T1","$or":[{"screen":"SC1"}]}}`, code: codeInvalidInput},
+		{name: "regex", fn: "gss", arg: `{"selector":{"obj":"ShowDetails","thid":"T1","moviename":{"$regex":"L"}}}`, code: codeInvalidInput},
+		{name: "object in the condition", fn: "gss", arg: `{"selector":{"obj":"ShowDetails","thid":"T1","screen":{"$eq":{"a":1}}}}`, code: codeInvalidInput},
+		{name: "tickets of the owner", fn: "gss", arg: `{"selector":{"obj":"Ticket","thid":"T1","status":{"$in":["SOLD"]}}}`},
+		{name: "tickets of another organization", fn: "gss", arg: `{"selector":{"obj":"Ticket","thid":"T1"}}`, code: codeUnauthorized, role: "guest", msp: "Org2MSP"},
+		{name: "page of another organization", fn: "gssp", arg: `{"selector":{"obj":"Refund","thid":"T1"}}`, code: codeUnauthorized, msp: "Org2MSP"},
+	})
+
+	result := s.mustInvoke("gss", `{"selector":{"obj":"ShowDetails","thid":"T1","showdate":{"$gte":"`+date1+`"}}}`)
+	if records, _ := result["records"].([]interface{}); len(records) != 2 {
+		t.Errorf("Shows from %s :%v, expected 2 records", date1, result["records"])
+	}
+
+	pages := []struct {
+		bookmark string
+		fetched  float64
+	}{
+		{"", 2},
+		{"2", 1},
+		{"3", 0},
+	}
+	for _, p := range pages {
+		result := s.mustInvoke("gssp", `{"selector":{"obj":"ShowDetails","thid":"T1"},"pagesize":2,"bookmark":"`+p.bookmark+`"}`)
+		records, _ := result["records"].([]interface{})
+		if result["fetched"] != p.fetched || len(records) != int(p.fetched) {
+			t.Errorf("Page from %q :%v, expected %v records", p.bookmark, result, p.fetched)
+		}
+	}
+}
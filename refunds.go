@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// RefundRule gives the refund percentage for cancellations done at least MinutesBefore minutes before the show starts
+type RefundRule struct {
+	MinutesBefore int64 `json:"minsbefore"` // Min 0
+	Percent       uint8 `json:"percent"`    // Min 0, Max 100
+}
+
+// Cancellation is the input to cancel the tickets sold for a show
+type Cancellation struct {
+	TheatreID string   `json:"thid"`     // Alphanumeric
+	Screen    string   `json:"screen"`   // Alphanumeric
+	ShowDate  string   `json:"showdate"` // YYYY-MM-DD. Defaults to the current date of the theatre
+	ShowCode  string   `json:"showcode"` //
+	Count     uint32   `json:"count"`    // Tickets to cancel when the seats are not provided
+	Seats     []string `json:"seats"`    // Seats to cancel
+}
+
+// Refund is the refund entry recorded for every cancellation
+type Refund struct {
+	ObjType      string   `json:"obj"`
+	RefundID     string   `json:"refundid"`  // Transaction ID of the cancellation
+	TheatreID    string   `json:"thid"`      // Alphanumeric
+	Screen       string   `json:"screen"`    // Alphanumeric
+	ShowDate     string   `json:"showdate"`  // YYYY-MM-DD
+	ShowCode     string   `json:"showcode"`  //
+	Count        uint32   `json:"count"`     // Tickets cancelled
+	Seats        []string `json:"seats"`     // Seats cancelled, if any
+	TicketIDs    []string `json:"tickets"`   // Ticket records cancelled, if any
+	Amount       uint64   `json:"amount"`    // Amount paid for the cancelled tickets as per the ticket records
+	Percent      uint8    `json:"percent"`   // Refund percentage applied as per the refund policy of the theatre
+	RefundAmount uint64   `json:"refund"`    // Amount refunded to the customer
+	CancelledAt  int64    `json:"cancelled"` // epoch format. Transaction timestamp of the cancellation
+	CreateTs     string   `json:"cts"`       // RFC3339. Transaction timestamp of the first save
+	UpdateTs     string   `json:"uts"`       // RFC3339. Transaction timestamp of the latest save
+}
+
+// refundPercent returns the refund percentage for a cancellation done at the given time. No refund once the show starts
+func refundPercent(policy []RefundRule, showStart int64, now int64) uint8 {
+	if now >= showStart {
+		return 0
+	}
+	minutesLeft := (showStart - now) / 60
+	var percent uint8
+	best := int64(-1)
+	for _, rule := range policy {
+		if minutesLeft >= rule.MinutesBefore && rule.MinutesBefore > best {
+			best = rule.MinutesBefore
+			percent = rule.Percent
+		}
+	}
+	return percent
+}
+
+// Cancel the tickets sold for a show and refund as per the refund policy of the theatre
+func (s *ShowsManagement) cancelTickets(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("cancelTickets", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var cn Cancellation
+	err := json.Unmarshal([]byte(args[0]), &cn)
+	if err != nil {
+		return errorResponse("cancelTickets", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	// Either the ticket count or the seats to cancel must be provided
+	if (cn.Count == 0) == (len(cn.Seats) == 0) {
+		return errorResponse("cancelTickets", codeInvalidInput, strconv.Itoa(int(cn.Count)), "Invalid request to cancel tickets. Expected either ticket count or seats")
+	}
+
+	refund, err := applyCancellation(stub, cn, nil)
+	if err != nil {
+		return failedResponse("cancelTickets", cn.TheatreID, err)
+	}
+	_logger.Infof("cancelTickets:Tickets cancelled successfully")
+
+	result := map[string]interface{}{
+		"trxnid":          stub.GetTxID(),
+		"ticketCancelled": refund.Count,
+		"tickets":         refund.TicketIDs,
+		"refundPercent":   refund.Percent,
+		"refund":          refund.RefundAmount,
+		"message":         "Cancel ticket successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// unseatedTickets returns the tickets sold without seats for the show to cancel by the count, in the order of the
+// ticket IDs. Tickets are picked from the index of the show. Tickets sold before the ticket records were introduced
+// make up the rest of the count when there are not enough tickets in the SOLD status
+func unseatedTickets(stub shim.ChaincodeStubInterface, tkt Tickets, booked int, count uint32) ([]string, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("ShowTicket", []string{tkt.TheatreID, tkt.Screen, tkt.ShowDate, tkt.ShowCode})
+	if err != nil {
+		return nil, fmt.Errorf("GetStateByPartialCompositeKey is Failed :%s", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	var sold []string
+	recorded := 0
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Query iteration is Failed :%s", err.Error())
+		}
+		st := ShowTicket{}
+		if json.Unmarshal(record.Value, &st) != nil {
+			continue
+		}
+		t, err := getTicketRecord(stub, st.TheatreID, st.TicketID)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil || t.Status == ticketCancelled {
+			continue
+		}
+		recorded++
+		if t.Status == ticketSold && len(sold) < int(count) {
+			sold = append(sold, t.TicketID)
+		}
+	}
+	untracked := int(tkt.TicketsSold) - booked - recorded
+	if untracked < 0 {
+		untracked = 0
+	}
+	if len(sold)+untracked < int(count) {
+		return nil, newError(codeConflict, "Only %d tickets sold without seats can be cancelled for the show", len(sold)+untracked)
+	}
+	return sold, nil
+}
+
+// applyCancellation cancels the tickets of a show and records the refund. The given tickets, the tickets of the
+// cancelled seats and the tickets picked for the cancelled count are marked as cancelled
+func applyCancellation(stub shim.ChaincodeStubInterface, cn Cancellation, ticketIDs []string) (*Refund, error) {
+	thid := cn.TheatreID
+	sc := cn.Screen
+	st := cn.ShowCode
+
+	dt, err := resolveShowDate(stub, thid, cn.ShowDate)
+	if err != nil {
+		return nil, err
+	}
+
+	count := cn.Count
+	if len(cn.Seats) > 0 {
+		count = uint32(len(cn.Seats))
+	}
+
+	td, err := getTheatreDetails(stub, thid)
+	if err != nil {
+		return nil, err
+	}
+	if td == nil {
+		return nil, newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+
+	// Show start time is required to apply the refund policy
+	sd, err := getShowDetailsOfScreen(stub, thid, sc, dt)
+	if err != nil {
+		return nil, err
+	}
+	if sd == nil {
+		return nil, newError(codeNotFound, "Show details does not exists for the given details")
+	}
+	show, found := sd.show(st)
+	if !found || show.StartTime == 0 {
+		return nil, newError(codeInvalidInput, "Show start time is not available for the showcode %s", st)
+	}
+
+	tkt, err := getTickets(stub, thid, sc, dt, st)
+	if err != nil {
+		return nil, err
+	}
+	if tkt == nil || tkt.TicketsSold < count {
+		return nil, newError(codeConflict, "Cancelled tickets can not be more than the tickets sold for the show")
+	}
+
+	showSeats, err := getShowSeats(stub, thid, sc, dt, st)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tickets sold with seats can be cancelled only by the seats
+	if len(cn.Seats) == 0 && int(count) > int(tkt.TicketsSold)-len(showSeats.Booked) {
+		return nil, newError(codeInvalidInput, "Tickets sold with seats must be cancelled by the seats")
+	}
+
+	// Tickets cancelled by the count are marked as cancelled so that they can not be used or cancelled again
+	if len(cn.Seats) == 0 && len(ticketIDs) == 0 {
+		ticketIDs, err = unseatedTickets(stub, *tkt, len(showSeats.Booked), count)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Cancelled seats are available for sale again
+	if len(cn.Seats) > 0 {
+		for _, seat := range cn.Seats {
+			ticketID, booked := showSeats.Booked[seat]
+			if !booked {
+				return nil, newError(codeConflict, "Seat %s is not booked for the show", seat)
+			}
+			ticketIDs = append(ticketIDs, ticketID)
+			delete(showSeats.Booked, seat)
+		}
+		err = putShowSeats(stub, *td, showSeats)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to cancel the tickets")
+		}
+	}
+
+	// Seats booked before the ticket records were introduced do not have a ticket record and are not refunded
+	cancelled := []string{}
+	exchanged := uint32(0)
+	amount := uint64(0)
+	for _, ticketID := range ticketIDs {
+		if contains(cancelled, ticketID) {
+			continue
+		}
+		t, err := getTicketRecord(stub, thid, ticketID)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			continue
+		}
+		if t.Status != ticketSold {
+			return nil, newError(codeConflict, "Ticket %s can not be cancelled in %s status", ticketID, t.Status)
+		}
+		if t.WaterExch {
+			exchanged++
+		}
+		amount += uint64(t.Price)
+		t.Status = ticketCancelled
+		err = putTicketRecord(stub, *td, *t)
+		if err == nil && t.Seat == "" {
+			err = delRecord(stub, "ShowTicket", thid, t.Screen, t.ShowDate, t.ShowCode, t.TicketID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to cancel the ticket %s", ticketID)
+		}
+		cancelled = append(cancelled, ticketID)
+	}
+
+	tkt.TicketsSold = subCount(tkt.TicketsSold, count)
+	tkt.PopCornSold = subCount(tkt.PopCornSold, count)
+	// Water exchanged with soda is not returned with the cancelled tickets
+	tkt.WaterSold = subCount(tkt.WaterSold, subCount(count, exchanged))
+	tktjson, _ := json.Marshal(tkt)
+	err = putRecord(stub, *td, tktjson, "Tickets", thid, sc, dt, st)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to cancel the tickets")
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, fmt.Errorf("GetTxTimestamp is Failed :%s", err.Error())
+	}
+	percent := refundPercent(td.RefundPolicy, show.StartTime, now)
+
+	refund := Refund{
+		ObjType:      "Refund",
+		RefundID:     stub.GetTxID(),
+		TheatreID:    thid,
+		Screen:       sc,
+		ShowDate:     dt,
+		ShowCode:     st,
+		Count:        count,
+		Seats:        cn.Seats,
+		TicketIDs:    cancelled,
+		Amount:       amount,
+		Percent:      percent,
+		RefundAmount: amount * uint64(percent) / 100,
+		CancelledAt:  now,
+	}
+	refundjson, _ := json.Marshal(refund)
+	err = putRecord(stub, *td, refundjson, "Refund", thid, refund.RefundID)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to record the refund")
+	}
+	return &refund, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const refundPolicy = `,"refundpolicy":[{"minsbefore":1440,"percent":100},{"minsbefore":0,"percent":50}]`
+
+func TestCancelTickets(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", refundPolicy)
+	s.mustInvoke("asm", `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":2},{"row":"B","seats":2}]}`)
+	s.mustInvoke("sells", `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1","A2"],"price":10000}`)
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000}`)
+	other := ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":1,"price":10000}`))
+
+	runCases(t, s, []invokeCase{
+		{name: "neither count nor seats", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1"}`, code: codeInvalidInput},
+		{name: "both count and seats", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":1,"seats":["A1"]}`, code: codeInvalidInput},
+		{name: "unknown theatre", fn: "cancel", arg: `{"thid":"T9","screen":"SC1","showcode":"1","count":1}`, code: codeNotFound},
+		{name: "show of another day", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"1","count":1}`, code: codeNotFound},
+		{name: "more than sold", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":5}`, code: codeConflict},
+		{name: "seated tickets by the count", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":3}`, code: codeInvalidInput},
+		{name: "seat not booked", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["B1"]}`, code: codeConflict},
+		{name: "other organization", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"]}`, code: codeUnauthorized, msp: "Org2MSP"},
+		{name: "seat by a cashier", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"]}`, role: roleCashier},
+		{name: "cancelled seat", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"]}`, code: codeConflict},
+		{name: "cancelled seat sold again", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"],"price":10000}`},
+		{name: "tickets by the count", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":2}`},
+		{name: "no more unseated tickets", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":1}`, code: codeInvalidInput},
+	})
+
+	tkt := Tickets{}
+	s.record(&tkt, "Tickets", "T1", "SC1", date0, "1")
+	if tkt.TicketsSold != 2 || tkt.PopCornSold != 2 || tkt.WaterSold != 2 {
+		t.Errorf("Tickets after the cancellations :%+v, expected 2 tickets, popcorn and water sold", tkt)
+	}
+
+	// Tickets cancelled by the count are removed from the index of the show. Tickets of the other shows are kept
+	for showCode, expected := range map[string][]string{"1": nil, "2": other} {
+		var indexed []string
+		for _, record := range s.theatreRecords("ShowTicket", "T1", "SC1", date0, showCode) {
+			st := ShowTicket{}
+			json.Unmarshal(record.Value, &st)
+			indexed = append(indexed, st.TicketID)
+		}
+		if strings.Join(indexed, " ") != strings.Join(expected, " ") {
+			t.Errorf("Tickets of the show %s indexed :%v, expected %v", showCode, indexed, expected)
+		}
+	}
+	s.mustInvoke("cancel", `{"thid":"T1","screen":"SC1","showcode":"2","count":1}`)
+	tr := Ticket{}
+	if s.record(&tr, "Ticket", "T1", other[0]); tr.Status != ticketCancelled {
+		t.Errorf("Ticket of the other show :%+v, expected to be cancelled", tr)
+	}
+}
+
+func TestRefundPolicy(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", refundPolicy)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"`+date1+`","shows":[{"showcode":"1","start":`+strconv.FormatInt(day0+34*3600, 10)+`}]}`)
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":3,"price":10000}`)
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showdate":"`+date1+`","showcode":"1","ticketsold":1,"price":8000}`)
+
+	tests := []struct {
+		name    string
+		at      int64
+		arg     string
+		percent float64
+		refund  float64
+	}{
+		{"more than a day before the show", day0, `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"1","count":1}`, 100, 8000},
+		{"less than a day before the show", day0, `{"thid":"T1","screen":"SC1","showcode":"1","count":1}`, 50, 5000},
+		{"just before the show", day0 + 10*3600 - 60, `{"thid":"T1","screen":"SC1","showcode":"1","count":1}`, 50, 5000},
+		{"after the show starts", day0 + 10*3600, `{"thid":"T1","screen":"SC1","showcode":"1","count":1}`, 0, 0},
+	}
+	for _, tt := range tests {
+		s.now = tt.at
+		result := s.mustInvoke("cancel", tt.arg)
+		if result["refundPercent"] != tt.percent || result["refund"] != tt.refund {
+			t.Errorf("%s: refund %v%% of %v, expected %v%% of %v", tt.name, result["refundPercent"], result["refund"], tt.percent, tt.refund)
+		}
+		refund := Refund{}
+		if !s.record(&refund, "Refund", "T1", result["trxnid"].(string)) || float64(refund.RefundAmount) != tt.refund {
+			t.Errorf("%s: refund recorded :%+v", tt.name, refund)
+		}
+	}
+}
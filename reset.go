@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// DailyArchive is the history of a business day of a theatre, recorded on the daily reset
+type DailyArchive struct {
+	ObjType      string          `json:"obj"`
+	TheatreID    string          `json:"thid"`    // Alphanumeric
+	BusinessDate string          `json:"bizdate"` // YYYY-MM-DD. Business day archived
+	Tickets      []Tickets       `json:"tickets"` // Show-wise tickets of the shows till the business day
+	Soda         []SodaInventory `json:"soda"`    // Soda sold on the business day
+	ResetAt      int64           `json:"resetat"` // epoch format. Transaction timestamp of the reset
+	CreateTs     string          `json:"cts"`     // RFC3339. Transaction timestamp of the first save
+	UpdateTs     string          `json:"uts"`     // RFC3339. Transaction timestamp of the latest save
+}
+
+// ResetRequest is the input to reset the theatre for a new business day
+type ResetRequest struct {
+	TheatreID string `json:"thid"` // Alphanumeric
+}
+
+// pastShowRecords returns the records of the object type of the shows of a theatre before the given date. Records are
+// read by the partial key of the theatre and filtered by the show date of the key
+func pastShowRecords(stub shim.ChaincodeStubInterface, objType string, thid string, beforeDate string) ([]*queryresult.KV, error) {
+	records, err := getTheatreRecords(stub, objType, thid)
+	if err != nil {
+		return nil, err
+	}
+	var past []*queryresult.KV
+	for _, record := range records {
+		_, ids, err := stub.SplitCompositeKey(record.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s key :%s", objType, err.Error())
+		}
+		if len(ids) > 2 && ids[2] < beforeDate {
+			past = append(past, record)
+		}
+	}
+	return past, nil
+}
+
+// Reset the theatre for a new business day. Tickets of the past shows and the soda sold are archived date-wise,
+// past shows are closed and the soda count starts from 0 for the new day
+func (s *ShowsManagement) resetDay(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("resetDay", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var req ResetRequest
+	err := json.Unmarshal([]byte(args[0]), &req)
+	if err != nil {
+		return errorResponse("resetDay", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := req.TheatreID
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("resetDay", thid, err)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("resetDay:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("resetDay", errorCode(err), thid, "Unable to reset the theatre")
+	}
+	today := theatreDate(*td, now)
+	if td.BusinessDate >= today {
+		return errorResponse("resetDay", codeConflict, today, "Theatre is already reset for the day")
+	}
+
+	archive := DailyArchive{
+		ObjType:      "DailyArchive",
+		TheatreID:    thid,
+		BusinessDate: td.BusinessDate,
+		ResetAt:      now,
+	}
+	// Theatre details added before the daily reset was introduced do not have a business day
+	if archive.BusinessDate == "" {
+		archive.BusinessDate = theatreDate(*td, now-24*60*60)
+	}
+
+	// Tickets of the past shows are archived and removed along with the seats, holds and ticket index of those shows
+	tktRecords, err := pastShowRecords(stub, "Tickets", thid, today)
+	if err != nil {
+		return failedResponse("resetDay", thid, err)
+	}
+	for _, record := range tktRecords {
+		tkt := Tickets{}
+		err = json.Unmarshal(record.Value, &tkt)
+		if err != nil {
+			return errorResponse("resetDay", codeLedgerError, thid, "Existing ticket details Unmarshalling error")
+		}
+		archive.Tickets = append(archive.Tickets, tkt)
+	}
+
+	var closedKeys []string
+	for _, objType := range []string{"Tickets", "ShowSeats", "ShowHolds", "ShowTicket"} {
+		records := tktRecords
+		if objType != "Tickets" {
+			records, err = pastShowRecords(stub, objType, thid, today)
+			if err != nil {
+				return failedResponse("resetDay", thid, err)
+			}
+		}
+		for _, record := range records {
+			closedKeys = append(closedKeys, record.Key)
+		}
+	}
+	for _, key := range closedKeys {
+		err = stub.DelState(key)
+		if err != nil {
+			_logger.Errorf("resetDay:DelState is Failed :" + string(err.Error()))
+			return errorResponse("resetDay", errorCode(err), thid, "Unable to reset the theatre")
+		}
+	}
+
+	// Soda sold is archived and the count starts from 0 for the new day
+	sodaRecords, err := getTheatreRecords(stub, "SodaInventory", thid)
+	if err != nil {
+		return failedResponse("resetDay", thid, err)
+	}
+	for _, record := range sodaRecords {
+		soda := SodaInventory{}
+		err = json.Unmarshal(record.Value, &soda)
+		if err != nil {
+			return errorResponse("resetDay", codeLedgerError, thid, "Existing inventory details Unmarshalling error")
+		}
+		archive.Soda = append(archive.Soda, soda)
+
+		soda.SodaSold = 0
+		sodajson, _ := json.Marshal(soda)
+		err = putTheatreState(stub, *td, record.Key, sodajson)
+		if err != nil {
+			_logger.Errorf("resetDay:PutState is Failed :" + string(err.Error()))
+			return errorResponse("resetDay", errorCode(err), thid, "Unable to reset the soda inventory")
+		}
+	}
+
+	archivejson, _ := json.Marshal(archive)
+	err = putRecord(stub, *td, archivejson, "DailyArchive", thid, archive.BusinessDate)
+	if err != nil {
+		_logger.Errorf("resetDay:PutState is Failed :" + string(err.Error()))
+		return errorResponse("resetDay", errorCode(err), thid, "Unable to archive the business day")
+	}
+
+	td.BusinessDate = today
+	tdjson, _ := json.Marshal(td)
+	err = putRecord(stub, *td, tdjson, "TheatreDetails", thid)
+	if err != nil {
+		_logger.Errorf("resetDay:PutState is Failed :" + string(err.Error()))
+		return errorResponse("resetDay", errorCode(err), thid, "Unable to reset the theatre")
+	}
+	_logger.Infof("resetDay:Theatre reset successfully for :" + string(today))
+
+	result := map[string]interface{}{
+		"trxnid":       stub.GetTxID(),
+		"archived":     archive.BusinessDate,
+		"bizdate":      today,
+		"showsClosed":  len(archive.Tickets),
+		"sodaArchived": len(archive.Soda),
+		"message":      "Reset Theatre Success",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestResetDay(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"`+date1+`","shows":[{"showcode":"1","start":`+strconv.FormatInt(day0+34*3600, 10)+`}]}`)
+	s.mustInvoke("asm", `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":4}]}`)
+	s.mustInvoke("sells", `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"],"price":10000}`)
+	ids := ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":1,"price":10000}`))
+	s.mustInvoke("hold", `{"thid":"T1","screen":"SC1","showcode":"2","count":1}`)
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showdate":"`+date1+`","showcode":"1","ticketsold":1,"price":10000}`)
+	s.startSoda("T1", 100)
+	s.mustInvoke("exs", `{"thid":"T1","inventoryid":"ES13","ticketid":"`+ids[0]+`"}`)
+
+	runCases(t, s, []invokeCase{
+		{name: "same day", fn: "rst", arg: `{"thid":"T1"}`, code: codeConflict, at: day0 + 23*3600},
+		{name: "unknown theatre", fn: "rst", arg: `{"thid":"T9"}`, code: codeNotFound},
+		{name: "by a cashier", fn: "rst", arg: `{"thid":"T1"}`, code: codeUnauthorized, role: roleCashier},
+		{name: "by another organization", fn: "rst", arg: `{"thid":"T1"}`, code: codeUnauthorized, msp: "Org2MSP"},
+		{name: "next day", fn: "rst", arg: `{"thid":"T1"}`, at: day0 + 24*3600},
+		{name: "next day again", fn: "rst", arg: `{"thid":"T1"}`, code: codeConflict},
+	})
+
+	closed := []struct {
+		objType string
+		ids     []string
+	}{
+		{"Tickets", []string{"T1", "SC1", date0, "1"}},
+		{"Tickets", []string{"T1", "SC1", date0, "2"}},
+		{"ShowSeats", []string{"T1", "SC1", date0, "1"}},
+		{"ShowHolds", []string{"T1", "SC1", date0, "2"}},
+	}
+	for _, c := range closed {
+		if s.record(&map[string]interface{}{}, c.objType, c.ids...) {
+			t.Errorf("%s %v of the past show is not closed", c.objType, c.ids)
+		}
+	}
+	tkt := Tickets{}
+	if !s.record(&tkt, "Tickets", "T1", "SC1", date1, "1") || tkt.TicketsSold != 1 {
+		t.Errorf("Tickets of the show of the new day :%+v", tkt)
+	}
+	soda := SodaInventory{}
+	if !s.record(&soda, "SodaInventory", "T1", "ES13") || soda.SodaSold != 0 {
+		t.Errorf("Soda inventory for the new day :%+v", soda)
+	}
+	archive := DailyArchive{}
+	if !s.record(&archive, "DailyArchive", "T1", date0) {
+		t.Fatal("Business day is not archived")
+	}
+	if len(archive.Tickets) != 2 || len(archive.Soda) != 1 || archive.Soda[0].SodaSold != 1 {
+		t.Errorf("Archive of the business day :%+v, expected 2 shows and 1 soda sold", archive)
+	}
+	td := TheatreDetails{}
+	s.record(&td, "TheatreDetails", "T1")
+	if td.BusinessDate != date1 {
+		t.Errorf("Business date :%s, expected %s", td.BusinessDate, date1)
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// SeatMap is the seat layout of a screen. Seat IDs are formed as row + seat number (ex: "A1", "A2")
+type SeatMap struct {
+	ObjType   string    `json:"obj"`
+	TheatreID string    `json:"thid"`   // Alphanumeric
+	Screen    string    `json:"screen"` // Alphanumeric
+	Rows      []SeatRow `json:"rows"`   // Total seats of all the rows is set as the screen capacity in "TheatreDetails" struct
+	CreateTs  string    `json:"cts"`    // RFC3339. Transaction timestamp of the first save
+	UpdateTs  string    `json:"uts"`    // RFC3339. Transaction timestamp of the latest save
+}
+
+// SeatRow is a single row of seats on a screen. All the seats in a row belong to the same category
+type SeatRow struct {
+	Row      string `json:"row"`      // Alphabetic (ex: "A")
+	Seats    uint32 `json:"seats"`    // Seats are numbered from 1 to Seats
+	Category string `json:"category"` // ex: "Gold", "Silver"
+}
+
+// ShowSeats keeps track of the seats booked for a show.
+type ShowSeats struct {
+	ObjType   string            `json:"obj"`
+	TheatreID string            `json:"thid"`     // Alphanumeric
+	Screen    string            `json:"screen"`   // Alphanumeric
+	ShowDate  string            `json:"showdate"` // YYYY-MM-DD
+	ShowCode  string            `json:"showcode"` //
+	Booked    map[string]string `json:"booked"`   // SeatID -> Ticket ID
+	CreateTs  string            `json:"cts"`      // RFC3339. Transaction timestamp of the first save
+	UpdateTs  string            `json:"uts"`      // RFC3339. Transaction timestamp of the latest save
+}
+
+// SeatSale is the input to sell specific seats of a show
+type SeatSale struct {
+	TheatreID string   `json:"thid"`      // Alphanumeric
+	MovieName string   `json:"moviename"` //
+	Screen    string   `json:"screen"`    // Alphanumeric
+	ShowDate  string   `json:"showdate"`  // YYYY-MM-DD. Defaults to the current date of the theatre
+	ShowCode  string   `json:"showcode"`  //
+	Seats     []string `json:"seats"`     // Seat IDs (ex: ["A1", "A2"])
+	TicketSale
+}
+
+// AllocatedSeat is a seat allocated to the customer on a sale
+type AllocatedSeat struct {
+	SeatID   string `json:"seat"`
+	Category string `json:"category"`
+	Price    uint32 `json:"price"`
+	TicketID string `json:"ticketid"`
+}
+
+// seatCategories returns seat ID -> category of all the seats in the seat map
+func (sm SeatMap) seatCategories() map[string]string {
+	seats := make(map[string]string)
+	for _, row := range sm.Rows {
+		for n := 1; n <= int(row.Seats); n++ {
+			seats[row.Row+strconv.Itoa(n)] = row.Category
+		}
+	}
+	return seats
+}
+
+// seatsTaken returns the seats sold or held for the shows of the screen, sorted by the seat ID
+func seatsTaken(stub shim.ChaincodeStubInterface, thid string, sc string, now int64) ([]string, error) {
+	taken := make(map[string]bool)
+	for _, objType := range []string{"ShowSeats", "ShowHolds"} {
+		resultsIterator, err := stub.GetStateByPartialCompositeKey(objType, []string{thid, sc})
+		if err != nil {
+			return nil, fmt.Errorf("GetStateByPartialCompositeKey is Failed :%s", err.Error())
+		}
+		defer resultsIterator.Close()
+		for resultsIterator.HasNext() {
+			record, err := resultsIterator.Next()
+			if err != nil {
+				return nil, fmt.Errorf("Query iteration is Failed :%s", err.Error())
+			}
+			showSeats := ShowSeats{}
+			holds := ShowHolds{}
+			if objType == "ShowSeats" {
+				err = json.Unmarshal(record.Value, &showSeats)
+			} else {
+				err = json.Unmarshal(record.Value, &holds)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Existing %s Unmarshalling error", objType)
+			}
+			for seat := range showSeats.Booked {
+				taken[seat] = true
+			}
+			for seat := range holds.liveSeats(now) {
+				taken[seat] = true
+			}
+		}
+	}
+	seats := []string{}
+	for seat := range taken {
+		seats = append(seats, seat)
+	}
+	sort.Strings(seats)
+	return seats, nil
+}
+
+// Add or modify the seat map of a screen in a theatre. Seat count of the seat map is set as the capacity of the screen.
+// Seat map can be replaced as long as it has the seats sold and held for the shows of the screen
+func (s *ShowsManagement) addSeatMap(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("addSeatMap", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var sm SeatMap
+	err := json.Unmarshal([]byte(args[0]), &sm)
+	if err != nil {
+		return errorResponse("addSeatMap", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := sm.TheatreID
+	sc := sm.Screen
+
+	theatreDetails, err := getRecord(stub, "TheatreDetails", thid)
+	if err != nil {
+		return errorResponse("addSeatMap", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+	if theatreDetails == nil {
+		return errorResponse("addSeatMap", codeNotFound, thid, "Theatre details does not exists for :"+thid)
+	}
+
+	td := TheatreDetails{}
+	err = json.Unmarshal(theatreDetails, &td)
+	if err != nil {
+		return errorResponse("addSeatMap", codeLedgerError, "", "Existing theatre details Unmarshalling error")
+	}
+	if td.SeatsPerHall[sc] == 0 {
+		return errorResponse("addSeatMap", codeNotFound, sc, "This screen details does not exists for the theatre")
+	}
+
+	// Validate the rows. Row names must be unique
+	totalSeats := uint64(0)
+	rows := make(map[string]bool)
+	for _, row := range sm.Rows {
+		if row.Row == "" || row.Seats == 0 || rows[row.Row] {
+			return errorResponse("addSeatMap", codeInvalidInput, row.Row, "Invalid or duplicate row in the seat map")
+		}
+		rows[row.Row] = true
+		totalSeats += uint64(row.Seats)
+	}
+	if totalSeats > math.MaxUint32 {
+		return errorResponse("addSeatMap", codeInvalidInput, strconv.FormatUint(totalSeats, 10), "Seat count in the seat map is too large")
+	}
+
+	// Seats sold and held for the shows of the screen must be in the new seat map
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("addSeatMap:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("addSeatMap", errorCode(err), thid, "Unable to add the seat map")
+	}
+	inUse, err := seatsInUse(stub, thid, sc, now)
+	if err != nil {
+		return failedResponse("addSeatMap", thid, err)
+	}
+	if !withinCapacity(uint32(totalSeats), inUse) {
+		return errorResponse("addSeatMap", codeConflict, strconv.FormatUint(totalSeats, 10), "Seat count in the seat map can not be less than the "+strconv.Itoa(inUse)+" tickets sold and held for a show of the screen")
+	}
+	taken, err := seatsTaken(stub, thid, sc, now)
+	if err != nil {
+		return failedResponse("addSeatMap", thid, err)
+	}
+	categories := sm.seatCategories()
+	for _, seat := range taken {
+		if _, found := categories[seat]; !found {
+			return errorResponse("addSeatMap", codeConflict, seat, "Seat sold or held for a show of the screen is not in the seat map")
+		}
+	}
+
+	sm.ObjType = "SeatMap"
+	smjson, _ := json.Marshal(sm)
+	err = putRecord(stub, td, smjson, "SeatMap", thid, sc)
+	if err == nil && td.SeatsPerHall[sc] != uint32(totalSeats) {
+		td.SeatsPerHall[sc] = uint32(totalSeats)
+		tdjson, _ := json.Marshal(td)
+		err = putRecord(stub, td, tdjson, "TheatreDetails", thid)
+	}
+	if err != nil {
+		_logger.Errorf("addSeatMap:PutState is Failed :" + string(err.Error()))
+		return errorResponse("addSeatMap", errorCode(err), thid, "Unable to add the seat map")
+	}
+	_logger.Infof("addSeatMap:Seat map added succesfully for theatre :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":  stub.GetTxID(),
+		"seats":   totalSeats,
+		"message": "Add Seat Map Success",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Sell specific seats of a show. 1 popcorn and 1 water bottle issued per seat
+func (s *ShowsManagement) sellSeats(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("sellSeats", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var sale SeatSale
+	err := json.Unmarshal([]byte(args[0]), &sale)
+	if err != nil {
+		return errorResponse("sellSeats", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := sale.TheatreID
+	sc := sale.Screen
+	st := sale.ShowCode
+
+	dt, err := resolveShowDate(stub, thid, sale.ShowDate)
+	if err != nil {
+		return failedResponse("sellSeats", thid, err)
+	}
+
+	if len(sale.Seats) == 0 {
+		return errorResponse("sellSeats", codeInvalidInput, strconv.Itoa(len(sale.Seats)), "Invalid request to sell seats. Expected 1 or more seats")
+	}
+
+	// Check if the show details are available
+	showDetails, err := getRecord(stub, "ShowDetails", thid, sc, dt)
+	if err != nil {
+		return errorResponse("sellSeats", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+	if showDetails == nil {
+		return errorResponse("sellSeats", codeNotFound, thid, "Show details does not exists for the given details")
+	}
+
+	// Seats can be sold only on the screens with a seat map
+	sm, err := getSeatMap(stub, thid, sc)
+	if err != nil {
+		return failedResponse("sellSeats", thid, err)
+	}
+	if sm == nil {
+		return errorResponse("sellSeats", codeNotFound, sc, "Seat map does not exists for the screen")
+	}
+
+	// Get the seats already booked and held for the show
+	showSeats, err := getShowSeats(stub, thid, sc, dt, st)
+	if err != nil {
+		return failedResponse("sellSeats", thid, err)
+	}
+	holds, err := getShowHolds(stub, thid, sc, dt, st)
+	if err != nil {
+		return failedResponse("sellSeats", thid, err)
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("sellSeats:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("sellSeats", errorCode(err), "", "Unable to sell the seats")
+	}
+	heldSeats := holds.liveSeats(now)
+
+	// Validate the requested seats against the seat map and the existing bookings
+	categories := sm.seatCategories()
+	var allocated []AllocatedSeat
+	for n, seat := range sale.Seats {
+		category, found := categories[seat]
+		if !found {
+			return errorResponse("sellSeats", codeNotFound, seat, "Seat does not exists on the screen")
+		}
+		if _, booked := showSeats.Booked[seat]; booked {
+			return errorResponse("sellSeats", codeConflict, seat, "Seat is already booked for the show")
+		}
+		if heldSeats[seat] {
+			return errorResponse("sellSeats", codeConflict, seat, "Seat is on hold for the show")
+		}
+		showSeats.Booked[seat] = ticketID(stub.GetTxID(), n+1)
+		allocated = append(allocated, AllocatedSeat{SeatID: seat, Category: category, TicketID: showSeats.Booked[seat]})
+	}
+
+	// Seats are priced from the price list of the screen at the time of the sale
+	quote, err := quoteSale(stub, thid, sc, dt, st, 0, sale.Seats, sale.Price)
+	if err != nil {
+		return failedResponse("sellSeats", thid, err)
+	}
+	coupon, use, err := applyCoupon(stub, thid, sc, dt, quote, sale.TicketSale)
+	if err != nil {
+		return failedResponse("sellSeats", sale.Coupon, err)
+	}
+	for n := range allocated {
+		allocated[n].Price = quote.Tickets[n].Price - quote.Tickets[n].Discount
+	}
+
+	// Seat-wise sale is also added to the show-wise ticket count used for capacity checks
+	tkt, err := getTickets(stub, thid, sc, dt, st)
+	if err != nil {
+		return failedResponse("sellSeats", thid, err)
+	}
+	if tkt == nil {
+		tkt = &Tickets{TheatreID: thid, MovieName: sale.MovieName, Screen: sc, ShowDate: dt, ShowCode: st}
+	}
+
+	// Tickets sold without seats also count against the screen capacity
+	th, err := getRecord(stub, "TheatreDetails", thid)
+	if err != nil {
+		return errorResponse("sellSeats", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+
+	td := TheatreDetails{}
+	err = json.Unmarshal(th, &td)
+	if err != nil {
+		return errorResponse("sellSeats", codeLedgerError, "", "Existing theatre details Unmarshalling error")
+	}
+	err = td.salesOpen(sc)
+	if err != nil {
+		return failedResponse("sellSeats", thid, err)
+	}
+
+	if !withinCapacity(td.SeatsPerHall[sc], int(tkt.TicketsSold), holds.liveCount(now), len(allocated)) {
+		return errorResponse("sellSeats", codeCapacityExceeded, "", "Enough tickets not available")
+	}
+
+	tkt.ObjType = "Tickets"
+	err = tkt.addSold(uint32(len(allocated)))
+	if err != nil {
+		return failedResponse("sellSeats", thid, err)
+	}
+
+	err = putShowSeats(stub, td, showSeats)
+	if err != nil {
+		_logger.Errorf("sellSeats:PutState is Failed :" + string(err.Error()))
+		return errorResponse("sellSeats", errorCode(err), thid, "Unable to sell the seats")
+	}
+
+	tktjson, _ := json.Marshal(tkt)
+	err = putRecord(stub, td, tktjson, "Tickets", thid, sc, dt, st)
+	if err != nil {
+		_logger.Errorf("sellSeats:PutState is Failed :" + string(err.Error()))
+		return errorResponse("sellSeats", errorCode(err), thid, "Unable to sell the seats")
+	}
+
+	var invoice *Invoice
+	tickets, err := issueTickets(stub, td, *tkt, *quote, sale.Customer, 0)
+	if err == nil {
+		err = useCoupon(stub, td, coupon, use)
+	}
+	if err == nil {
+		invoice, err = issueInvoice(stub, td, sale.Customer, ticketLines(*quote, tickets))
+	}
+	if err != nil {
+		_logger.Errorf("sellSeats:PutState is Failed :" + string(err.Error()))
+		return errorResponse("sellSeats", errorCode(err), thid, "Unable to issue the tickets")
+	}
+	_logger.Infof("sellSeats:Seats sold successfully")
+
+	result := map[string]interface{}{
+		"trxnid":     stub.GetTxID(),
+		"ticketSold": len(allocated),
+		"seats":      allocated,
+		"format":     quote.Format,
+		"discount":   quote.Discount,
+		"amount":     quote.Total,
+		"invoiceno":  invoice.InvoiceNo,
+		"tax":        invoice.Tax,
+		"total":      invoice.Total,
+		"message":    "Sell seats successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// getShowSeats fetches the seats booked for a show. Returns an empty booking if no seat is booked yet
+func getShowSeats(stub shim.ChaincodeStubInterface, thid string, sc string, dt string, st string) (ShowSeats, error) {
+	showSeats := ShowSeats{ObjType: "ShowSeats", TheatreID: thid, Screen: sc, ShowDate: dt, ShowCode: st}
+	bookedDetails, err := getRecord(stub, "ShowSeats", thid, sc, dt, st)
+	if err != nil {
+		return showSeats, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if bookedDetails != nil {
+		err = json.Unmarshal(bookedDetails, &showSeats)
+		if err != nil {
+			return showSeats, fmt.Errorf("Existing seat booking details Unmarshalling error")
+		}
+	}
+	if showSeats.Booked == nil {
+		showSeats.Booked = make(map[string]string)
+	}
+	return showSeats, nil
+}
+
+// putShowSeats saves the seats booked for a show
+func putShowSeats(stub shim.ChaincodeStubInterface, td TheatreDetails, showSeats ShowSeats) error {
+	showSeats.ObjType = "ShowSeats"
+	seatsjson, _ := json.Marshal(showSeats)
+	return putRecord(stub, td, seatsjson, "ShowSeats", showSeats.TheatreID, showSeats.Screen, showSeats.ShowDate, showSeats.ShowCode)
+}
+
+// getSeatMap fetches the seat map of a screen. Returns nil if the seat map is not added for the screen
+func getSeatMap(stub shim.ChaincodeStubInterface, thid string, sc string) (*SeatMap, error) {
+	seatMapDetails, err := getRecord(stub, "SeatMap", thid, sc)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if seatMapDetails == nil {
+		return nil, nil
+	}
+	sm := SeatMap{}
+	err = json.Unmarshal(seatMapDetails, &sm)
+	if err != nil {
+		return nil, fmt.Errorf("Existing seat map Unmarshalling error")
+	}
+	return &sm, nil
+}
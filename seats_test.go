@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestSeatMapAndSeatSales(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+
+	runCases(t, s, []invokeCase{
+		{name: "seat map of an unknown theatre", fn: "asm", arg: `{"thid":"T9","screen":"SC1","rows":[{"row":"A","seats":4}]}`, code: codeNotFound},
+		{name: "seat map of an unknown screen", fn: "asm", arg: `{"thid":"T1","screen":"SC9","rows":[{"row":"A","seats":4}]}`, code: codeNotFound},
+		{name: "row without seats", fn: "asm", arg: `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":4},{"row":"B","seats":0}]}`, code: codeInvalidInput},
+		{name: "duplicate row", fn: "asm", arg: `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":2},{"row":"A","seats":2}]}`, code: codeInvalidInput},
+		{name: "seats sold without a seat map", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"],"price":10000}`, code: codeNotFound},
+		{name: "seat map", fn: "asm", arg: `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":2,"category":"Silver"},{"row":"B","seats":2,"category":"Gold"}]}`},
+		{name: "seats of an unknown show", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"1","seats":["A1"],"price":10000}`, code: codeInvalidInput},
+		{name: "seats", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1","B2"],"price":10000}`},
+		{name: "seat already booked", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1"],"price":10000}`, code: codeConflict},
+		{name: "seat not on the screen", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["C1"],"price":10000}`, code: codeNotFound},
+		{name: "same seat of another show", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"2","seats":["A1"],"price":10000}`},
+		{name: "tickets without seats", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000}`},
+		{name: "seat over the capacity", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A2"],"price":10000}`, code: codeCapacityExceeded},
+		{name: "seats not provided", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":[],"price":10000}`, code: codeInvalidInput},
+		{name: "seats sold by a client of another role", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"2","seats":["A2"],"price":10000}`, code: codeUnauthorized, role: "none"},
+	})
+
+	ss := ShowSeats{}
+	if !s.record(&ss, "ShowSeats", "T1", "SC1", date0, "1") {
+		t.Fatal("Seats of the show are not saved")
+	}
+	if len(ss.Booked) != 2 || ss.Booked["A1"] == "" || ss.Booked["B2"] == "" {
+		t.Errorf("Seats booked for the show :%v, expected A1 and B2", ss.Booked)
+	}
+}
+
+func TestSellSeatsResult(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	s.mustInvoke("asm", `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":2,"category":"Silver"},{"row":"B","seats":2,"category":"Gold"}]}`)
+
+	result := s.mustInvoke("sells", `{"thid":"T1","screen":"SC1","showcode":"1","seats":["B1","A2"],"price":10000}`)
+	seats, _ := result["seats"].([]interface{})
+	if len(seats) != 2 {
+		t.Fatalf("Seats allocated :%v, expected 2 seats", result["seats"])
+	}
+	categories := map[string]string{}
+	for _, seat := range seats {
+		allocated := seat.(map[string]interface{})
+		categories[allocated["seat"].(string)], _ = allocated["category"].(string)
+	}
+	if categories["B1"] != "Gold" || categories["A2"] != "Silver" {
+		t.Errorf("Categories of the seats allocated :%v", categories)
+	}
+}
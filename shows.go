@@ -1,640 +1,954 @@
-package main
-
-// Assumption - Fixed showcode for all movie halls and theatres (ex: "1", "2", "3", "4")
-// Assumption - Theatres will trigger reset(through  API) of available tickets and inventory for all shows every day before the first show
-// Assumption - Movie names are in English and no Unicode characters
-// Assumption - Max Sodas available per day is 200. Theatres have to reset the available count everyday
-// Assumption - Theatre details will be added before adding screen-wise show details, selling tickets or exchanging soda
-// Assumtion - Only 1 cafeteria inventory per theatre
-// Assumption - All the mandatory parameters will be provided as per the function requirement i,e non-null values.
-
-// All inputs are case sensitive
-// More than one theatre can add the data on to Blockchain
-
-/********************* Sample Peer commands for various functions ************************************
-
-peer chaincode invoke -n moviecc -c '{"args":["asd","{\"moviename\":\"Lucy\", \"screen\":\"1\", \"thid\":\"Theatre1\", \"showcode\": [\"1\",\"2\",\"3\",\"4\"]}"]}' -C movieTheatre
-
-peer chaincode invoke -n moviecc -c '{"args":["gss","{\"selector\": {\"thid\": \"Theatre1\"}}"]}' -C movieTheatre
-
-peer chaincode invoke -n moviecc -c '{"args":["athd","{\"thid\":\"Theatre1\", \"maxsoda\": 200, \"sph\": {\"SC1\": 100 ,\"SC2\": 100,\"SC3\": 100,\"SC4\": 100,\"SC5\": 100}, \"cts\":\"1606791828\", \"uts\": \"1606791828\"}"]}' -C movieTheatre
-
-peer chaincode invoke -n moviecc -c '{"args":["exs","{\"thid\":\"Theatre1\", \"inventoryid\": \"ES13\", \"cts\":\"1606791828\", \"uts\": \"1606791828\"}"]}' -C movieTheatre
-
-peer chaincode invoke -n moviecc -c '{"args":["sell","{\"thid\": \"Theatre1\", \"moviename\":\"Lucy\", \"screen\":\"SC1\", \"showcode\":\"2\", \"ticketsold\": 3, \"cts\":\"1606791828\", \"uts\": \"1606791828\"}"]}' -C movieTheatre
-
-***********************************************************************************************************/
-
-import (
-	"encoding/json"
-	"fmt"
-	"math/rand"
-	"strconv"
-	"strings"
-	"time"
-
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	pb "github.com/hyperledger/fabric/protos/peer"
-)
-
-var _logger = shim.NewLogger("Shows-logger")
-
-// ShowDetails maintains the show related details.
-type ShowDetails struct {
-	ObjType   string    `json:"obj"`
-	MovieName string    `json:"moviename"`
-	Screen    string    `json:"screen"` // Alphanumeric
-	TheatreID string    `json:"thid"`   // Alphanumeric
-	ShowCode  [4]string `json:"showcode"`
-	CreateTs  string    `json:"cts"` // epoch format
-	UpdateTs  string    `json:"uts"` // epoch format
-}
-
-// TheatreDetails has movie hall-wise max capacity and inventory capacity details
-type TheatreDetails struct {
-	ObjType       string           `json:"obj"`
-	TheatreID     string           `json:"thid"`    // Alphanumeric. Unique for each theatre
-	SeatsPerHall  map[string]uint8 `json:"sph"`     // (Max seats count can be set by the theatre. Not hardcoded as 100(as per instruction) to keep it configurable)
-	MaxSodaPerDay uint8            `json:"maxsoda"` // Min 0, Max - Count can be set by the theatre. Not hardcoded as 200(as per instruction) to keep it configurable
-	CreateTs      string           `json:"cts"`     // epoch format
-	UpdateTs      string           `json:"uts"`     // epoch format
-}
-
-// SodaInventory keeps track of day-wise soda sale.
-type SodaInventory struct {
-	ObjType     string `json:"obj"`
-	TheatreID   string `json:"thid"`        // Alphanumeric
-	InventoryID string `json:"inventoryid"` // Alphanumeric
-	SodaSold    uint8  `json:"soda"`        // Min 0, Max - Count set by the theatre in "TheatreDetails" struct.
-	CreateTs    string `json:"cts"`         // epoch format
-	UpdateTs    string `json:"uts"`         // epoch format
-}
-
-// Tickets is the show-wise state data.
-type Tickets struct {
-	ObjType     string `json:"obj"`
-	TheatreID   string `json:"thid"`       // Alphanumeric
-	MovieName   string `json:"moviename"`  //
-	Screen      string `json:"screen"`     // Alphanumeric
-	ShowCode    string `json:"showcode"`   //
-	TicketsSold uint8  `json:"ticketsold"` //  Min 0, Max - Count set by the theatre in "TheatreDetails" struct.
-	PopCornSold uint8  `json:"pcsold"`     //  Min 0, Max - Equals tickets TicketsSold
-	WaterSold   uint8  `json:"watersold"`  //  Min 0, Max - Equals tickets TicketsSold
-	CreateTs    string `json:"cts"`        // epoch format
-	UpdateTs    string `json:"uts"`        // epoch format
-}
-
-// ShowsManagement is the chaincode construct
-type ShowsManagement struct {
-}
-
-var jsonResp string
-var errorKey string
-var errorData string
-
-// Init Initialises the chaincode
-func (s *ShowsManagement) Init(stub shim.ChaincodeStubInterface) pb.Response {
-	_logger.Info("######### ShowsMangement is Initialized successfully #########")
-	return shim.Success(nil)
-}
-
-// Invoke gets called when interacting with ledger
-func (s *ShowsManagement) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
-	fn, args := stub.GetFunctionAndParameters()
-	_logger.Info("ShowsMangement CC is invoked with function: ", string(fn))
-
-	switch fn {
-	case "asd":
-		return s.addOrModifyShowDetails(stub, args)
-	case "exs":
-		return s.exchangeSoda(stub, args)
-	case "athd":
-		return s.addTheatreDetails(stub, args)
-	case "gss":
-		return s.getShowDetails(stub, args)
-	case "sell":
-		return s.sellTicket(stub, args)
-	default:
-		_logger.Errorf("Unknown Function Invoked. Available Functions : asd,athd,gss,sell")
-		jsonResp = "{\"Data\":" + fn + ",\"ErrorDetails\":\"Available Functions:asd,athd,gss,sell\"}"
-		return shim.Error(jsonResp)
-	}
-}
-
-// Add movie name and show timings for a particular screen in a theatre
-func (s *ShowsManagement) addOrModifyShowDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-
-	if len(args) != 1 {
-		_logger.Info("addOrModifyShowDetails: Incorrect number of arguments provided for the transaction.")
-		jsonResp = "{\"Data\":" + strconv.Itoa(len(args)) + ",\"ErrorDetails\":\"Invalid Number of argumnets provided for transaction\"}"
-		return shim.Error(jsonResp)
-	}
-
-	var sd ShowDetails
-	err := json.Unmarshal([]byte(args[0]), &sd)
-	if err != nil {
-		errorKey = args[0]
-		errorData = "Invalid json provided as input"
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("addOrModifyShowDetails:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	sc := sd.Screen
-	thid := sd.TheatreID
-
-	/* Check if the theatre details is added for the movie hall. If not present, do not process the request */
-	theatreDetails, err := stub.GetState(thid)
-
-	if err != nil {
-		errorKey = thid
-		replaceErr := strings.Replace(err.Error(), "\"", " ", -1)
-		errorData = "GetState is Failed :" + replaceErr
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("addOrModifyShowDetails:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-	if theatreDetails == nil {
-		errorData = "Theatre details does not exists for :" + string(thid)
-		jsonResp = "{\"Data\":" + thid + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("addOrModifyShowDetails:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	compositeKey := thid + sc // Form composite key with TheatreID and ScreenCode
-	// Check if the movie-hall/screen details is present with the theatre
-	screenExists, err := stub.GetState(compositeKey)
-
-	if err != nil {
-		errorKey = thid
-		replaceErr := strings.Replace(err.Error(), "\"", " ", -1)
-		errorData = "GetState is Failed :" + replaceErr
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("addOrModifyShowDetails:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-	if screenExists != nil {
-
-		screendetail := ShowDetails{}
-		err = json.Unmarshal(screenExists, &screendetail)
-		if err != nil {
-			errorData = "Existing show details Unmarshalling error"
-			jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"" + errorData + "\"}"
-			_logger.Error("addOrModifyShowDetails:" + string(jsonResp))
-			return shim.Error(jsonResp)
-		}
-
-		sd.ObjType = "ShowDetails"
-		sd.UpdateTs = screendetail.UpdateTs
-		sdjson, _ := json.Marshal(sd)
-		err = stub.PutState(compositeKey, sdjson)
-		if err != nil {
-			_logger.Errorf("addOrModifyShowDetails:PutState is Failed :" + string(err.Error()))
-			jsonResp = "{\"Data\":" + thid + ",\"ErrorDetails\":\"Unable to add the show details\"}"
-			return shim.Error(jsonResp)
-		}
-		_logger.Infof("addOrModifyShowDetails:Show details added succesfully for theatre :" + string(thid))
-
-	} else {
-
-		// Validate the screen detail against the respective theatre details
-		td := TheatreDetails{}
-		err = json.Unmarshal(theatreDetails, &td)
-		if err != nil {
-			errorData = "Existing theatre details Unmarshalling error"
-			jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"" + errorData + "\"}"
-			_logger.Error("addOrModifyShowDetails:" + string(jsonResp))
-			return shim.Error(jsonResp)
-		}
-		if td.SeatsPerHall[sc] == 0 {
-			errorData = "This screen details does not exists for the theatre"
-			jsonResp = "{\"Data\":" + sc + ",\"ErrorDetails\":\"" + errorData + "\"}"
-			_logger.Error("addOrModifyShowDetails:" + string(jsonResp))
-			return shim.Error(jsonResp)
-		}
-
-		sd.ObjType = "ShowDetails"
-		sdjson, _ := json.Marshal(sd)
-		err = stub.PutState(compositeKey, sdjson)
-		if err != nil {
-			_logger.Errorf("addOrModifyShowDetails:PutState is Failed :" + string(err.Error()))
-			jsonResp = "{\"Data\":" + thid + ",\"ErrorDetails\":\"Unable to add the show details\"}"
-			return shim.Error(jsonResp)
-		}
-		_logger.Infof("addOrModifyShowDetails:Show details added succesfully for theatre :" + string(thid))
-	}
-	result := map[string]interface{}{
-		"trxnid":  stub.GetTxID(),
-		"message": "Add Show Detail Success",
-	}
-	respjson, _ := json.Marshal(result)
-	return shim.Success(respjson)
-}
-
-// Get show details on a particular screen of a movie theatre
-func (s *ShowsManagement) getShowDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting screen number to query")
-	}
-
-	var records []ShowDetails
-	queryString := args[0]
-
-	valAsbytes, err := stub.GetQueryResult(queryString)
-
-	if err != nil {
-		jsonResp = "{\"Error\":\"Failed to get state for " + queryString + "\"}"
-		return shim.Error(jsonResp)
-	} else if valAsbytes == nil {
-		jsonResp = "{\"Error\":\"Record does not exist: " + queryString + "\"}"
-		return shim.Error(jsonResp)
-	}
-
-	for valAsbytes.HasNext() {
-		record := ShowDetails{}
-		recordBytes, _ := valAsbytes.Next()
-		if (string(recordBytes.Value)) == "" {
-			continue
-		}
-		err = json.Unmarshal(recordBytes.Value, &record)
-		if err != nil {
-			replaceErr := strings.Replace(err.Error(), "\"", " ", -1)
-			errorData = "Unmarshalling Error :" + replaceErr
-			jsonResp = "{\"Data\":" + string(recordBytes.Value) + ",\"ErrorDetails\":\"" + errorData + "\"}"
-			return shim.Error(jsonResp)
-		}
-		records = append(records, record)
-	}
-
-	resultData := map[string]interface{}{
-		"status":  "true",
-		"records": records,
-	}
-	respjson, _ := json.Marshal(resultData)
-	return shim.Success(respjson)
-}
-
-// Add theatre details
-func (s *ShowsManagement) addTheatreDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		_logger.Info("addTheatreDetails: Incorrect number of arguments provided for the transaction.")
-		jsonResp = "{\"Data\":" + strconv.Itoa(len(args)) + ",\"ErrorDetails\":\"Invalid Number of argumnets provided for transaction\"}"
-		return shim.Error(jsonResp)
-	}
-
-	var td TheatreDetails
-	err := json.Unmarshal([]byte(args[0]), &td)
-	if err != nil {
-		errorKey = args[0]
-		errorData = "Invalid json provided as input"
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("addTheatreDetails:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	thid := td.TheatreID
-	theatreExists, err := stub.GetState(thid) // Check if the theatre details is already added
-
-	if err != nil {
-		errorKey = thid
-		replaceErr := strings.Replace(err.Error(), "\"", " ", -1)
-		errorData = "GetState is Failed :" + replaceErr
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("addTheatreDetails:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-	if theatreExists != nil {
-		errorData = "Theatre details already added"
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("addTheatreDetails:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	td.ObjType = "TheatreDetails"
-	tdjson, _ := json.Marshal(td)
-	err = stub.PutState(thid, tdjson)
-
-	if err != nil {
-		_logger.Errorf("addTheatreDetails:PutState is Failed :" + string(err.Error()))
-		jsonResp = "{\"Data\":" + thid + ",\"ErrorDetails\":\"Unable to add theatre details\"}"
-		return shim.Error(jsonResp)
-	}
-	_logger.Infof("addTheatreDetails:Theatre details added succesfully for :" + string(thid))
-	result := map[string]interface{}{
-		"trxnid":  stub.GetTxID(),
-		"message": "Add Theatre Details Success",
-	}
-	respJSON, _ := json.Marshal(result)
-	return shim.Success(respJSON)
-}
-
-// Sell tickets. 1 popcorn and 1 water bottle issued per ticket
-func (s *ShowsManagement) sellTicket(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	var compositeKey string
-
-	if len(args) != 1 {
-		_logger.Info("sellTicket: Incorrect number of arguments provided for the transaction.")
-		jsonResp = "{\"Data\":" + strconv.Itoa(len(args)) + ",\"ErrorDetails\":\"Invalid Number of argumnets provided for transaction\"}"
-		return shim.Error(jsonResp)
-	}
-
-	var tkt Tickets
-	err := json.Unmarshal([]byte(args[0]), &tkt)
-	if err != nil {
-		errorKey = args[0]
-		errorData = "Invalid json provided as input"
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("sellTicket:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	thid := tkt.TheatreID
-	sc := tkt.Screen
-	st := tkt.ShowCode
-
-	// Check if the show details are available
-	compositeKey = thid + sc
-	showDetails, err := stub.GetState(compositeKey)
-
-	if err != nil {
-		errorKey = thid
-		replaceErr := strings.Replace(err.Error(), "\"", " ", -1)
-		errorData = "GetState is Failed :" + replaceErr
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("sellTicket:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-	if showDetails == nil {
-		errorData = "Show details does not exists for the given details"
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("sellTicket:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	_logger.Info("All Show's details on the current screen: " + string(showDetails))
-
-	// Validation for ticket count
-	if tkt.TicketsSold == 0 {
-		errorKey = string(tkt.TicketsSold)
-		errorData = "Invalid request to sell tickets. Expected 1 or more ticket count"
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("sellTicket:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	// Check if tickets sales already started for any given showcode of particular movie-hall
-	compositeKey = thid + sc + st
-	tktIssueStarted, err := stub.GetState(compositeKey)
-	if err != nil {
-		errorData = "GetState is Failed"
-		jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("sellTicket:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	// Get movie hall-wise maximux seat capacity
-	th, err := stub.GetState(thid)
-	if err != nil {
-		errorKey = thid
-		replaceErr := strings.Replace(err.Error(), "\"", " ", -1)
-		errorData = "GetState is Failed :" + replaceErr
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("sellTicket:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	td := TheatreDetails{}
-	err = json.Unmarshal(th, &td)
-	if err != nil {
-		errorData = "Existing theatre details Unmarshalling error"
-		jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("sellTicket:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	if err != nil {
-		_logger.Errorf("sellTicket: String to integer converstion failed ")
-		jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"Unable to sell the ticket\"}"
-		return shim.Error(jsonResp)
-	}
-
-	if err != nil {
-		_logger.Errorf("sellTicket: String to integer converstion failed ")
-		jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"Unable to sell the ticket\"}"
-		return shim.Error(jsonResp)
-	}
-
-	if tktIssueStarted == nil {
-
-		if tkt.TicketsSold > td.SeatsPerHall[sc] {
-			_logger.Error("sellTicket: Enough tickets not available")
-			jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"Enough tickets not available\"}"
-			return shim.Error(jsonResp)
-		}
-
-		tkt.ObjType = "Tickets"
-		tkt.WaterSold, tkt.PopCornSold = tkt.TicketsSold, tkt.TicketsSold
-		tktjson, _ := json.Marshal(tkt)
-		err = stub.PutState(compositeKey, tktjson)
-		if err != nil {
-			_logger.Errorf("sellTicket:PutState is Failed :" + string(err.Error()))
-			jsonResp = "{\"Data\":" + thid + ",\"ErrorDetails\":\"Unable to sell the ticket\"}"
-			return shim.Error(jsonResp)
-		}
-		_logger.Infof("sellTicket:Tickets ticket.TicketsSold successfully")
-	} else {
-
-		ticket := Tickets{}
-		err := json.Unmarshal(tktIssueStarted, &ticket)
-		if err != nil {
-			errorData = "Existing ticket details Unmarshalling error"
-			jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"" + errorData + "\"}"
-			_logger.Error("sellTicket:" + string(jsonResp))
-			return shim.Error(jsonResp)
-		}
-
-		tkt.ObjType = "Tickets"
-		tkt.UpdateTs = ticket.UpdateTs
-
-		if err != nil {
-			_logger.Errorf("sellTicket: String to integer converstion failed ")
-			jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"Unable to sell the ticket\"}"
-			return shim.Error(jsonResp)
-		}
-
-		if (ticket.TicketsSold + tkt.TicketsSold) > td.SeatsPerHall[sc] {
-			_logger.Error("sellTicket: Enough tickets not available")
-			jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"Enough tickets not available\"}"
-			return shim.Error(jsonResp)
-		}
-
-		tkt.TicketsSold = ticket.TicketsSold + tkt.TicketsSold
-		tkt.WaterSold, tkt.PopCornSold = tkt.TicketsSold, tkt.TicketsSold
-
-		updatedTkt, _ := json.Marshal(tkt)
-		err = stub.PutState(compositeKey, updatedTkt)
-		if err != nil {
-			_logger.Errorf("sellTicket:PutState is Failed :" + string(err.Error()))
-			jsonResp = "{\"Data\":" + thid + ",\"ErrorDetails\":\"Unable to sell the ticket\"}"
-			return shim.Error(jsonResp)
-		}
-
-		_logger.Infof("sellTicket:Tickets ticket.TicketsSold successfully")
-	}
-
-	result := map[string]interface{}{
-		"trxnid":     stub.GetTxID(),
-		"ticketSold": tkt.TicketsSold,
-		"message":    "Sell ticket successfull",
-	}
-	respjson, _ := json.Marshal(result)
-	return shim.Success(respjson)
-
-}
-
-// Exchange water with soda
-func (s *ShowsManagement) exchangeSoda(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-
-	if !generateRandomNumber() {
-		errorData = "Better luck next time. Cannot exchange soda"
-		_logger.Infof("exchangeSoda:" + string(errorData))
-		jsonResp = "{\"Data\":" + strconv.Itoa(len(args)) + ",\"ErrorDetails\":" + errorData + "}"
-		return shim.Error(jsonResp)
-	}
-
-	if len(args) != 1 {
-		_logger.Info("exchangeSoda: Incorrect number of arguments provided for the transaction.")
-		jsonResp = "{\"Data\":" + strconv.Itoa(len(args)) + ",\"ErrorDetails\":\"Invalid Number of argumnets provided for transaction\"}"
-		return shim.Error(jsonResp)
-	}
-
-	var soda SodaInventory
-	err := json.Unmarshal([]byte(args[0]), &soda)
-	if err != nil {
-		errorKey = args[0]
-		errorData = "Invalid json provided as input"
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("exchangeSoda:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	// Check max soda count for the theatre per day
-	thid := soda.TheatreID
-	invid := soda.InventoryID
-
-	theatreDetails, err := stub.GetState(thid)
-
-	if err != nil {
-		errorKey = thid
-		replaceErr := strings.Replace(err.Error(), "\"", " ", -1)
-		errorData = "GetState is Failed :" + replaceErr
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("exchangeSoda:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-	if theatreDetails == nil {
-		errorData = "Theatre details does not exists"
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("exchangeSoda:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	td := TheatreDetails{}
-	err = json.Unmarshal(theatreDetails, &td)
-	if err != nil {
-		errorData = "Existing theatre details Unmarshalling error"
-		jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("exchangeSoda:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	compositeKey := thid + invid
-	sodaDetails, err := stub.GetState(compositeKey)
-
-	if err != nil {
-		errorKey = compositeKey
-		replaceErr := strings.Replace(err.Error(), "\"", " ", -1)
-		errorData = "GetState is Failed :" + replaceErr
-		jsonResp = "{\"Data\":" + errorKey + ",\"ErrorDetails\":\"" + errorData + "\"}"
-		_logger.Error("exchangeSoda:" + string(jsonResp))
-		return shim.Error(jsonResp)
-	}
-
-	if sodaDetails == nil {
-		soda.ObjType = "SodaInventory"
-		soda.SodaSold++
-		sodajson, _ := json.Marshal(soda)
-		err = stub.PutState(compositeKey, sodajson)
-		if err != nil {
-			_logger.Errorf("exchangeSoda:PutState is Failed :" + string(err.Error()))
-			jsonResp = "{\"Data\":" + thid + ",\"ErrorDetails\":\"Unable to exchange soda\"}"
-			return shim.Error(jsonResp)
-		}
-		_logger.Infof("exchangeSoda:Soda exchange successfull")
-	} else {
-
-		sodainv := SodaInventory{}
-		err := json.Unmarshal(sodaDetails, &sodainv)
-		if err != nil {
-			errorData = "Existing inventory details Unmarshalling error"
-			jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\"" + errorData + "\"}"
-			_logger.Error("exchangeSoda:" + string(jsonResp))
-			return shim.Error(jsonResp)
-		}
-
-		soda.ObjType = "SodaInventory"
-		soda.UpdateTs = sodainv.UpdateTs
-
-		if (sodainv.SodaSold + 1) > td.MaxSodaPerDay {
-			_logger.Error("exchangeSoda: Enough soda not available")
-			jsonResp = "{\"Data\":\"\",\"ErrorDetails\":\" Enough soda not available\"}"
-			return shim.Error(jsonResp)
-		}
-		soda.SodaSold = sodainv.SodaSold + 1
-		updatedinv, _ := json.Marshal(soda)
-		err = stub.PutState(compositeKey, updatedinv)
-		if err != nil {
-			_logger.Errorf("exchangeSoda:PutState is Failed :" + string(err.Error()))
-			jsonResp = "{\"Data\":" + thid + ",\"ErrorDetails\":\"Unable to exchange the soda\"}"
-			return shim.Error(jsonResp)
-		}
-
-		_logger.Infof("exchangeSoda:Soda exchange successfull")
-	}
-
-	result := map[string]interface{}{
-		"trxnid":        stub.GetTxID(),
-		"sodaExchanged": 1,
-		"message":       "Soda exchange successfull",
-	}
-	respjson, _ := json.Marshal(result)
-	return shim.Success(respjson)
-}
-
-// Generates random number - Decides if the customer is lucky enough to exchange water with soda :)
-func generateRandomNumber() bool {
-
-	// Generate Random number
-	min := 10
-	max := 100
-	rand.Seed(time.Now().UnixNano())
-	randomNum := rand.Intn(max-min) + min
-	if randomNum%2 == 0 {
-		fmt.Printf("Random Num: %d\n", randomNum)
-		return true
-	}
-	fmt.Printf("Random Num: %d\n", randomNum)
-	return false
-
-}
-
-func main() {
-	err := shim.Start(new(ShowsManagement))
-	_logger.SetLevel(shim.LogDebug)
-	if err != nil {
-		_logger.Error("Error occured while starting the Shows chaincode")
-	} else {
-		_logger.Info("Starting the Shows chaincode")
-	}
-}
+package main
+
+// Assumption - Shows are added date-wise for each screen. Showcodes are unique for a screen on a day (ex: "1", "2", "3", "4")
+// Assumption - Theatres will trigger reset(through "rst" API) of available tickets and inventory for all shows every day before the first show
+// Assumption - Movie names are in English and no Unicode characters
+// Assumption - Soda draws are decided from the transaction ID, transaction timestamp and the seed of the theatre so that all the endorsing peers get the same outcome
+// Assumption - Seed of the soda draws is set through "asp" API in the transient data ("sodaseed") and kept in the private data collection of the organization owning the theatre ("sodaSeeds<MSP ID>" in collections_config.json). Draws are rejected until the seed is set
+// Assumption - Hash of the seed is published with the soda promotion and the seed is revealed when it is replaced. Draws ("vdraw" API) can be verified by anyone once their seed is revealed
+// Assumption - Water of a ticket can be exchanged with soda only once. The draw is used up even if the soda is not won
+// Assumption - Soda odds, daily wins and active hours are configured per theatre through "asp" API. 50/50 odds all day if not configured
+// Assumption - Screen capacity, soda per day and the counts of tickets, popcorn, water and soda sold are not limited to 255. Counts saved before are corrected through "rcnt" API from the ticket records, or capped at the seats of the screen for the shows without the ticket records
+// Assumption - Max Sodas available per day is 200. Theatres have to reset the available count everyday
+// Assumption - Theatre details are updated through "uthd" API. Screens are deactivated ("dact" API) and reactivated ("ract" API) instead of being removed
+// Assumption - Seat map of a screen ("asm" API) sets the seat count of the screen. It can be replaced as long as it has the seats sold and held for the shows of the screen
+// Assumption - Tickets are priced from the price list of the screen ("apl" API) by the seat category and the show format (2D, 3D or IMAX) at the time of the sale. Screens without a price list use the price provided with the sale. Tickets sold without a price on such screens are unpriced (price 0)
+// Assumption - Coupons ("acpn" API) are used once per sale. Uses of a coupon are counted on the coupon and per customer on the ledger so that the caps hold across the peers
+// Assumption - Every sale saves a tax invoice with the next invoice number of the theatre. Taxes ("atax" API) are added to the price after the discount. Sales of a theatre are therefore serialized on the invoice counter
+// Assumption - Carts ("cart" API) are sold in a single transaction or rejected as a whole with the reasons of each line ("Violations"). Concessions are priced by the theatre details
+// Assumption - Theatre details will be added before adding screen-wise show details, selling tickets or exchanging soda
+// Assumtion - Only 1 cafeteria inventory per theatre
+// Assumption - Inputs are validated for the required fields, unknown fields and the formats of IDs, dates and movie names before reading the ledger. All the violations are returned together in "Violations"
+
+// Assumption - Users are issued the "role" attribute ("manager", "cashier" or "admin") by the CA of their organization. Queries are open to all the clients
+// Assumption - Theatre details, shows, tickets and soda inventory of a theatre can be updated only by the users of the organization adding the theatre
+// Assumption - Rich queries ("gss", "gssp") are restricted to a theatre and to the queryable fields of the object type
+// Assumption - Records are saved under the composite keys of the object type. Records saved under the older string keys are moved through "mig" API by an admin, dating the shows saved without a show date by the "showdate" of the migration
+// Assumption - Theatres added before the access control are bound through "mig" API to the owner registered at the instantiation or upgrade ("legacyowners"), by an admin of that organization
+// Assumption - Keys of a theatre require endorsement by the peers of the organizations endorsing the theatre. Changed through "cep" API in batches, resumed from the "nextkey" returned
+// Assumption - Create and update timestamps ("cts", "uts") of the records are set in RFC3339 from the transaction timestamp and can not be provided in the input
+// Assumption - Errors are returned as JSON with a stable code ("Code"), the input or ID the error is about ("Data") and the description ("ErrorDetails")
+// Assumption - Every record carries the identity of the client saving it ("modby", "modmsp") so that the key history ("hist" API) shows who changed it
+
+// All inputs are case sensitive
+// More than one theatre can add the data on to Blockchain
+
+/********************* Sample Peer commands for various functions ************************************
+
+peer chaincode instantiate -n moviecc -v 1.0 -c '{"args":[]}' --collections-config collections_config.json -C movieTheatre
+
+peer chaincode upgrade -n moviecc -v 2.0 -c '{"args":["init","{\"legacyowners\": {\"Theatre1\": \"Org1MSP\"}}"]}' --collections-config collections_config.json -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["asd","{\"moviename\":\"Lucy\", \"screen\":\"1\", \"thid\":\"Theatre1\", \"showcode\": [\"1\",\"2\",\"3\",\"4\"]}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["asd","{\"moviename\":\"Lucy\", \"screen\":\"SC1\", \"thid\":\"Theatre1\", \"showdate\":\"2020-12-02\", \"shows\": [{\"showcode\":\"1\", \"start\": 1606887000}, {\"showcode\":\"2\", \"start\": 1606899600}, {\"showcode\":\"3\", \"moviename\":\"Tenet\", \"start\": 1606912200, \"format\": \"IMAX\"}]}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["gss","{\"selector\": {\"obj\": \"ShowDetails\", \"thid\": \"Theatre1\", \"showdate\": \"2020-12-02\"}}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["gssp","{\"selector\": {\"obj\": \"ShowDetails\", \"thid\": \"Theatre1\", \"showdate\": {\"$gte\": \"2020-12-02\"}}, \"pagesize\": 10, \"bookmark\": \"\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["athd","{\"thid\":\"Theatre1\", \"maxsoda\": 200, \"sph\": {\"SC1\": 100 ,\"SC2\": 100,\"SC3\": 100,\"SC4\": 100,\"SC5\": 100}, \"utcoffset\": 330, \"refundpolicy\": [{\"minsbefore\": 1440, \"percent\": 100}, {\"minsbefore\": 0, \"percent\": 50}]}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["exs","{\"thid\":\"Theatre1\", \"inventoryid\": \"ES13\", \"ticketid\": \"<trxnid of sale>-1\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["sell","{\"thid\": \"Theatre1\", \"moviename\":\"Lucy\", \"screen\":\"SC1\", \"showdate\":\"2020-12-02\", \"showcode\":\"2\", \"ticketsold\": 3, \"price\": 25000, \"customer\": \"CUST01\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["asm","{\"thid\":\"Theatre1\", \"screen\":\"SC1\", \"rows\": [{\"row\":\"A\", \"seats\": 50, \"category\":\"Silver\"}, {\"row\":\"B\", \"seats\": 50, \"category\":\"Gold\"}]}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["sells","{\"thid\": \"Theatre1\", \"moviename\":\"Lucy\", \"screen\":\"SC1\", \"showcode\":\"2\", \"seats\": [\"A1\",\"A2\"]}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["hold","{\"thid\": \"Theatre1\", \"moviename\":\"Lucy\", \"screen\":\"SC1\", \"showcode\":\"2\", \"seats\": [\"A3\",\"A4\"]}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["chold","{\"thid\": \"Theatre1\", \"screen\":\"SC1\", \"showcode\":\"2\", \"holdid\": \"<trxnid of hold>\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["sweep","{\"thid\": \"Theatre1\", \"screen\":\"SC1\", \"showcode\":\"2\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["cancel","{\"thid\": \"Theatre1\", \"screen\":\"SC1\", \"showcode\":\"2\", \"seats\": [\"A1\"]}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["gtkt","{\"thid\": \"Theatre1\", \"ticketid\": \"<trxnid of sale>-1\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["xfer","{\"thid\": \"Theatre1\", \"ticketid\": \"<trxnid of sale>-1\", \"customer\": \"CUST02\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["rst","{\"thid\": \"Theatre1\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["vdraw","{\"thid\": \"Theatre1\", \"drawid\": \"<trxnid of exs>\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["asp","{\"thid\": \"Theatre1\", \"enabled\": true, \"winpercent\": 30, \"maxwins\": 50, \"fromhour\": 10, \"tohour\": 22}"]}' --transient '{"sodaseed":"<base64 of 16 or more random bytes>"}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["cep","{\"thid\": \"Theatre1\", \"add\": [\"Org2MSP\"], \"remove\": [\"Org1MSP\"], \"limit\": 100}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["mig","{\"thid\": \"Theatre1\", \"limit\": 100, \"showdate\": \"2020-12-02\", \"ownermsp\": \"Org1MSP\"}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["gscr","{\"thid\": \"Theatre1\"}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["gshw","{\"thid\": \"Theatre1\", \"screen\": \"SC1\", \"showdate\": \"2020-12-02\"}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["gth","{\"thid\": \"Theatre1\"}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["gsr","{\"thid\": \"Theatre1\", \"screen\": \"SC1\", \"showdate\": \"2020-12-02\", \"showcode\": \"2\"}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["gsst","{\"thid\": \"Theatre1\", \"inventoryid\": \"ES13\"}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["srch","{\"moviename\": \"Lucy\", \"showdate\": \"2020-12-02\", \"from\": 1606912200, \"to\": 1606930200}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["uthd","{\"thid\":\"Theatre1\", \"maxsoda\": 250, \"sph\": {\"SC1\": 120, \"SC6\": 80}, \"concessions\": {\"coffee\": 15000, \"nachos\": 18000}}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["dact","{\"thid\":\"Theatre1\", \"screen\": \"SC2\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["ract","{\"thid\":\"Theatre1\", \"screen\": \"SC2\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["rcnt","{\"thid\": \"Theatre1\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["apl","{\"thid\":\"Theatre1\", \"screen\": \"SC1\", \"prices\": [{\"format\": \"2D\", \"price\": 20000}, {\"category\": \"Gold\", \"format\": \"2D\", \"price\": 25000}, {\"category\": \"Gold\", \"format\": \"IMAX\", \"price\": 40000}]}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["quote","{\"thid\":\"Theatre1\", \"screen\": \"SC1\", \"showdate\": \"2020-12-02\", \"showcode\": \"2\", \"seats\": [\"B1\", \"B2\"]}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["acpn","{\"thid\":\"Theatre1\", \"code\": \"TUEHALF\", \"description\": \"Tuesday half-price\", \"weekdays\": [2], \"discount\": \"PERCENT\", \"value\": 50, \"maxpercustomer\": 1}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["sell","{\"thid\": \"Theatre1\", \"screen\":\"SC1\", \"showdate\":\"2020-12-01\", \"showcode\":\"2\", \"ticketsold\": 2, \"customer\": \"CUST01\", \"coupon\": \"TUEHALF\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["atax","{\"thid\":\"Theatre1\", \"ticketslabs\": [{\"upto\": 10000, \"rates\": [{\"name\": \"CGST\", \"rate\": 600}, {\"name\": \"SGST\", \"rate\": 600}]}, {\"upto\": 0, \"rates\": [{\"name\": \"CGST\", \"rate\": 900}, {\"name\": \"SGST\", \"rate\": 900}]}], \"concession\": [{\"name\": \"CGST\", \"rate\": 250}, {\"name\": \"SGST\", \"rate\": 250}]}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["ginv","{\"thid\":\"Theatre1\", \"fromdate\": \"2020-12-01\", \"todate\": \"2020-12-31\"}"]}' -C movieTheatre
+
+peer chaincode invoke -n moviecc -c '{"args":["cart","{\"thid\":\"Theatre1\", \"customer\": \"CUST01\", \"lines\": [{\"screen\": \"SC1\", \"showdate\": \"2020-12-02\", \"showcode\": \"2\", \"seats\": [\"B1\", \"B2\"]}, {\"screen\": \"SC2\", \"showdate\": \"2020-12-02\", \"showcode\": \"1\", \"ticketsold\": 2}], \"concessions\": [{\"item\": \"coffee\", \"qty\": 2}]}"]}' -C movieTheatre
+
+peer chaincode query -n moviecc -c '{"args":["hist","{\"obj\": \"ShowDetails\", \"thid\": \"Theatre1\", \"screen\": \"SC1\", \"showdate\": \"2020-12-02\"}"]}' -C movieTheatre
+
+***********************************************************************************************************/
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+var _logger = shim.NewLogger("Shows-logger")
+
+const dateFormat = "2006-01-02"
+
+// ShowDetails maintains the date-wise show details of a screen.
+type ShowDetails struct {
+	ObjType   string   `json:"obj"`
+	MovieName string   `json:"moviename"` // Default movie of the shows on the day
+	Screen    string   `json:"screen"`    // Alphanumeric
+	TheatreID string   `json:"thid"`      // Alphanumeric
+	ShowDate  string   `json:"showdate"`  // YYYY-MM-DD. Defaults to the current date of the theatre
+	ShowCode  []string `json:"showcode"`  // Showcodes of the day. Derived from the shows when the shows are provided
+	Shows     []Show   `json:"shows"`     // Shows of the day sorted by start time
+	CreateTs  string   `json:"cts"`       // RFC3339. Transaction timestamp of the first save
+	UpdateTs  string   `json:"uts"`       // RFC3339. Transaction timestamp of the latest save
+}
+
+// Show is a single show on a screen
+type Show struct {
+	ShowCode  string `json:"showcode"`  //
+	MovieName string `json:"moviename"` // Defaults to the movie name in the show details
+	StartTime int64  `json:"start"`     // epoch format. Required to refund cancelled tickets
+	Format    string `json:"format"`    // 2D, 3D or IMAX. Defaults to 2D. Tickets are priced by the format
+}
+
+// TheatreDetails has movie hall-wise max capacity and inventory capacity details
+type TheatreDetails struct {
+	ObjType         string            `json:"obj"`
+	TheatreID       string            `json:"thid"`            // Alphanumeric. Unique for each theatre
+	SeatsPerHall    map[string]uint32 `json:"sph"`             // (Max seats count can be set by the theatre. Not hardcoded as 100(as per instruction) to keep it configurable)
+	MaxSodaPerDay   uint32            `json:"maxsoda"`         // Min 0, Max - Count can be set by the theatre. Not hardcoded as 200(as per instruction) to keep it configurable
+	HoldSeconds     int64             `json:"holdsecs"`        // Seconds a hold on tickets is valid. Defaults to 300 when not set
+	RefundPolicy    []RefundRule      `json:"refundpolicy"`    // Refund percentage based on the time left for the show to start. No refund if not set
+	UTCOffset       int64             `json:"utcoffset"`       // Offset of the theatre time zone from UTC in minutes (ex: 330 for IST). Used to find the current date of the theatre
+	BusinessDate    string            `json:"bizdate"`         // YYYY-MM-DD. Current business day of the theatre. Set by the daily reset
+	OwnerMSP        string            `json:"ownermsp"`        // Organization (MSP ID) of the client adding the theatre details. Only its users can update the theatre
+	EndorsingOrgs   []string          `json:"endorsers"`       // Organizations (MSP IDs) that must endorse the changes to the keys of the theatre. Defaults to the owning organization
+	Inactive        bool              `json:"inactive"`        // Theatre is deactivated. Tickets can not be sold or held
+	InactiveScreens []string          `json:"inactivescreens"` // Screens deactivated. Tickets can not be sold or held for the shows of the screens
+	Concessions     map[string]uint32 `json:"concessions"`     // Concession item -> price. Items sold along with the tickets in a cart, other than popcorn, water and soda
+	CreateTs        string            `json:"cts"`             // RFC3339. Transaction timestamp of the first save
+	UpdateTs        string            `json:"uts"`             // RFC3339. Transaction timestamp of the latest save
+}
+
+// SodaInventory keeps track of day-wise soda sale.
+type SodaInventory struct {
+	ObjType     string `json:"obj"`
+	TheatreID   string `json:"thid"`        // Alphanumeric
+	InventoryID string `json:"inventoryid"` // Alphanumeric
+	SodaSold    uint32 `json:"soda"`        // Min 0, Max - Count set by the theatre in "TheatreDetails" struct.
+	CreateTs    string `json:"cts"`         // RFC3339. Transaction timestamp of the first save
+	UpdateTs    string `json:"uts"`         // RFC3339. Transaction timestamp of the latest save
+}
+
+// Tickets is the show-wise state data.
+type Tickets struct {
+	ObjType     string `json:"obj"`
+	TheatreID   string `json:"thid"`       // Alphanumeric
+	MovieName   string `json:"moviename"`  //
+	Screen      string `json:"screen"`     // Alphanumeric
+	ShowDate    string `json:"showdate"`   // YYYY-MM-DD. Defaults to the current date of the theatre
+	ShowCode    string `json:"showcode"`   //
+	TicketsSold uint32 `json:"ticketsold"` //  Min 0, Max - Count set by the theatre in "TheatreDetails" struct.
+	PopCornSold uint32 `json:"pcsold"`     //  Min 0, Max - Equals tickets TicketsSold
+	WaterSold   uint32 `json:"watersold"`  //  Min 0, Max - Equals tickets TicketsSold less the water exchanged with soda
+	SodaSold    uint32 `json:"sodasold"`   //  Min 0, Max - Equals tickets TicketsSold. Water exchanged with soda
+	CreateTs    string `json:"cts"`        // RFC3339. Transaction timestamp of the first save
+	UpdateTs    string `json:"uts"`        // RFC3339. Transaction timestamp of the latest save
+}
+
+// ShowsManagement is the chaincode construct
+type ShowsManagement struct {
+}
+
+// Init Initialises the chaincode
+func (s *ShowsManagement) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	if len(args) > 1 {
+		return errorResponse("Init", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for instantiation")
+	}
+	if len(args) == 1 {
+		err := saveLegacyOwners(stub, args[0])
+		if err != nil {
+			return failedResponse("Init", args[0], err)
+		}
+	}
+	_logger.Info("######### ShowsMangement is Initialized successfully #########")
+	return shim.Success(nil)
+}
+
+// Invoke gets called when interacting with ledger
+func (s *ShowsManagement) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	fn, args := stub.GetFunctionAndParameters()
+	_logger.Info("ShowsMangement CC is invoked with function: ", string(fn))
+
+	// Input is validated before reading the ledger. Showcode of a sale or hold is validated after the authorization
+	err := validateInput(fn, args)
+	if err == nil {
+		err = authorize(stub, fn, args)
+	}
+	if err == nil {
+		err = validateShowCode(stub, fn, args)
+	}
+	if err != nil {
+		return failedResponse("Invoke", fn, err)
+	}
+
+	switch fn {
+	case "asd":
+		return s.addOrModifyShowDetails(stub, args)
+	case "exs":
+		return s.exchangeSoda(stub, args)
+	case "athd":
+		return s.addTheatreDetails(stub, args)
+	case "gss":
+		return s.getShowDetails(stub, args)
+	case "sell":
+		return s.sellTicket(stub, args)
+	case "asm":
+		return s.addSeatMap(stub, args)
+	case "sells":
+		return s.sellSeats(stub, args)
+	case "hold":
+		return s.holdTickets(stub, args)
+	case "chold":
+		return s.confirmHold(stub, args)
+	case "rhold":
+		return s.releaseHold(stub, args)
+	case "sweep":
+		return s.sweepHolds(stub, args)
+	case "cancel":
+		return s.cancelTickets(stub, args)
+	case "gtkt":
+		return s.queryTicket(stub, args)
+	case "ctkt":
+		return s.cancelTicket(stub, args)
+	case "xfer":
+		return s.transferTicket(stub, args)
+	case "redeem":
+		return s.redeemTicket(stub, args)
+	case "rst":
+		return s.resetDay(stub, args)
+	case "vdraw":
+		return s.verifySodaDraw(stub, args)
+	case "asp":
+		return s.addOrModifySodaPromotion(stub, args)
+	case "cep":
+		return s.changeEndorsers(stub, args)
+	case "mig":
+		return s.migrateKeys(stub, args)
+	case "gscr":
+		return s.getScreens(stub, args)
+	case "gshw":
+		return s.getScreenShows(stub, args)
+	case "gssp":
+		return s.getShowDetailsPage(stub, args)
+	case "gth":
+		return s.getTheatre(stub, args)
+	case "gsr":
+		return s.getSeatsRemaining(stub, args)
+	case "gsst":
+		return s.getSodaStock(stub, args)
+	case "rcnt":
+		return s.recountTickets(stub, args)
+	case "uthd":
+		return s.updateTheatreDetails(stub, args)
+	case "dact":
+		return s.setTheatreActive(stub, args, false)
+	case "ract":
+		return s.setTheatreActive(stub, args, true)
+	case "hist":
+		return s.getKeyHistory(stub, args)
+	case "srch":
+		return s.searchShows(stub, args)
+	case "apl":
+		return s.addPriceList(stub, args)
+	case "quote":
+		return s.quoteTickets(stub, args)
+	case "acpn":
+		return s.addCoupon(stub, args)
+	case "cart":
+		return s.checkout(stub, args)
+	case "atax":
+		return s.addTaxConfig(stub, args)
+	case "ginv":
+		return s.getInvoices(stub, args)
+	default:
+		return errorResponse("Invoke", codeInvalidInput, fn, "Available Functions:asd,athd,gss,sell,asm,sells,hold,chold,rhold,sweep,cancel,gtkt,ctkt,xfer,redeem,rst,vdraw,asp,cep,mig,gscr,gshw,gssp,gth,gsr,gsst,srch,hist,rcnt,uthd,dact,ract,apl,quote,acpn,atax,ginv,cart")
+	}
+}
+
+// Add movie name and show timings for a particular screen in a theatre
+func (s *ShowsManagement) addOrModifyShowDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("addOrModifyShowDetails", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var sd ShowDetails
+	err := json.Unmarshal([]byte(args[0]), &sd)
+	if err != nil {
+		return errorResponse("addOrModifyShowDetails", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	sc := sd.Screen
+	thid := sd.TheatreID
+
+	/* Check if the theatre details is added for the movie hall. If not present, do not process the request */
+	theatreDetails, err := getRecord(stub, "TheatreDetails", thid)
+
+	if err != nil {
+		return errorResponse("addOrModifyShowDetails", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+	if theatreDetails == nil {
+		return errorResponse("addOrModifyShowDetails", codeNotFound, thid, "Theatre details does not exists for :"+thid)
+	}
+
+	td := TheatreDetails{}
+	err = json.Unmarshal(theatreDetails, &td)
+	if err != nil {
+		return errorResponse("addOrModifyShowDetails", codeLedgerError, "", "Existing theatre details Unmarshalling error")
+	}
+
+	// Shows are added date-wise. Current date of the theatre is used when the show date is not provided
+	sd.ShowDate, err = showDateOrToday(stub, td, sd.ShowDate)
+	if err == nil {
+		err = sd.normalizeShows()
+	}
+	if err != nil {
+		return failedResponse("addOrModifyShowDetails", thid, err)
+	}
+
+	// Check if the movie-hall/screen details is present with the theatre
+	screenExists, err := getRecord(stub, "ShowDetails", thid, sc, sd.ShowDate)
+
+	if err != nil {
+		return errorResponse("addOrModifyShowDetails", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+	if screenExists != nil {
+
+		screendetail := ShowDetails{}
+		err = json.Unmarshal(screenExists, &screendetail)
+		if err != nil {
+			return errorResponse("addOrModifyShowDetails", codeLedgerError, "", "Existing show details Unmarshalling error")
+		}
+
+		sd.ObjType = "ShowDetails"
+		sdjson, _ := json.Marshal(sd)
+		err = putRecord(stub, td, sdjson, "ShowDetails", thid, sc, sd.ShowDate)
+		if err != nil {
+			_logger.Errorf("addOrModifyShowDetails:PutState is Failed :" + string(err.Error()))
+			return errorResponse("addOrModifyShowDetails", errorCode(err), thid, "Unable to add the show details")
+		}
+		_logger.Infof("addOrModifyShowDetails:Show details added succesfully for theatre :" + string(thid))
+
+	} else {
+
+		// Validate the screen detail against the respective theatre details
+		if td.SeatsPerHall[sc] == 0 {
+			return errorResponse("addOrModifyShowDetails", codeNotFound, sc, "This screen details does not exists for the theatre")
+		}
+
+		sd.ObjType = "ShowDetails"
+		sdjson, _ := json.Marshal(sd)
+		err = putRecord(stub, td, sdjson, "ShowDetails", thid, sc, sd.ShowDate)
+		if err != nil {
+			_logger.Errorf("addOrModifyShowDetails:PutState is Failed :" + string(err.Error()))
+			return errorResponse("addOrModifyShowDetails", errorCode(err), thid, "Unable to add the show details")
+		}
+		_logger.Infof("addOrModifyShowDetails:Show details added succesfully for theatre :" + string(thid))
+	}
+	result := map[string]interface{}{
+		"trxnid":  stub.GetTxID(),
+		"message": "Add Show Detail Success",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Get show details on a particular screen of a movie theatre. Other records of the theatre can be queried by the queryable fields
+func (s *ShowsManagement) getShowDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("getShowDetails", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	// Selector is restricted to the queryable fields of the object type of a theatre
+	_, queryString, err := parseRecordQuery(stub, args[0])
+	if err != nil {
+		return failedResponse("getShowDetails", "", err)
+	}
+
+	valAsbytes, err := stub.GetQueryResult(queryString)
+
+	if err != nil {
+		return errorResponse("getShowDetails", codeLedgerError, queryString, "Failed to get state :"+err.Error())
+	}
+	defer valAsbytes.Close()
+
+	records, err := collectRecords(valAsbytes)
+	if err != nil {
+		return failedResponse("getShowDetails", "", err)
+	}
+
+	resultData := map[string]interface{}{
+		"status":  "true",
+		"records": records,
+	}
+	respjson, _ := json.Marshal(resultData)
+	return shim.Success(respjson)
+}
+
+// Add theatre details
+func (s *ShowsManagement) addTheatreDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return errorResponse("addTheatreDetails", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var td TheatreDetails
+	err := json.Unmarshal([]byte(args[0]), &td)
+	if err != nil {
+		return errorResponse("addTheatreDetails", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := td.TheatreID
+	theatreExists, err := getRecord(stub, "TheatreDetails", thid) // Check if the theatre details is already added
+
+	if err != nil {
+		return errorResponse("addTheatreDetails", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+	if theatreExists != nil {
+		return errorResponse("addTheatreDetails", codeAlreadyExists, thid, "Theatre details already added")
+	}
+
+	err = checkTheatreSettings(td.RefundPolicy, td.HoldSeconds, td.Concessions)
+	if err != nil {
+		return failedResponse("addTheatreDetails", thid, err)
+	}
+
+	// Business day of the theatre starts on the day the theatre details are added
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("addTheatreDetails:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("addTheatreDetails", errorCode(err), thid, "Unable to add theatre details")
+	}
+	td.BusinessDate = theatreDate(td, now)
+
+	// Theatre is owned by the organization adding the theatre details
+	td.OwnerMSP, err = cid.GetMSPID(stub)
+	if err != nil {
+		_logger.Errorf("addTheatreDetails:GetMSPID is Failed :" + string(err.Error()))
+		return errorResponse("addTheatreDetails", errorCode(err), thid, "Unable to add theatre details")
+	}
+
+	// Keys of the theatre are endorsed by the owning organization unless other organizations are provided
+	if len(td.EndorsingOrgs) == 0 {
+		td.EndorsingOrgs = []string{td.OwnerMSP}
+	}
+
+	td.ObjType = "TheatreDetails"
+	tdjson, _ := json.Marshal(td)
+	key, err := stateKey(stub, "TheatreDetails", thid)
+	if err == nil {
+		err = putState(stub, key, tdjson)
+	}
+	if err == nil {
+		err = setKeyEndorsers(stub, key, td.EndorsingOrgs)
+	}
+
+	if err != nil {
+		_logger.Errorf("addTheatreDetails:PutState is Failed :" + string(err.Error()))
+		return errorResponse("addTheatreDetails", errorCode(err), thid, "Unable to add theatre details")
+	}
+	_logger.Infof("addTheatreDetails:Theatre details added succesfully for :" + string(thid))
+	result := map[string]interface{}{
+		"trxnid":  stub.GetTxID(),
+		"message": "Add Theatre Details Success",
+	}
+	respJSON, _ := json.Marshal(result)
+	return shim.Success(respJSON)
+}
+
+// Sell tickets. 1 popcorn and 1 water bottle issued per ticket
+func (s *ShowsManagement) sellTicket(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("sellTicket", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var tkt Tickets
+	var sale TicketSale
+	err := json.Unmarshal([]byte(args[0]), &tkt)
+	if err == nil {
+		err = json.Unmarshal([]byte(args[0]), &sale)
+	}
+	if err != nil {
+		return errorResponse("sellTicket", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := tkt.TheatreID
+	sc := tkt.Screen
+	st := tkt.ShowCode
+
+	tkt.ShowDate, err = resolveShowDate(stub, thid, tkt.ShowDate)
+	if err != nil {
+		return failedResponse("sellTicket", thid, err)
+	}
+	dt := tkt.ShowDate
+
+	// Check if the show details are available
+	showDetails, err := getRecord(stub, "ShowDetails", thid, sc, dt)
+
+	if err != nil {
+		return errorResponse("sellTicket", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+	if showDetails == nil {
+		return errorResponse("sellTicket", codeNotFound, thid, "Show details does not exists for the given details")
+	}
+
+	_logger.Info("All Show's details on the current screen: " + string(showDetails))
+
+	// Validation for ticket count
+	if tkt.TicketsSold == 0 {
+		return errorResponse("sellTicket", codeInvalidInput, strconv.Itoa(int(tkt.TicketsSold)), "Invalid request to sell tickets. Expected 1 or more ticket count")
+	}
+	count := int(tkt.TicketsSold)
+
+	// Check if tickets sales already started for any given showcode of particular movie-hall
+	tktIssueStarted, err := getRecord(stub, "Tickets", thid, sc, dt, st)
+	if err != nil {
+		return errorResponse("sellTicket", codeLedgerError, "", "GetState is Failed")
+	}
+
+	// Get movie hall-wise maximux seat capacity
+	th, err := getRecord(stub, "TheatreDetails", thid)
+	if err != nil {
+		return errorResponse("sellTicket", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+	if th == nil {
+		return errorResponse("sellTicket", codeNotFound, thid, "Theatre details does not exists")
+	}
+
+	td := TheatreDetails{}
+	err = json.Unmarshal(th, &td)
+	if err != nil {
+		return errorResponse("sellTicket", codeLedgerError, "", "Existing theatre details Unmarshalling error")
+	}
+	err = td.salesOpen(sc)
+	if err != nil {
+		return failedResponse("sellTicket", thid, err)
+	}
+
+	// Tickets on live holds are not available for sale
+	holds, err := getShowHolds(stub, thid, sc, dt, st)
+	if err != nil {
+		return failedResponse("sellTicket", thid, err)
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("sellTicket:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("sellTicket", errorCode(err), "", "Unable to sell the ticket")
+	}
+	held := holds.liveCount(now)
+
+	if tktIssueStarted == nil {
+
+		if !withinCapacity(td.SeatsPerHall[sc], count, held) {
+			return errorResponse("sellTicket", codeCapacityExceeded, "", "Enough tickets not available")
+		}
+
+		tkt.ObjType = "Tickets"
+		tkt.TicketsSold, tkt.PopCornSold, tkt.WaterSold, tkt.SodaSold = 0, 0, 0, 0
+		err = tkt.addSold(uint32(count))
+		if err != nil {
+			_logger.Errorf("sellTicket:" + string(err.Error()))
+			return errorResponse("sellTicket", errorCode(err), thid, "Unable to sell the ticket")
+		}
+		tktjson, _ := json.Marshal(tkt)
+		err = putRecord(stub, td, tktjson, "Tickets", thid, sc, dt, st)
+		if err != nil {
+			_logger.Errorf("sellTicket:PutState is Failed :" + string(err.Error()))
+			return errorResponse("sellTicket", errorCode(err), thid, "Unable to sell the ticket")
+		}
+		_logger.Infof("sellTicket:Tickets ticket.TicketsSold successfully")
+	} else {
+
+		ticket := Tickets{}
+		err := json.Unmarshal(tktIssueStarted, &ticket)
+		if err != nil {
+			return errorResponse("sellTicket", codeLedgerError, "", "Existing ticket details Unmarshalling error")
+		}
+
+		tkt.ObjType = "Tickets"
+
+		if !withinCapacity(td.SeatsPerHall[sc], int(ticket.TicketsSold), count, held) {
+			return errorResponse("sellTicket", codeCapacityExceeded, "", "Enough tickets not available")
+		}
+
+		// Popcorn, water and soda counts of the earlier sales are carried forward
+		tkt.TicketsSold, tkt.PopCornSold, tkt.WaterSold, tkt.SodaSold = ticket.TicketsSold, ticket.PopCornSold, ticket.WaterSold, ticket.SodaSold
+		err = tkt.addSold(uint32(count))
+		if err != nil {
+			_logger.Errorf("sellTicket:" + string(err.Error()))
+			return errorResponse("sellTicket", errorCode(err), thid, "Unable to sell the ticket")
+		}
+
+		updatedTkt, _ := json.Marshal(tkt)
+		err = putRecord(stub, td, updatedTkt, "Tickets", thid, sc, dt, st)
+		if err != nil {
+			_logger.Errorf("sellTicket:PutState is Failed :" + string(err.Error()))
+			return errorResponse("sellTicket", errorCode(err), thid, "Unable to sell the ticket")
+		}
+
+		_logger.Infof("sellTicket:Tickets ticket.TicketsSold successfully")
+	}
+
+	// Tickets are priced from the price list of the screen at the time of the sale
+	quote, err := quoteSale(stub, thid, sc, dt, st, count, nil, sale.Price)
+	if err != nil {
+		return failedResponse("sellTicket", thid, err)
+	}
+	coupon, use, err := applyCoupon(stub, thid, sc, dt, quote, sale)
+	if err != nil {
+		return failedResponse("sellTicket", sale.Coupon, err)
+	}
+
+	var invoice *Invoice
+	tickets, err := issueTickets(stub, td, tkt, *quote, sale.Customer, 0)
+	if err == nil {
+		err = useCoupon(stub, td, coupon, use)
+	}
+	if err == nil {
+		invoice, err = issueInvoice(stub, td, sale.Customer, ticketLines(*quote, tickets))
+	}
+	if err != nil {
+		_logger.Errorf("sellTicket:PutState is Failed :" + string(err.Error()))
+		return errorResponse("sellTicket", errorCode(err), thid, "Unable to issue the tickets")
+	}
+
+	result := map[string]interface{}{
+		"trxnid":     stub.GetTxID(),
+		"ticketSold": tkt.TicketsSold,
+		"tickets":    tickets,
+		"format":     quote.Format,
+		"discount":   quote.Discount,
+		"amount":     quote.Total,
+		"invoiceno":  invoice.InvoiceNo,
+		"tax":        invoice.Tax,
+		"total":      invoice.Total,
+		"message":    "Sell ticket successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+
+}
+
+// Exchange water with soda. The customer is lucky enough to exchange water with soda if the soda draw is won :)
+func (s *ShowsManagement) exchangeSoda(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("exchangeSoda", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var soda SodaInventory
+	var exchange SodaExchange
+	err := json.Unmarshal([]byte(args[0]), &soda)
+	if err == nil {
+		err = json.Unmarshal([]byte(args[0]), &exchange)
+	}
+	if err != nil {
+		return errorResponse("exchangeSoda", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	// Check max soda count for the theatre per day
+	thid := soda.TheatreID
+	invid := soda.InventoryID
+
+	theatreDetails, err := getRecord(stub, "TheatreDetails", thid)
+
+	if err != nil {
+		return errorResponse("exchangeSoda", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+	}
+	if theatreDetails == nil {
+		return errorResponse("exchangeSoda", codeNotFound, thid, "Theatre details does not exists")
+	}
+
+	td := TheatreDetails{}
+	err = json.Unmarshal(theatreDetails, &td)
+	if err != nil {
+		return errorResponse("exchangeSoda", codeLedgerError, "", "Existing theatre details Unmarshalling error")
+	}
+
+	// Water of a ticket can be exchanged only once
+	t, err := getTicketForSoda(stub, thid, exchange.TicketID)
+	if err != nil {
+		return failedResponse("exchangeSoda", exchange.TicketID, err)
+	}
+
+	sodaDetails, err := getRecord(stub, "SodaInventory", thid, invid)
+
+	if err != nil {
+		return errorResponse("exchangeSoda", codeLedgerError, invid, "GetState is Failed :"+err.Error())
+	}
+
+	soda.ObjType = "SodaInventory"
+	soda.SodaSold = 0
+	if sodaDetails != nil {
+		sodainv := SodaInventory{}
+		err := json.Unmarshal(sodaDetails, &sodainv)
+		if err != nil {
+			return errorResponse("exchangeSoda", codeLedgerError, "", "Existing inventory details Unmarshalling error")
+		}
+
+		soda.SodaSold = sodainv.SodaSold
+
+		if sodainv.SodaSold >= td.MaxSodaPerDay {
+			return errorResponse("exchangeSoda", codeCapacityExceeded, "", "Enough soda not available")
+		}
+	}
+
+	// Every draw is recorded on the ledger so that the outcome can be verified later
+	draw, err := drawSoda(stub, td, invid, t.TicketID)
+	if err != nil {
+		return failedResponse("exchangeSoda", thid, err)
+	}
+	err = exchangeTicketWater(stub, td, t, *draw)
+	if err != nil {
+		return failedResponse("exchangeSoda", exchange.TicketID, err)
+	}
+	if !draw.Won {
+		_logger.Infof("exchangeSoda:Better luck next time. Cannot exchange soda")
+		result := map[string]interface{}{
+			"trxnid":        stub.GetTxID(),
+			"drawid":        draw.DrawID,
+			"won":           false,
+			"sodaExchanged": 0,
+			"message":       "Better luck next time. Cannot exchange soda",
+		}
+		respjson, _ := json.Marshal(result)
+		return shim.Success(respjson)
+	}
+
+	soda.SodaSold++
+	sodajson, _ := json.Marshal(soda)
+	err = putRecord(stub, td, sodajson, "SodaInventory", thid, invid)
+	if err != nil {
+		_logger.Errorf("exchangeSoda:PutState is Failed :" + string(err.Error()))
+		return errorResponse("exchangeSoda", errorCode(err), thid, "Unable to exchange the soda")
+	}
+	_logger.Infof("exchangeSoda:Soda exchange successfull")
+
+	result := map[string]interface{}{
+		"trxnid":        stub.GetTxID(),
+		"drawid":        draw.DrawID,
+		"won":           true,
+		"sodaExchanged": 1,
+		"message":       "Soda exchange successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// getTheatreDetails fetches the theatre details. Returns nil if the theatre details are not added
+func getTheatreDetails(stub shim.ChaincodeStubInterface, thid string) (*TheatreDetails, error) {
+	theatreDetails, err := getRecord(stub, "TheatreDetails", thid)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if theatreDetails == nil {
+		return nil, nil
+	}
+	td := TheatreDetails{}
+	err = json.Unmarshal(theatreDetails, &td)
+	if err != nil {
+		return nil, fmt.Errorf("Existing theatre details Unmarshalling error")
+	}
+	return &td, nil
+}
+
+// normalizeShows builds the shows from the showcodes when the shows are not provided, and the showcodes from the shows
+func (sd *ShowDetails) normalizeShows() error {
+	if len(sd.Shows) == 0 {
+		for _, st := range sd.ShowCode {
+			if st != "" {
+				sd.Shows = append(sd.Shows, Show{ShowCode: st})
+			}
+		}
+	}
+	if len(sd.Shows) == 0 {
+		return newError(codeInvalidInput, "Expected 1 or more shows for the day")
+	}
+
+	sort.SliceStable(sd.Shows, func(i, j int) bool { return sd.Shows[i].StartTime < sd.Shows[j].StartTime })
+	sd.ShowCode = nil
+	for i := range sd.Shows {
+		if sd.Shows[i].ShowCode == "" || contains(sd.ShowCode, sd.Shows[i].ShowCode) {
+			return newError(codeInvalidInput, "Showcodes must be unique for the day")
+		}
+		if sd.Shows[i].StartTime < 0 {
+			return newError(codeInvalidInput, "Invalid start time for the showcode %s", sd.Shows[i].ShowCode)
+		}
+		if sd.Shows[i].MovieName == "" {
+			sd.Shows[i].MovieName = sd.MovieName
+		}
+		if sd.Shows[i].Format == "" {
+			sd.Shows[i].Format = format2D
+		}
+		if !contains(showFormats, sd.Shows[i].Format) {
+			return newError(codeInvalidInput, "Invalid format %s for the showcode %s. Expected 2D, 3D or IMAX", sd.Shows[i].Format, sd.Shows[i].ShowCode)
+		}
+		sd.ShowCode = append(sd.ShowCode, sd.Shows[i].ShowCode)
+	}
+	return nil
+}
+
+// show returns the show of the given showcode
+func (sd ShowDetails) show(st string) (Show, bool) {
+	for _, show := range sd.Shows {
+		if show.ShowCode == st {
+			return show, true
+		}
+	}
+	return Show{}, false
+}
+
+// theatreDate returns the date of the theatre at the given time
+func theatreDate(td TheatreDetails, now int64) string {
+	return time.Unix(now+td.UTCOffset*60, 0).UTC().Format(dateFormat)
+}
+
+// showDateOrToday validates the show date. Returns the current date of the theatre if the show date is not provided
+func showDateOrToday(stub shim.ChaincodeStubInterface, td TheatreDetails, dt string) (string, error) {
+	if dt != "" {
+		_, err := time.Parse(dateFormat, dt)
+		if err != nil {
+			return "", newError(codeInvalidInput, "Invalid show date %s. Expected YYYY-MM-DD format", dt)
+		}
+		return dt, nil
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return "", fmt.Errorf("GetTxTimestamp is Failed :%s", err.Error())
+	}
+	return theatreDate(td, now), nil
+}
+
+// resolveShowDate is same as showDateOrToday. Theatre details are fetched only if the show date is not provided
+func resolveShowDate(stub shim.ChaincodeStubInterface, thid string, dt string) (string, error) {
+	if dt != "" {
+		return showDateOrToday(stub, TheatreDetails{}, dt)
+	}
+	td, err := getTheatreDetails(stub, thid)
+	if err != nil {
+		return "", err
+	}
+	if td == nil {
+		return "", newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	return showDateOrToday(stub, *td, dt)
+}
+
+// getShowDetailsOfScreen fetches the show details of a screen on a day. Returns nil if the show details are not added
+func getShowDetailsOfScreen(stub shim.ChaincodeStubInterface, thid string, sc string, dt string) (*ShowDetails, error) {
+	showDetails, err := getRecord(stub, "ShowDetails", thid, sc, dt)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if showDetails == nil {
+		return nil, nil
+	}
+	sd := ShowDetails{}
+	err = json.Unmarshal(showDetails, &sd)
+	if err != nil {
+		return nil, fmt.Errorf("Existing show details Unmarshalling error")
+	}
+	return &sd, nil
+}
+
+// getTickets fetches the show-wise ticket details. Returns nil if ticket sales are not started for the show
+func getTickets(stub shim.ChaincodeStubInterface, thid string, sc string, dt string, st string) (*Tickets, error) {
+	tktDetails, err := getRecord(stub, "Tickets", thid, sc, dt, st)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if tktDetails == nil {
+		return nil, nil
+	}
+	tkt := Tickets{}
+	err = json.Unmarshal(tktDetails, &tkt)
+	if err != nil {
+		return nil, fmt.Errorf("Existing ticket details Unmarshalling error")
+	}
+	return &tkt, nil
+}
+
+// contains checks if the value is present in the list
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// txTime returns the transaction timestamp in epoch seconds. It is same on all the endorsing peers
+func txTime(stub shim.ChaincodeStubInterface) (int64, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return 0, err
+	}
+	return ts.Seconds, nil
+}
+
+func main() {
+	err := shim.Start(new(ShowsManagement))
+	_logger.SetLevel(shim.LogDebug)
+	if err != nil {
+		_logger.Error("Error occured while starting the Shows chaincode")
+	} else {
+		_logger.Info("Starting the Shows chaincode")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestDatedShows(t *testing.T) {
+	s := newTestStub(t)
+	s.mustInvoke("athd", `{"thid":"T1","maxsoda":200,"sph":{"SC1":4,"SC2":4}}`)
+
+	start := func(hour int64) string {
+		return strconv.FormatInt(day0+24*3600+hour*3600, 10)
+	}
+	fiveShows := `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"` + date1 + `","shows":[` +
+		`{"showcode":"5","start":` + start(22) + `},{"showcode":"1","start":` + start(9) + `},{"showcode":"2","start":` + start(12) + `},` +
+		`{"showcode":"3","start":` + start(15) + `,"moviename":"Tenet","format":"IMAX"},{"showcode":"4","start":` + start(19) + `}]}`
+
+	runCases(t, s, []invokeCase{
+		{name: "shows of an unknown theatre", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T9","showcode":["1"]}`, code: codeNotFound},
+		{name: "shows of an unknown screen", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC9","thid":"T1","showcode":["1"]}`, code: codeNotFound},
+		{name: "no shows", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"` + date1 + `","shows":[]}`, code: codeInvalidInput},
+		{name: "duplicate showcodes", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"` + date1 + `","shows":[{"showcode":"1","start":` + start(9) + `},{"showcode":"1","start":` + start(12) + `}]}`, code: codeInvalidInput},
+		{name: "unknown format", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"` + date1 + `","shows":[{"showcode":"1","start":` + start(9) + `,"format":"4D"}]}`, code: codeInvalidInput},
+		{name: "invalid show date", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"2024-02-30","showcode":["1"]}`, code: codeInvalidInput},
+		{name: "showcodes of today", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC2","thid":"T1","showcode":["1","2","3","4"]}`},
+		{name: "five shows of tomorrow", fn: "asd", arg: fiveShows},
+		{name: "advance booking", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"5","ticketsold":4,"price":10000}`},
+		{name: "advance booking over the capacity", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"5","ticketsold":1,"price":10000}`, code: codeCapacityExceeded},
+		{name: "another show of tomorrow", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"1","ticketsold":1,"price":10000}`},
+		{name: "show of today", fn: "sell", arg: `{"thid":"T1","screen":"SC2","showcode":"4","ticketsold":4,"price":10000}`},
+		{name: "showcode not on the day", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"6","ticketsold":1,"price":10000}`, code: codeInvalidInput},
+		{name: "day without shows", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`, code: codeInvalidInput},
+		{name: "no tickets", fn: "sell", arg: `{"thid":"T1","screen":"SC2","showcode":"1","ticketsold":0,"price":10000}`, code: codeInvalidInput},
+	})
+
+	sd := ShowDetails{}
+	if !s.record(&sd, "ShowDetails", "T1", "SC1", date1) {
+		t.Fatal("Shows of tomorrow are not saved")
+	}
+	order := ""
+	for _, show := range sd.Shows {
+		order += show.ShowCode
+	}
+	if order != "12345" || len(sd.ShowCode) != 5 {
+		t.Errorf("Shows are in the order %s, expected 12345 by the start time", order)
+	}
+	if tenet, _ := sd.show("3"); tenet.MovieName != "Tenet" || tenet.Format != formatIMAX {
+		t.Errorf("Show 3 :%+v, expected Tenet in IMAX", tenet)
+	}
+	if lucy, _ := sd.show("4"); lucy.MovieName != "Lucy" || lucy.Format != format2D {
+		t.Errorf("Show 4 :%+v, expected Lucy in 2D", lucy)
+	}
+	tkt := Tickets{}
+	if !s.record(&tkt, "Tickets", "T1", "SC1", date1, "5") || tkt.TicketsSold != 4 {
+		t.Errorf("Tickets of the advance booking :%+v", tkt)
+	}
+}
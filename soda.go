@@ -0,0 +1,455 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Seed of the soda draws of a theatre is kept in the private data collection of the organization owning the theatre,
+// so that the clients can not compute the outcome of a draw before submitting it. Hash of the seed is published with
+// the soda promotion and the seed is revealed when it is replaced, so that the draws can be verified by anyone
+const (
+	sodaCollectionPrefix = "sodaSeeds" // Collection of an organization is named with its MSP ID (ex: sodaSeedsOrg1MSP)
+	sodaSeedKey          = "sodaseed"  // Transient data key of the seed provided to "asp"
+	minSeedBytes         = 16
+)
+
+// SodaDraw is the outcome of a soda draw along with the inputs used to decide it
+type SodaDraw struct {
+	ObjType     string `json:"obj"`
+	DrawID      string `json:"drawid"`      // Transaction ID of the soda exchange
+	TheatreID   string `json:"thid"`        // Alphanumeric
+	InventoryID string `json:"inventoryid"` // Alphanumeric
+	TicketID    string `json:"ticketid"`    // Ticket whose water is used for the draw
+	TxSeconds   int64  `json:"txsecs"`      // Transaction timestamp seconds
+	TxNanos     int32  `json:"txnanos"`     // Transaction timestamp nanoseconds
+	SeedHash    string `json:"seedhash"`    // SHA-256 of the seed of the theatre at the time of the draw. Seed is not recorded
+	WinPercent  uint8  `json:"winpercent"`  // Win probability of the soda promotion at the time of the draw
+	Number      uint8  `json:"number"`      // Min 0, Max 99. Drawn number
+	Won         bool   `json:"won"`         // Number below the win percentage wins the soda
+}
+
+// SodaExchange has the ticket details provided along with the input of a soda exchange
+type SodaExchange struct {
+	TicketID string `json:"ticketid"` // Ticket whose water is exchanged with soda
+}
+
+// SodaPromotion is the soda exchange promotion configured by a theatre
+type SodaPromotion struct {
+	ObjType    string `json:"obj"`
+	TheatreID  string `json:"thid"`       // Alphanumeric
+	Enabled    bool   `json:"enabled"`    // Soda can be exchanged only when the promotion is enabled
+	WinPercent uint8  `json:"winpercent"` // Min 0, Max 100. Probability of winning the soda draw
+	MaxWins    uint32 `json:"maxwins"`    // Max soda draws won per day across the inventories. No cap if 0
+	FromHour   uint8  `json:"fromhour"`   // Min 0, Max 23. Hour of the theatre time the promotion starts every day
+	ToHour     uint8  `json:"tohour"`     // Min 0, Max 23. Hour of the theatre time the promotion ends every day. Active all day if same as FromHour
+	SeedHash   string `json:"seedhash"`   // SHA-256 of the current seed of the soda draws. Published when the seed is set
+	ChangedBy  string `json:"changedby"`  // Transaction ID of the last change of the promotion
+	ChangedAt  int64  `json:"changedat"`  // epoch format. Transaction timestamp of the last change of the promotion
+	CreateTs   string `json:"cts"`        // RFC3339. Transaction timestamp of the first save
+	UpdateTs   string `json:"uts"`        // RFC3339. Transaction timestamp of the latest save
+}
+
+// SodaWins is the count of soda draws won by the customers of a theatre on a day
+type SodaWins struct {
+	ObjType   string `json:"obj"`
+	TheatreID string `json:"thid"`    // Alphanumeric
+	WinDate   string `json:"windate"` // YYYY-MM-DD. Date of the theatre
+	Wins      uint32 `json:"wins"`    // Soda draws won on the day
+}
+
+// RevealedSeed is a replaced seed of the soda draws of a theatre. Draws recorded with the hash of the seed can be
+// recomputed from it
+type RevealedSeed struct {
+	ObjType    string `json:"obj"`
+	TheatreID  string `json:"thid"`       // Alphanumeric
+	SeedHash   string `json:"seedhash"`   // SHA-256 of the seed
+	Seed       string `json:"seed"`       // Hex encoded seed
+	RevealedBy string `json:"revealedby"` // Transaction ID replacing the seed
+}
+
+// defaultSodaPromotion is applied to the theatres that have not configured the soda promotion. 50/50 odds all day
+func defaultSodaPromotion(thid string) SodaPromotion {
+	return SodaPromotion{ObjType: "SodaPromotion", TheatreID: thid, Enabled: true, WinPercent: 50}
+}
+
+// active checks if the promotion is on at the given time of the theatre
+func (sp SodaPromotion) active(td TheatreDetails, now int64) bool {
+	if !sp.Enabled {
+		return false
+	}
+	if sp.FromHour == sp.ToHour {
+		return true
+	}
+	hour := uint8(time.Unix(now+td.UTCOffset*60, 0).UTC().Hour())
+	if sp.FromHour < sp.ToHour {
+		return hour >= sp.FromHour && hour < sp.ToHour
+	}
+	// Promotion running past midnight
+	return hour >= sp.FromHour || hour < sp.ToHour
+}
+
+// getSodaPromotion fetches the soda promotion of the theatre. Returns the default promotion if not configured
+func getSodaPromotion(stub shim.ChaincodeStubInterface, thid string) (*SodaPromotion, error) {
+	promoDetails, err := getRecord(stub, "SodaPromotion", thid)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	sp := defaultSodaPromotion(thid)
+	if promoDetails == nil {
+		return &sp, nil
+	}
+	err = json.Unmarshal(promoDetails, &sp)
+	if err != nil {
+		return nil, fmt.Errorf("Existing soda promotion Unmarshalling error")
+	}
+	return &sp, nil
+}
+
+// getSodaWins fetches the soda draws won on the given day of the theatre
+func getSodaWins(stub shim.ChaincodeStubInterface, thid string, dt string) (*SodaWins, error) {
+	winDetails, err := getRecord(stub, "SodaWins", thid, dt)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	wins := SodaWins{ObjType: "SodaWins", TheatreID: thid, WinDate: dt}
+	if winDetails == nil {
+		return &wins, nil
+	}
+	err = json.Unmarshal(winDetails, &wins)
+	if err != nil {
+		return nil, fmt.Errorf("Existing soda wins Unmarshalling error")
+	}
+	return &wins, nil
+}
+
+// DrawRequest is the input to verify a soda draw
+type DrawRequest struct {
+	TheatreID string `json:"thid"`   // Alphanumeric
+	DrawID    string `json:"drawid"` // Transaction ID of the soda exchange
+}
+
+// sodaNumber derives the number of a soda draw from the transaction and the theatre seed. It is same on all
+// the endorsing peers and can be recomputed by anyone once the seed is revealed
+func sodaNumber(txID string, seconds int64, nanos int32, seed []byte) uint8 {
+	sum := sha256.Sum256([]byte(txID + "|" + strconv.FormatInt(seconds, 10) + "." + strconv.Itoa(int(nanos)) + "|" + string(seed)))
+	return uint8(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
+
+// seedHash returns the hash of the seed recorded with the draws to match a draw with the seed it was decided with
+func seedHash(seed []byte) string {
+	sum := sha256.Sum256(seed)
+	return hex.EncodeToString(sum[:])
+}
+
+// sodaCollection returns the private data collection of the soda seeds of the theatres owned by the organization
+func sodaCollection(mspID string) string {
+	return sodaCollectionPrefix + mspID
+}
+
+// getSodaSeed fetches the current seed of the soda draws of the theatre from the private data collection of the
+// organization owning the theatre. Returns nil if the seed is not set
+func getSodaSeed(stub shim.ChaincodeStubInterface, td TheatreDetails) ([]byte, error) {
+	key, err := stateKey(stub, "SodaSeed", td.TheatreID)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := stub.GetPrivateData(sodaCollection(td.OwnerMSP), key)
+	if err != nil {
+		return nil, fmt.Errorf("GetPrivateData is Failed :%s", err.Error())
+	}
+	return seed, nil
+}
+
+// drawSoda decides if the customer is lucky enough to exchange water with soda as per the soda promotion of the
+// theatre and records the draw. The soda draws won for the day are counted against the daily cap of the promotion
+func drawSoda(stub shim.ChaincodeStubInterface, td TheatreDetails, invid string, ticketID string) (*SodaDraw, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("GetTxTimestamp is Failed :%s", err.Error())
+	}
+
+	sp, err := getSodaPromotion(stub, td.TheatreID)
+	if err != nil {
+		return nil, err
+	}
+	if !sp.active(td, ts.Seconds) {
+		return nil, newError(codeConflict, "Soda promotion is not active now")
+	}
+	wins, err := getSodaWins(stub, td.TheatreID, theatreDate(td, ts.Seconds))
+	if err != nil {
+		return nil, err
+	}
+	if sp.MaxWins > 0 && wins.Wins >= sp.MaxWins {
+		return nil, newError(codeCapacityExceeded, "Soda promotion wins exhausted for the day")
+	}
+
+	seed, err := getSodaSeed(stub, td)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) == 0 {
+		return nil, newError(codeConflict, "Soda seed is not set for the theatre")
+	}
+
+	draw := SodaDraw{
+		ObjType:     "SodaDraw",
+		DrawID:      stub.GetTxID(),
+		TheatreID:   td.TheatreID,
+		InventoryID: invid,
+		TicketID:    ticketID,
+		TxSeconds:   ts.Seconds,
+		TxNanos:     ts.Nanos,
+		SeedHash:    seedHash(seed),
+		WinPercent:  sp.WinPercent,
+	}
+	draw.Number = sodaNumber(draw.DrawID, draw.TxSeconds, draw.TxNanos, seed)
+	draw.Won = draw.Number < draw.WinPercent
+
+	drawjson, _ := json.Marshal(draw)
+	err = putRecord(stub, td, drawjson, "SodaDraw", td.TheatreID, draw.DrawID)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to record the soda draw")
+	}
+
+	if draw.Won {
+		wins.Wins++
+		winsjson, _ := json.Marshal(wins)
+		err = putRecord(stub, td, winsjson, "SodaWins", td.TheatreID, wins.WinDate)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to record the soda draw")
+		}
+	}
+	return &draw, nil
+}
+
+// getTicketForSoda fetches the ticket whose water is to be exchanged with soda. Only the tickets sold or redeemed
+// are eligible and the water of a ticket can be exchanged only once
+func getTicketForSoda(stub shim.ChaincodeStubInterface, thid string, ticketID string) (*Ticket, error) {
+	if ticketID == "" {
+		return nil, newError(codeInvalidInput, "Ticket is required to exchange soda")
+	}
+	t, err := getTicketRecord(stub, thid, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, newError(codeNotFound, "Ticket does not exists for the theatre")
+	}
+	if t.Status != ticketSold && t.Status != ticketRedeemed {
+		return nil, newError(codeConflict, "Ticket can not be used to exchange soda in %s status", t.Status)
+	}
+	if t.SodaDraw != "" {
+		return nil, newError(codeConflict, "Water of the ticket is already used for the soda draw %s", t.SodaDraw)
+	}
+	return t, nil
+}
+
+// exchangeTicketWater records the soda draw on the ticket. If the draw is won, the water of the ticket is
+// exchanged and the show-wise water and soda count is updated
+func exchangeTicketWater(stub shim.ChaincodeStubInterface, td TheatreDetails, t *Ticket, draw SodaDraw) error {
+	t.SodaDraw = draw.DrawID
+	t.WaterExch = draw.Won
+	err := putTicketRecord(stub, td, *t)
+	if err != nil {
+		return fmt.Errorf("Unable to update the ticket %s", t.TicketID)
+	}
+	if !draw.Won {
+		return nil
+	}
+
+	tkt, err := getTickets(stub, t.TheatreID, t.Screen, t.ShowDate, t.ShowCode)
+	if err != nil {
+		return err
+	}
+	if tkt == nil || tkt.WaterSold == 0 {
+		return newError(codeCapacityExceeded, "Water is not available to exchange for the show")
+	}
+	tkt.WaterSold--
+	tkt.SodaSold++
+	tktjson, _ := json.Marshal(tkt)
+	err = putRecord(stub, td, tktjson, "Tickets", t.TheatreID, t.Screen, t.ShowDate, t.ShowCode)
+	if err != nil {
+		return fmt.Errorf("Unable to exchange the water of the show")
+	}
+	return nil
+}
+
+// revealSodaSeed saves the current seed of the soda draws of the theatre in the public state before it is replaced
+func revealSodaSeed(stub shim.ChaincodeStubInterface, td TheatreDetails, seed []byte) error {
+	if len(seed) == 0 {
+		return nil
+	}
+	reveal := RevealedSeed{
+		ObjType:    "RevealedSeed",
+		TheatreID:  td.TheatreID,
+		SeedHash:   seedHash(seed),
+		Seed:       hex.EncodeToString(seed),
+		RevealedBy: stub.GetTxID(),
+	}
+	revealjson, _ := json.Marshal(reveal)
+	return putRecord(stub, td, revealjson, "RevealedSeed", td.TheatreID, reveal.SeedHash)
+}
+
+// Add or modify the soda promotion of a theatre. Seed of the soda draws is set or changed by providing it in the
+// transient data "sodaseed" so that it is not recorded with the transaction. Hash of the new seed is published with
+// the promotion and the seed being replaced is revealed
+func (s *ShowsManagement) addOrModifySodaPromotion(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("addOrModifySodaPromotion", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var sp SodaPromotion
+	err := json.Unmarshal([]byte(args[0]), &sp)
+	if err != nil {
+		return errorResponse("addOrModifySodaPromotion", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := sp.TheatreID
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("addOrModifySodaPromotion", thid, err)
+	}
+
+	if sp.WinPercent > 100 || sp.FromHour > 23 || sp.ToHour > 23 {
+		return errorResponse("addOrModifySodaPromotion", codeInvalidInput, thid, "Invalid soda promotion. Expected win percentage upto 100 and hours between 0 and 23")
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("addOrModifySodaPromotion:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("addOrModifySodaPromotion", errorCode(err), thid, "Unable to save the soda promotion")
+	}
+
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return errorResponse("addOrModifySodaPromotion", codeInvalidInput, thid, "Invalid transient data :"+err.Error())
+	}
+	existing, err := getSodaPromotion(stub, thid)
+	if err != nil {
+		return failedResponse("addOrModifySodaPromotion", thid, err)
+	}
+	sp.SeedHash = existing.SeedHash
+	seed, seedChanged := transient[sodaSeedKey]
+	if seedChanged {
+		if len(seed) < minSeedBytes {
+			return errorResponse("addOrModifySodaPromotion", codeInvalidInput, thid, "Soda seed must be "+strconv.Itoa(minSeedBytes)+" bytes or more")
+		}
+		// Seeds revealed or in use are known to the clients or would be revealed by the change
+		sp.SeedHash = seedHash(seed)
+		revealed, err := getRecord(stub, "RevealedSeed", thid, sp.SeedHash)
+		if err != nil {
+			return errorResponse("addOrModifySodaPromotion", codeLedgerError, thid, "GetState is Failed :"+err.Error())
+		}
+		if revealed != nil || sp.SeedHash == existing.SeedHash {
+			return errorResponse("addOrModifySodaPromotion", codeInvalidInput, thid, "Soda seed is already used by the theatre")
+		}
+		current, err := getSodaSeed(stub, *td)
+		if err == nil {
+			err = revealSodaSeed(stub, *td, current)
+		}
+		var key string
+		if err == nil {
+			key, err = stateKey(stub, "SodaSeed", thid)
+		}
+		if err == nil {
+			err = stub.PutPrivateData(sodaCollection(td.OwnerMSP), key, seed)
+		}
+		if err != nil {
+			_logger.Errorf("addOrModifySodaPromotion:PutPrivateData is Failed :" + string(err.Error()))
+			return errorResponse("addOrModifySodaPromotion", errorCode(err), thid, "Unable to save the soda seed")
+		}
+	}
+
+	// Every change of the promotion is recorded with the transaction making the change
+	sp.ObjType = "SodaPromotion"
+	sp.ChangedBy = stub.GetTxID()
+	sp.ChangedAt = now
+	spjson, _ := json.Marshal(sp)
+	err = putRecord(stub, *td, spjson, "SodaPromotion", thid)
+	if err != nil {
+		_logger.Errorf("addOrModifySodaPromotion:PutState is Failed :" + string(err.Error()))
+		return errorResponse("addOrModifySodaPromotion", errorCode(err), thid, "Unable to save the soda promotion")
+	}
+	_logger.Infof("addOrModifySodaPromotion:Soda promotion saved successfully for :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":      stub.GetTxID(),
+		"thid":        thid,
+		"seedchanged": seedChanged,
+		"seedhash":    sp.SeedHash,
+		"message":     "Soda promotion saved successfully",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Verify a past soda draw by recomputing it from the recorded inputs and the revealed seed of the theatre. Draws can be
+// verified on any peer once the seed they were decided with is replaced
+func (s *ShowsManagement) verifySodaDraw(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("verifySodaDraw", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var req DrawRequest
+	err := json.Unmarshal([]byte(args[0]), &req)
+	if err != nil {
+		return errorResponse("verifySodaDraw", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	drawDetails, err := getRecord(stub, "SodaDraw", req.TheatreID, req.DrawID)
+	if err != nil {
+		return errorResponse("verifySodaDraw", codeLedgerError, req.DrawID, "GetState is Failed :"+err.Error())
+	}
+	if drawDetails == nil {
+		return errorResponse("verifySodaDraw", codeNotFound, req.DrawID, "Soda draw does not exists")
+	}
+
+	draw := SodaDraw{}
+	err = json.Unmarshal(drawDetails, &draw)
+	if err != nil {
+		return errorResponse("verifySodaDraw", codeLedgerError, req.DrawID, "Existing soda draw Unmarshalling error")
+	}
+
+	revealDetails, err := getRecord(stub, "RevealedSeed", req.TheatreID, draw.SeedHash)
+	if err != nil {
+		return errorResponse("verifySodaDraw", codeLedgerError, req.DrawID, "GetState is Failed :"+err.Error())
+	}
+	if revealDetails == nil {
+		return errorResponse("verifySodaDraw", codeConflict, req.DrawID, "Seed of the soda draw is revealed only when the seed of the theatre is changed")
+	}
+	reveal := RevealedSeed{}
+	err = json.Unmarshal(revealDetails, &reveal)
+	if err != nil {
+		return errorResponse("verifySodaDraw", codeLedgerError, req.DrawID, "Existing revealed seed Unmarshalling error")
+	}
+	seed, err := hex.DecodeString(reveal.Seed)
+	if err != nil || seedHash(seed) != draw.SeedHash {
+		return errorResponse("verifySodaDraw", codeConflict, req.DrawID, "Revealed seed does not match the seed of the soda draw")
+	}
+
+	number := sodaNumber(draw.DrawID, draw.TxSeconds, draw.TxNanos, seed)
+	won := number < draw.WinPercent
+
+	result := map[string]interface{}{
+		"draw":     draw,
+		"number":   number,
+		"won":      won,
+		"verified": number == draw.Number && won == draw.Won,
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+func TestSodaDraws(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	ids := ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":3,"price":10000}`))
+
+	runCases(t, s, []invokeCase{
+		{name: "draw without a seed", fn: "exs", arg: `{"thid":"T1","inventoryid":"ES13","ticketid":"` + ids[0] + `"}`, code: codeConflict},
+	})
+	s.transient = map[string][]byte{sodaSeedKey: []byte("short")}
+	runCases(t, s, []invokeCase{
+		{name: "short seed", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":50}`, code: codeInvalidInput},
+	})
+	s.transient = nil
+	s.startSoda("T1", 50)
+
+	key, _ := s.CreateCompositeKey("SodaSeed", []string{"T1"})
+	if !bytes.Equal(s.PvtState["sodaSeedsOrg1MSP"][key], testSeed) {
+		t.Fatal("Soda seed is not saved in the private data collection of the owner")
+	}
+	sp := SodaPromotion{}
+	if s.record(&sp, "SodaPromotion", "T1"); sp.SeedHash != seedHash(testSeed) {
+		t.Errorf("Soda promotion :%+v, expected the hash of the seed", sp)
+	}
+
+	draw := s.mustInvoke("exs", `{"thid":"T1","inventoryid":"ES13","ticketid":"`+ids[0]+`"}`)
+	drawID := draw["drawid"].(string)
+	runCases(t, s, []invokeCase{
+		{name: "draw of the current seed", fn: "vdraw", arg: `{"thid":"T1","drawid":"` + drawID + `"}`, code: codeConflict},
+		{name: "unknown draw", fn: "vdraw", arg: `{"thid":"T1","drawid":"tx0"}`, code: codeNotFound},
+		{name: "promotion changed without the seed", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":40}`},
+		{name: "water of a ticket used again", fn: "exs", arg: `{"thid":"T1","inventoryid":"ES13","ticketid":"` + ids[0] + `"}`, code: codeConflict},
+		{name: "unknown ticket", fn: "exs", arg: `{"thid":"T1","inventoryid":"ES13","ticketid":"tx0-1"}`, code: codeNotFound},
+	})
+
+	recorded := SodaDraw{}
+	if !s.record(&recorded, "SodaDraw", "T1", drawID) {
+		t.Fatal("Soda draw is not recorded")
+	}
+	if recorded.SeedHash != seedHash(testSeed) || recorded.Won != draw["won"] {
+		t.Errorf("Soda draw recorded :%+v, response :%v", recorded, draw)
+	}
+	if recorded.Number != sodaNumber(drawID, day0, 0, testSeed) || recorded.Won != (recorded.Number < 50) {
+		t.Errorf("Soda draw %+v is not decided from the transaction and the seed", recorded)
+	}
+	for key, value := range s.State {
+		if bytes.Contains(value, testSeed) {
+			t.Errorf("Soda seed is saved in the public state under %q", key)
+		}
+	}
+
+	if s.record(&sp, "SodaPromotion", "T1"); sp.SeedHash != seedHash(testSeed) || sp.WinPercent != 40 {
+		t.Errorf("Soda promotion changed without the seed :%+v, expected the hash of the seed kept", sp)
+	}
+
+	// Seed replaced is revealed and the draws decided with it can be verified by any organization
+	s.transient = map[string][]byte{sodaSeedKey: testSeed}
+	runCases(t, s, []invokeCase{
+		{name: "current seed set again", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":50}`, code: codeInvalidInput},
+	})
+	s.transient = map[string][]byte{sodaSeedKey: []byte("fedcba9876543210")}
+	runCases(t, s, []invokeCase{
+		{name: "new seed", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":50}`},
+	})
+	s.transient = map[string][]byte{sodaSeedKey: testSeed}
+	runCases(t, s, []invokeCase{
+		{name: "revealed seed set again", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":50}`, code: codeInvalidInput},
+	})
+	s.transient = nil
+
+	reveal := RevealedSeed{}
+	if !s.record(&reveal, "RevealedSeed", "T1", seedHash(testSeed)) || reveal.Seed != hex.EncodeToString(testSeed) {
+		t.Errorf("Seed replaced :%+v, expected it revealed", reveal)
+	}
+	var verified struct {
+		Number   uint8 `json:"number"`
+		Verified bool  `json:"verified"`
+	}
+	s.as("Org2MSP", "")
+	s.result("vdraw", `{"thid":"T1","drawid":"`+drawID+`"}`, &verified)
+	s.as(testMSP, roleManager)
+	if !verified.Verified || verified.Number != recorded.Number {
+		t.Errorf("Draw of the revealed seed :%+v, expected it verified", verified)
+	}
+}
+
+func TestSodaSeedsOfOrganizations(t *testing.T) {
+	s := newTestStub(t)
+	s.as("Org2MSP", roleManager)
+	s.addTheatre("T2", "")
+	ids := ticketIDs(s.mustInvoke("sell", `{"thid":"T2","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`))
+	s.transient = map[string][]byte{sodaSeedKey: testSeed}
+	s.mustInvoke("asp", `{"thid":"T2","enabled":true,"winpercent":50}`)
+	s.transient = nil
+
+	key, _ := s.CreateCompositeKey("SodaSeed", []string{"T2"})
+	if !bytes.Equal(s.PvtState["sodaSeedsOrg2MSP"][key], testSeed) || len(s.PvtState["sodaSeedsOrg1MSP"]) != 0 {
+		t.Errorf("Soda seeds by the collection :%v, expected the seed in the collection of Org2MSP", s.PvtState)
+	}
+	s.mustInvoke("exs", `{"thid":"T2","inventoryid":"ES13","ticketid":"`+ids[0]+`"}`)
+	s.as(testMSP, roleManager)
+}
+
+func TestSodaNumber(t *testing.T) {
+	tests := []struct {
+		txID    string
+		seconds int64
+		nanos   int32
+		seed    []byte
+	}{
+		{"tx1", day0, 0, testSeed},
+		{"tx2", day0, 0, testSeed},
+		{"tx1", day0 + 1, 0, testSeed},
+		{"tx1", day0, 500, testSeed},
+		{"tx1", day0, 0, []byte("fedcba9876543210")},
+	}
+	for _, tt := range tests {
+		number := sodaNumber(tt.txID, tt.seconds, tt.nanos, tt.seed)
+		if number > 99 {
+			t.Errorf("Number %d of %s is out of 0 to 99", number, tt.txID)
+		}
+		if again := sodaNumber(tt.txID, tt.seconds, tt.nanos, tt.seed); again != number {
+			t.Errorf("Numbers %d and %d of the same draw %s differ", number, again, tt.txID)
+		}
+	}
+}
+
+func TestSodaPromotion(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	ids := ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":4,"price":10000}`))
+	s.startSoda("T1", 0)
+	exs := func(n int) string {
+		return `{"thid":"T1","inventoryid":"ES13","ticketid":"` + ids[n] + `"}`
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "win percent over 100", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":101}`, code: codeInvalidInput},
+		{name: "hour over 23", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":50,"fromhour":24}`, code: codeInvalidInput},
+		{name: "unknown theatre", fn: "asp", arg: `{"thid":"T9","enabled":true,"winpercent":50}`, code: codeNotFound},
+		{name: "by a cashier", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":50}`, code: codeUnauthorized, role: roleCashier},
+		{name: "draw never won", fn: "exs", arg: exs(0)},
+		{name: "disabled", fn: "asp", arg: `{"thid":"T1","enabled":false,"winpercent":100}`},
+		{name: "draw while disabled", fn: "exs", arg: exs(1), code: codeConflict},
+		{name: "evening hours", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":100,"maxwins":1,"fromhour":18,"tohour":22}`},
+		{name: "draw before the hours", fn: "exs", arg: exs(1), code: codeConflict, at: day0 + 17*3600},
+		{name: "draw in the hours", fn: "exs", arg: exs(1), at: day0 + 18*3600},
+		{name: "draw over the daily wins", fn: "exs", arg: exs(2), code: codeCapacityExceeded},
+		{name: "overnight hours", fn: "asp", arg: `{"thid":"T1","enabled":true,"winpercent":100,"fromhour":22,"tohour":2}`},
+		{name: "draw after midnight", fn: "exs", arg: exs(2), at: day0 + 25*3600},
+		{name: "draw after the overnight hours", fn: "exs", arg: exs(3), code: codeConflict, at: day0 + 26*3600},
+	})
+
+	wins := []struct {
+		ticket string
+		won    bool
+	}{
+		{ids[0], false},
+		{ids[1], true},
+		{ids[2], true},
+		{ids[3], false},
+	}
+	for _, w := range wins {
+		tr := Ticket{}
+		s.record(&tr, "Ticket", "T1", w.ticket)
+		if tr.WaterExch != w.won {
+			t.Errorf("Water of the ticket %s exchanged :%v, expected %v", w.ticket, tr.WaterExch, w.won)
+		}
+	}
+	daily := SodaWins{}
+	if !s.record(&daily, "SodaWins", "T1", date0) || daily.Wins != 1 {
+		t.Errorf("Soda wins of the day :%+v, expected 1", daily)
+	}
+}
+
+func TestSodaExchangeOfTickets(t *testing.T) {
+	s := newTestStub(t)
+	s.mustInvoke("athd", `{"thid":"T1","maxsoda":1,"sph":{"SC1":4}}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","shows":[{"showcode":"1","start":`+strconv.FormatInt(day0+10*3600, 10)+`}]}`)
+	s.mustInvoke("athd", `{"thid":"T2","maxsoda":200,"sph":{"SC1":4}}`)
+	ids := ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":4,"price":10000}`))
+	s.startSoda("T1", 100)
+	s.mustInvoke("ctkt", `{"thid":"T1","ticketid":"`+ids[1]+`"}`)
+	s.mustInvoke("redeem", `{"thid":"T1","ticketid":"`+ids[2]+`"}`)
+	exs := func(thid string, ticketID string) string {
+		return `{"thid":"` + thid + `","inventoryid":"ES13","ticketid":"` + ticketID + `"}`
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "without a ticket", fn: "exs", arg: `{"thid":"T1","inventoryid":"ES13"}`, code: codeInvalidInput},
+		{name: "ticket of another theatre", fn: "exs", arg: exs("T2", ids[0]), code: codeNotFound},
+		{name: "cancelled ticket", fn: "exs", arg: exs("T1", ids[1]), code: codeConflict},
+		{name: "redeemed ticket", fn: "exs", arg: exs("T1", ids[2]), role: roleCashier},
+		{name: "ticket used again", fn: "exs", arg: exs("T1", ids[2]), code: codeConflict},
+		{name: "soda of the day sold out", fn: "exs", arg: exs("T1", ids[0]), code: codeCapacityExceeded},
+		{name: "exchanged ticket cancelled", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":2}`},
+	})
+
+	tr := Ticket{}
+	s.record(&tr, "Ticket", "T1", ids[2])
+	if tr.SodaDraw == "" || !tr.WaterExch {
+		t.Errorf("Ticket of the soda exchange :%+v", tr)
+	}
+	// Water of the redeemed ticket is exchanged and the water of the cancelled tickets is returned
+	tkt := Tickets{}
+	s.record(&tkt, "Tickets", "T1", "SC1", date0, "1")
+	if tkt.TicketsSold != 1 || tkt.WaterSold != 0 || tkt.SodaSold != 1 {
+		t.Errorf("Tickets after the exchange and the cancellation :%+v, expected 1 ticket, no water and 1 soda", tkt)
+	}
+}
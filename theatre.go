@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// TheatreUpdate is the input to update the theatre details. Details not provided are left unchanged
+type TheatreUpdate struct {
+	TheatreID     string            `json:"thid"`         // Alphanumeric
+	SeatsPerHall  map[string]uint32 `json:"sph"`          // Screens to add or to change the seat count of. Other screens are left unchanged
+	MaxSodaPerDay *uint32           `json:"maxsoda"`      // Min 0
+	HoldSeconds   *int64            `json:"holdsecs"`     // Seconds a hold on tickets is valid
+	RefundPolicy  []RefundRule      `json:"refundpolicy"` // Replaces the refund policy when provided
+	UTCOffset     *int64            `json:"utcoffset"`    // Offset of the theatre time zone from UTC in minutes
+	Concessions   map[string]uint32 `json:"concessions"`  // Concession items to add or to change the price of. Price 0 removes the item
+}
+
+// TheatreStatus is the input to deactivate or reactivate a theatre or a screen of the theatre
+type TheatreStatus struct {
+	TheatreID string `json:"thid"`   // Alphanumeric
+	Screen    string `json:"screen"` // Screen to deactivate or reactivate. Whole theatre if not provided
+}
+
+// salesOpen checks if new tickets can be sold for the screen of the theatre
+func (td TheatreDetails) salesOpen(sc string) error {
+	if td.Inactive {
+		return newError(codeInactive, "Theatre %s is deactivated", td.TheatreID)
+	}
+	if contains(td.InactiveScreens, sc) {
+		return newError(codeInactive, "Screen %s of the theatre %s is deactivated", sc, td.TheatreID)
+	}
+	return nil
+}
+
+// reservedItems are issued with the tickets or exchanged through the soda inventory. They are counted by the
+// tickets and the soda sold, and can not be sold as concessions
+var reservedItems = []string{"popcorn", "water", "soda"}
+
+// checkTheatreSettings validates the refund policy, the hold duration and the concession items of a theatre
+func checkTheatreSettings(policy []RefundRule, holdSeconds int64, concessions map[string]uint32) error {
+	for _, rule := range policy {
+		if rule.Percent > 100 || rule.MinutesBefore < 0 {
+			return newError(codeInvalidInput, "Invalid refund policy. Refund percentage can not be more than 100 and minutes before the show can not be negative")
+		}
+	}
+	if holdSeconds < 0 || holdSeconds > maxHoldSeconds {
+		return newError(codeInvalidInput, "Invalid hold seconds %d. Expected 0 to %d seconds", holdSeconds, maxHoldSeconds)
+	}
+	for item := range concessions {
+		if contains(reservedItems, strings.ToLower(item)) {
+			return newError(codeInvalidInput, "Concession item %s is issued with the tickets or the soda inventory and can not be sold in a cart", item)
+		}
+	}
+	return nil
+}
+
+// seatsInUse returns the most tickets sold and held for a show of the screen. Past shows not yet closed by the daily
+// reset are included
+func seatsInUse(stub shim.ChaincodeStubInterface, thid string, sc string, now int64) (int, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("Tickets", []string{thid, sc})
+	if err != nil {
+		return 0, fmt.Errorf("GetStateByPartialCompositeKey is Failed :%s", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	most := 0
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			return 0, fmt.Errorf("Query iteration is Failed :%s", err.Error())
+		}
+		tkt := Tickets{}
+		err = json.Unmarshal(record.Value, &tkt)
+		if err != nil {
+			return 0, fmt.Errorf("Existing ticket details Unmarshalling error")
+		}
+		holds, err := getShowHolds(stub, thid, sc, tkt.ShowDate, tkt.ShowCode)
+		if err != nil {
+			return 0, err
+		}
+		inUse := int(tkt.TicketsSold) + holds.liveCount(now)
+		if inUse > most {
+			most = inUse
+		}
+	}
+	return most, nil
+}
+
+// Update the theatre details. Screens can be added and the seat count of a screen can be changed, but not below the
+// tickets sold and held for the shows of the screen. Seat count of a screen with a seat map is changed with the seat map
+func (s *ShowsManagement) updateTheatreDetails(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("updateTheatreDetails", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var update TheatreUpdate
+	err := json.Unmarshal([]byte(args[0]), &update)
+	if err != nil {
+		return errorResponse("updateTheatreDetails", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := update.TheatreID
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("updateTheatreDetails", thid, err)
+	}
+
+	holdSeconds := int64(0)
+	if update.HoldSeconds != nil {
+		holdSeconds = *update.HoldSeconds
+	}
+	err = checkTheatreSettings(update.RefundPolicy, holdSeconds, update.Concessions)
+	if err != nil {
+		return failedResponse("updateTheatreDetails", thid, err)
+	}
+
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("updateTheatreDetails:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("updateTheatreDetails", errorCode(err), thid, "Unable to update theatre details")
+	}
+
+	screens := []string{}
+	for sc := range update.SeatsPerHall {
+		screens = append(screens, sc)
+	}
+	sort.Strings(screens)
+
+	// Screens are deactivated instead of being removed so that the shows and tickets of the screen are preserved
+	for _, sc := range screens {
+		seats := update.SeatsPerHall[sc]
+		if sc == "" || seats == 0 {
+			return errorResponse("updateTheatreDetails", codeInvalidInput, sc, "Screen and a seat count of 1 or more are required. Deactivate the screen to stop the sales")
+		}
+		if seats == td.SeatsPerHall[sc] {
+			continue
+		}
+		sm, err := getSeatMap(stub, thid, sc)
+		if err != nil {
+			return failedResponse("updateTheatreDetails", thid, err)
+		}
+		if sm != nil {
+			return errorResponse("updateTheatreDetails", codeConflict, sc, "Seat count of a screen with a seat map is changed by replacing the seat map. Seat map has "+strconv.Itoa(int(td.SeatsPerHall[sc]))+" seats")
+		}
+		if seats > td.SeatsPerHall[sc] {
+			continue
+		}
+		inUse, err := seatsInUse(stub, thid, sc, now)
+		if err != nil {
+			return failedResponse("updateTheatreDetails", thid, err)
+		}
+		if !withinCapacity(seats, inUse) {
+			return errorResponse("updateTheatreDetails", codeConflict, sc, "Seat count can not be less than the "+strconv.Itoa(inUse)+" tickets sold and held for a show of the screen")
+		}
+	}
+
+	if td.SeatsPerHall == nil {
+		td.SeatsPerHall = map[string]uint32{}
+	}
+	for _, sc := range screens {
+		td.SeatsPerHall[sc] = update.SeatsPerHall[sc]
+	}
+	if update.MaxSodaPerDay != nil {
+		td.MaxSodaPerDay = *update.MaxSodaPerDay
+	}
+	if update.HoldSeconds != nil {
+		td.HoldSeconds = *update.HoldSeconds
+	}
+	if update.RefundPolicy != nil {
+		td.RefundPolicy = update.RefundPolicy
+	}
+	if update.UTCOffset != nil {
+		td.UTCOffset = *update.UTCOffset
+	}
+	for item, price := range update.Concessions {
+		if td.Concessions == nil {
+			td.Concessions = map[string]uint32{}
+		}
+		if price == 0 {
+			delete(td.Concessions, item)
+			continue
+		}
+		td.Concessions[item] = price
+	}
+
+	tdjson, _ := json.Marshal(td)
+	err = putRecord(stub, *td, tdjson, "TheatreDetails", thid)
+	if err != nil {
+		_logger.Errorf("updateTheatreDetails:PutState is Failed :" + string(err.Error()))
+		return errorResponse("updateTheatreDetails", errorCode(err), thid, "Unable to update theatre details")
+	}
+	_logger.Infof("updateTheatreDetails:Theatre details updated succesfully for :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":  stub.GetTxID(),
+		"screens": screens,
+		"message": "Update Theatre Details Success",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Deactivate or reactivate a theatre or a screen of the theatre. Tickets can not be sold or held for a deactivated
+// theatre or screen. Shows, tickets and soda of the theatre are left as is
+func (s *ShowsManagement) setTheatreActive(stub shim.ChaincodeStubInterface, args []string, active bool) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("setTheatreActive", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var status TheatreStatus
+	err := json.Unmarshal([]byte(args[0]), &status)
+	if err != nil {
+		return errorResponse("setTheatreActive", codeInvalidInput, args[0], "Invalid json provided as input")
+	}
+
+	thid := status.TheatreID
+	sc := status.Screen
+	td, err := getTheatreDetails(stub, thid)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", thid)
+	}
+	if err != nil {
+		return failedResponse("setTheatreActive", thid, err)
+	}
+
+	if sc == "" {
+		td.Inactive = !active
+	} else {
+		if td.SeatsPerHall[sc] == 0 {
+			return errorResponse("setTheatreActive", codeNotFound, sc, "Screen does not exists for the theatre")
+		}
+		inactive := []string{}
+		for _, screen := range td.InactiveScreens {
+			if screen != sc {
+				inactive = append(inactive, screen)
+			}
+		}
+		if !active {
+			inactive = append(inactive, sc)
+			sort.Strings(inactive)
+		}
+		td.InactiveScreens = inactive
+	}
+
+	tdjson, _ := json.Marshal(td)
+	err = putRecord(stub, *td, tdjson, "TheatreDetails", thid)
+	if err != nil {
+		_logger.Errorf("setTheatreActive:PutState is Failed :" + string(err.Error()))
+		return errorResponse("setTheatreActive", errorCode(err), thid, "Unable to change the theatre status")
+	}
+	_logger.Infof("setTheatreActive:Theatre status changed succesfully for :" + string(thid))
+
+	result := map[string]interface{}{
+		"trxnid":          stub.GetTxID(),
+		"active":          !td.Inactive,
+		"inactivescreens": td.InactiveScreens,
+		"message":         "Change Theatre Status Success",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestUpdateTheatre(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", `,"concessions":{"coffee":500,"nachos":700}`)
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000}`)
+	s.mustInvoke("hold", `{"thid":"T1","screen":"SC1","showcode":"1","count":1}`)
+
+	runCases(t, s, []invokeCase{
+		{name: "theatre added again", fn: "athd", arg: `{"thid":"T1","maxsoda":200,"sph":{"SC1":8}}`, code: codeAlreadyExists},
+		{name: "unknown theatre", fn: "uthd", arg: `{"thid":"T9","maxsoda":300}`, code: codeNotFound},
+		{name: "by a cashier", fn: "uthd", arg: `{"thid":"T1","maxsoda":300}`, code: codeUnauthorized, role: roleCashier},
+		{name: "by another organization", fn: "uthd", arg: `{"thid":"T1","maxsoda":300}`, code: codeUnauthorized, msp: "Org2MSP"},
+		{name: "screen without seats", fn: "uthd", arg: `{"thid":"T1","sph":{"SC1":0}}`, code: codeInvalidInput},
+		{name: "hold over a day", fn: "uthd", arg: `{"thid":"T1","holdsecs":86401}`, code: codeInvalidInput},
+		{name: "refund over 100 percent", fn: "uthd", arg: `{"thid":"T1","refundpolicy":[{"minsbefore":60,"percent":101}]}`, code: codeInvalidInput},
+		{name: "popcorn sold as a concession", fn: "uthd", arg: `{"thid":"T1","concessions":{"Popcorn":300}}`, code: codeInvalidInput},
+		{name: "soda sold as a concession", fn: "uthd", arg: `{"thid":"T1","concessions":{"tea":200,"soda":400}}`, code: codeInvalidInput},
+		{name: "theatre with water sold as a concession", fn: "athd", arg: `{"thid":"T2","maxsoda":200,"sph":{"SC1":4},"concessions":{"water":100}}`, code: codeInvalidInput},
+		{name: "below the tickets sold and held", fn: "uthd", arg: `{"thid":"T1","sph":{"SC1":2}}`, code: codeConflict},
+		{name: "new screen and settings", fn: "uthd", arg: `{"thid":"T1","sph":{"SC1":3,"SC2":5},"maxsoda":300,"holdsecs":86400,"concessions":{"nachos":0,"tea":200}}`},
+		{name: "below the tickets sold after the hold expired", fn: "uthd", arg: `{"thid":"T1","sph":{"SC1":2}}`, at: day0 + defaultHoldSeconds},
+		{name: "seat map of the new screen", fn: "asm", arg: `{"thid":"T1","screen":"SC2","rows":[{"row":"A","seats":5}]}`},
+		{name: "screen with a seat map", fn: "uthd", arg: `{"thid":"T1","sph":{"SC2":6}}`, code: codeConflict},
+		{name: "same seats of the screen with a seat map", fn: "uthd", arg: `{"thid":"T1","sph":{"SC2":5}}`},
+		{name: "seat map of the screen replaced", fn: "asm", arg: `{"thid":"T1","screen":"SC2","rows":[{"row":"A","seats":5},{"row":"B","seats":1}]}`},
+	})
+
+	td := TheatreDetails{}
+	s.record(&td, "TheatreDetails", "T1")
+	if td.SeatsPerHall["SC1"] != 2 || td.SeatsPerHall["SC2"] != 6 || td.MaxSodaPerDay != 300 || td.HoldSeconds != 86400 {
+		t.Errorf("Theatre updated :%+v", td)
+	}
+	if len(td.Concessions) != 2 || td.Concessions["coffee"] != 500 || td.Concessions["tea"] != 200 {
+		t.Errorf("Concessions updated :%v, expected coffee and tea", td.Concessions)
+	}
+}
+
+func TestReplaceSeatMap(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	s.mustInvoke("asm", `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":2},{"row":"B","seats":2}]}`)
+	s.mustInvoke("sells", `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1","B2"],"price":10000}`)
+	s.mustInvoke("hold", `{"thid":"T1","screen":"SC1","showcode":"2","seats":["A2"]}`)
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":2,"price":10000}`)
+	rows := func(rows string) string {
+		return `{"thid":"T1","screen":"SC1","rows":[` + rows + `]}`
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "below the tickets sold and held", fn: "asm", arg: rows(`{"row":"A","seats":1},{"row":"B","seats":1}`), code: codeConflict},
+		{name: "without a seat sold", fn: "asm", arg: rows(`{"row":"B","seats":3},{"row":"C","seats":2}`), code: codeConflict},
+		{name: "without a seat held", fn: "asm", arg: rows(`{"row":"A","seats":1},{"row":"B","seats":3}`), code: codeConflict},
+		{name: "larger seat map", fn: "asm", arg: rows(`{"row":"A","seats":2},{"row":"B","seats":3,"category":"Gold"},{"row":"C","seats":1}`)},
+		{name: "seat of the new seat map", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"1","seats":["C1"],"price":10000}`},
+		{name: "seat count of the screen with the seat map", fn: "uthd", arg: `{"thid":"T1","sph":{"SC1":8}}`, code: codeConflict},
+		{name: "without the seat held after the hold expired", fn: "asm", arg: rows(`{"row":"A","seats":1},{"row":"B","seats":3},{"row":"C","seats":1}`), at: day0 + defaultHoldSeconds},
+	})
+
+	td := TheatreDetails{}
+	sm := SeatMap{}
+	s.record(&td, "TheatreDetails", "T1")
+	s.record(&sm, "SeatMap", "T1", "SC1")
+	if td.SeatsPerHall["SC1"] != 5 || len(sm.Rows) != 3 {
+		t.Errorf("Screen after the seat map is replaced :%v, seat map :%+v, expected 5 seats", td.SeatsPerHall, sm)
+	}
+}
+
+func TestDeactivateTheatre(t *testing.T) {
+	s := newTestStub(t)
+	s.mustInvoke("athd", `{"thid":"T1","maxsoda":200,"sph":{"SC1":4,"SC2":4}}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","shows":[{"showcode":"1","start":`+strconv.FormatInt(day0+10*3600, 10)+`}]}`)
+	s.mustInvoke("asd", `{"moviename":"Tenet","screen":"SC2","thid":"T1","showcode":["1"]}`)
+	ids := ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000}`))
+	sell := func(sc string) string {
+		return `{"thid":"T1","screen":"` + sc + `","showcode":"1","ticketsold":1,"price":10000}`
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "unknown screen", fn: "dact", arg: `{"thid":"T1","screen":"SC9"}`, code: codeNotFound},
+		{name: "unknown theatre", fn: "dact", arg: `{"thid":"T9"}`, code: codeNotFound},
+		{name: "by a cashier", fn: "dact", arg: `{"thid":"T1","screen":"SC1"}`, code: codeUnauthorized, role: roleCashier},
+		{name: "screen", fn: "dact", arg: `{"thid":"T1","screen":"SC1"}`},
+		{name: "sale for the deactivated screen", fn: "sell", arg: sell("SC1"), code: codeInactive},
+		{name: "hold for the deactivated screen", fn: "hold", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":1}`, code: codeInactive},
+		{name: "sale for another screen", fn: "sell", arg: sell("SC2")},
+		{name: "ticket of the deactivated screen redeemed", fn: "redeem", arg: `{"thid":"T1","ticketid":"` + ids[0] + `"}`, role: roleCashier},
+		{name: "ticket of the deactivated screen cancelled", fn: "ctkt", arg: `{"thid":"T1","ticketid":"` + ids[1] + `"}`},
+		{name: "theatre", fn: "dact", arg: `{"thid":"T1"}`},
+		{name: "sale for the deactivated theatre", fn: "sell", arg: sell("SC2"), code: codeInactive},
+		{name: "deactivated theatre queried", fn: "gth", arg: `{"thid":"T1"}`, role: "none"},
+		{name: "theatre reactivated", fn: "ract", arg: `{"thid":"T1"}`},
+		{name: "screen still deactivated", fn: "sell", arg: sell("SC1"), code: codeInactive},
+		{name: "screen reactivated", fn: "ract", arg: `{"thid":"T1","screen":"SC1"}`},
+		{name: "sale after the reactivation", fn: "sell", arg: sell("SC1")},
+	})
+
+	tkt := Tickets{}
+	s.record(&tkt, "Tickets", "T1", "SC1", date0, "1")
+	if tkt.TicketsSold != 2 {
+		t.Errorf("Tickets of the screen :%+v, expected 2 sold", tkt)
+	}
+	td := TheatreDetails{}
+	s.record(&td, "TheatreDetails", "T1")
+	if td.Inactive || len(td.InactiveScreens) != 0 {
+		t.Errorf("Theatre after the reactivation :%+v", td)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Status of an individual ticket
+const (
+	ticketSold      = "SOLD"
+	ticketCancelled = "CANCELLED"
+	ticketRedeemed  = "REDEEMED"
+)
+
+// Ticket is an individual ticket issued on a sale. Show-wise "Tickets" count is maintained along with the ticket records
+type Ticket struct {
+	ObjType   string `json:"obj"`
+	TicketID  string `json:"ticketid"`  // Transaction ID of the sale + "-" + ticket number in the sale (ex: "<trxnid>-1")
+	TheatreID string `json:"thid"`      // Alphanumeric
+	MovieName string `json:"moviename"` //
+	Screen    string `json:"screen"`    // Alphanumeric
+	ShowDate  string `json:"showdate"`  // YYYY-MM-DD
+	ShowCode  string `json:"showcode"`  //
+	Seat      string `json:"seat"`      // Empty if the ticket is sold without a seat
+	Price     uint32 `json:"price"`     // Price of the ticket in the smallest currency unit (ex: paise)
+	Category  string `json:"category"`  // Seat category the ticket is priced for. Empty if the ticket is sold without a seat
+	Format    string `json:"format"`    // Format of the show the ticket is priced for
+	Discount  uint32 `json:"discount"`  // Discount of the coupon. Price is after the discount
+	Coupon    string `json:"coupon"`    // Coupon applied to the sale, if any
+	Customer  string `json:"customer"`  // Current owner of the ticket
+	Status    string `json:"status"`    // SOLD, CANCELLED or REDEEMED
+	SodaDraw  string `json:"sodadraw"`  // Soda draw done with the water of the ticket, if any
+	WaterExch bool   `json:"waterexch"` // Water of the ticket is exchanged with soda
+	CreateTs  string `json:"cts"`       // RFC3339. Transaction timestamp of the first save
+	UpdateTs  string `json:"uts"`       // RFC3339. Transaction timestamp of the latest save
+}
+
+// ShowTicket indexes a ticket sold without a seat by the show. Tickets cancelled by the count are picked from the
+// index of the show. Index is removed when the ticket is cancelled
+type ShowTicket struct {
+	ObjType   string `json:"obj"`
+	TheatreID string `json:"thid"`     // Alphanumeric
+	Screen    string `json:"screen"`   // Alphanumeric
+	ShowDate  string `json:"showdate"` // YYYY-MM-DD
+	ShowCode  string `json:"showcode"` //
+	TicketID  string `json:"ticketid"` //
+	CreateTs  string `json:"cts"`      // RFC3339. Transaction timestamp of the first save
+	UpdateTs  string `json:"uts"`      // RFC3339. Transaction timestamp of the latest save
+}
+
+// TicketSale has the customer and price details provided along with the input of a sale
+type TicketSale struct {
+	Price    uint32 `json:"price"`    // Price per ticket. Must match the price list of the screen, if added
+	Customer string `json:"customer"` //
+	Coupon   string `json:"coupon"`   // Coupon of the theatre to apply to the sale
+}
+
+// TicketRequest is the input to query, cancel, transfer or redeem a ticket
+type TicketRequest struct {
+	TheatreID string `json:"thid"`     // Alphanumeric
+	TicketID  string `json:"ticketid"` //
+	Customer  string `json:"customer"` // New owner of the ticket. Required for transfer
+}
+
+// ticketID returns the ID of the n-th ticket issued on the sale transaction
+func ticketID(txID string, n int) string {
+	return txID + "-" + strconv.Itoa(n)
+}
+
+// issueTickets adds the ticket records of a sale at the quoted prices. Seats, if any, are assigned in the order of the
+// quote. Tickets are numbered after the tickets issued before in the transaction
+func issueTickets(stub shim.ChaincodeStubInterface, td TheatreDetails, tkt Tickets, quote SaleQuote, customer string, issued int) ([]string, error) {
+	var ids []string
+	for n, priced := range quote.Tickets {
+		t := Ticket{
+			ObjType:   "Ticket",
+			TicketID:  ticketID(stub.GetTxID(), issued+n+1),
+			TheatreID: tkt.TheatreID,
+			MovieName: tkt.MovieName,
+			Screen:    tkt.Screen,
+			ShowDate:  tkt.ShowDate,
+			ShowCode:  tkt.ShowCode,
+			Seat:      priced.Seat,
+			Price:     priced.Price - priced.Discount,
+			Category:  priced.Category,
+			Format:    quote.Format,
+			Discount:  priced.Discount,
+			Coupon:    quote.Coupon,
+			Customer:  customer,
+			Status:    ticketSold,
+		}
+		err := putTicketRecord(stub, td, t)
+		if err == nil && t.Seat == "" {
+			err = putShowTicket(stub, td, t)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, t.TicketID)
+	}
+	return ids, nil
+}
+
+// getTicketRecord fetches an individual ticket. Returns nil if the ticket does not exists
+func getTicketRecord(stub shim.ChaincodeStubInterface, thid string, id string) (*Ticket, error) {
+	ticketDetails, err := getRecord(stub, "Ticket", thid, id)
+	if err != nil {
+		return nil, fmt.Errorf("GetState is Failed :%s", err.Error())
+	}
+	if ticketDetails == nil {
+		return nil, nil
+	}
+	t := Ticket{}
+	err = json.Unmarshal(ticketDetails, &t)
+	if err != nil {
+		return nil, fmt.Errorf("Existing ticket record Unmarshalling error")
+	}
+	return &t, nil
+}
+
+// putTicketRecord saves an individual ticket
+func putTicketRecord(stub shim.ChaincodeStubInterface, td TheatreDetails, t Ticket) error {
+	t.ObjType = "Ticket"
+	tjson, _ := json.Marshal(t)
+	return putRecord(stub, td, tjson, "Ticket", t.TheatreID, t.TicketID)
+}
+
+// putShowTicket indexes a ticket sold without a seat by the show
+func putShowTicket(stub shim.ChaincodeStubInterface, td TheatreDetails, t Ticket) error {
+	st := ShowTicket{
+		ObjType:   "ShowTicket",
+		TheatreID: t.TheatreID,
+		Screen:    t.Screen,
+		ShowDate:  t.ShowDate,
+		ShowCode:  t.ShowCode,
+		TicketID:  t.TicketID,
+	}
+	stjson, _ := json.Marshal(st)
+	return putRecord(stub, td, stjson, "ShowTicket", t.TheatreID, t.Screen, t.ShowDate, t.ShowCode, t.TicketID)
+}
+
+// parseTicketRequest validates the input of the ticket functions and fetches the requested ticket
+func parseTicketRequest(stub shim.ChaincodeStubInterface, fn string, args []string) (TicketRequest, *Ticket, *pb.Response) {
+	var req TicketRequest
+
+	if len(args) != 1 {
+		resp := errorResponse(fn, codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+		return req, nil, &resp
+	}
+
+	err := json.Unmarshal([]byte(args[0]), &req)
+	if err != nil {
+		resp := errorResponse(fn, codeInvalidInput, args[0], "Invalid json provided as input")
+		return req, nil, &resp
+	}
+
+	t, err := getTicketRecord(stub, req.TheatreID, req.TicketID)
+	if err == nil && t == nil {
+		err = newError(codeNotFound, "Ticket does not exists for the theatre")
+	}
+	if err != nil {
+		resp := failedResponse(fn, req.TicketID, err)
+		return req, nil, &resp
+	}
+	return req, t, nil
+}
+
+// Get an individual ticket
+func (s *ShowsManagement) queryTicket(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	_, t, errResp := parseTicketRequest(stub, "queryTicket", args)
+	if errResp != nil {
+		return *errResp
+	}
+
+	resultData := map[string]interface{}{
+		"status": "true",
+		"ticket": t,
+	}
+	respjson, _ := json.Marshal(resultData)
+	return shim.Success(respjson)
+}
+
+// Cancel an individual ticket. Refund is based on the ticket price and the refund policy of the theatre
+func (s *ShowsManagement) cancelTicket(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	_, t, errResp := parseTicketRequest(stub, "cancelTicket", args)
+	if errResp != nil {
+		return *errResp
+	}
+
+	cn := Cancellation{
+		TheatreID: t.TheatreID,
+		Screen:    t.Screen,
+		ShowDate:  t.ShowDate,
+		ShowCode:  t.ShowCode,
+		Count:     1,
+	}
+	if t.Seat != "" {
+		cn.Count = 0
+		cn.Seats = []string{t.Seat}
+	}
+
+	refund, err := applyCancellation(stub, cn, []string{t.TicketID})
+	if err != nil {
+		return failedResponse("cancelTicket", t.TicketID, err)
+	}
+	_logger.Infof("cancelTicket:Ticket cancelled successfully")
+
+	result := map[string]interface{}{
+		"trxnid":        stub.GetTxID(),
+		"ticketid":      t.TicketID,
+		"refundPercent": refund.Percent,
+		"refund":        refund.RefundAmount,
+		"message":       "Cancel ticket successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Transfer an individual ticket to another customer
+func (s *ShowsManagement) transferTicket(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	req, t, errResp := parseTicketRequest(stub, "transferTicket", args)
+	if errResp != nil {
+		return *errResp
+	}
+
+	if req.Customer == "" || req.Customer == t.Customer {
+		return errorResponse("transferTicket", codeInvalidInput, req.Customer, "Invalid request to transfer the ticket. Expected a new customer")
+	}
+	if t.Status != ticketSold {
+		return errorResponse("transferTicket", codeConflict, t.TicketID, "Ticket can not be transferred in "+t.Status+" status")
+	}
+
+	td, err := getTheatreDetails(stub, t.TheatreID)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", t.TheatreID)
+	}
+	if err != nil {
+		return failedResponse("transferTicket", t.TicketID, err)
+	}
+
+	previous := t.Customer
+	t.Customer = req.Customer
+	err = putTicketRecord(stub, *td, *t)
+	if err != nil {
+		_logger.Errorf("transferTicket:PutState is Failed :" + string(err.Error()))
+		return errorResponse("transferTicket", errorCode(err), t.TicketID, "Unable to transfer the ticket")
+	}
+	_logger.Infof("transferTicket:Ticket transferred successfully")
+
+	result := map[string]interface{}{
+		"trxnid":   stub.GetTxID(),
+		"ticketid": t.TicketID,
+		"from":     previous,
+		"to":       t.Customer,
+		"message":  "Transfer ticket successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
+
+// Redeem an individual ticket at the entrance of the screen
+func (s *ShowsManagement) redeemTicket(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	_, t, errResp := parseTicketRequest(stub, "redeemTicket", args)
+	if errResp != nil {
+		return *errResp
+	}
+
+	if t.Status != ticketSold {
+		return errorResponse("redeemTicket", codeConflict, t.TicketID, "Ticket can not be redeemed in "+t.Status+" status")
+	}
+
+	td, err := getTheatreDetails(stub, t.TheatreID)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", t.TheatreID)
+	}
+	if err != nil {
+		return failedResponse("redeemTicket", t.TicketID, err)
+	}
+
+	t.Status = ticketRedeemed
+	err = putTicketRecord(stub, *td, *t)
+	if err != nil {
+		_logger.Errorf("redeemTicket:PutState is Failed :" + string(err.Error()))
+		return errorResponse("redeemTicket", errorCode(err), t.TicketID, "Unable to redeem the ticket")
+	}
+	_logger.Infof("redeemTicket:Ticket redeemed successfully")
+
+	result := map[string]interface{}{
+		"trxnid":   stub.GetTxID(),
+		"ticketid": t.TicketID,
+		"message":  "Redeem ticket successfull",
+	}
+	respjson, _ := json.Marshal(result)
+	return shim.Success(respjson)
+}
@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestTicketRecords(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	ids := ticketIDs(s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":3,"price":10000,"customer":"CUST01"}`))
+	if len(ids) != 3 || ids[0] != "tx3-1" || ids[2] != "tx3-3" {
+		t.Fatalf("Tickets issued :%v, expected tx3-1 to tx3-3", ids)
+	}
+
+	runCases(t, s, []invokeCase{
+		{name: "ticket", fn: "gtkt", arg: `{"thid":"T1","ticketid":"` + ids[0] + `"}`, role: "none"},
+		{name: "unknown ticket", fn: "gtkt", arg: `{"thid":"T1","ticketid":"tx3-9"}`, code: codeNotFound},
+		{name: "ticket of another theatre", fn: "gtkt", arg: `{"thid":"T2","ticketid":"` + ids[0] + `"}`, code: codeNotFound},
+		{name: "transfer without a customer", fn: "xfer", arg: `{"thid":"T1","ticketid":"` + ids[0] + `"}`, code: codeInvalidInput},
+		{name: "transfer", fn: "xfer", arg: `{"thid":"T1","ticketid":"` + ids[0] + `","customer":"CUST02"}`, role: roleCashier},
+		{name: "redeem", fn: "redeem", arg: `{"thid":"T1","ticketid":"` + ids[0] + `"}`, role: roleCashier},
+		{name: "redeemed ticket redeemed again", fn: "redeem", arg: `{"thid":"T1","ticketid":"` + ids[0] + `"}`, code: codeConflict},
+		{name: "redeemed ticket transferred", fn: "xfer", arg: `{"thid":"T1","ticketid":"` + ids[0] + `","customer":"CUST03"}`, code: codeConflict},
+		{name: "redeemed ticket cancelled", fn: "ctkt", arg: `{"thid":"T1","ticketid":"` + ids[0] + `"}`, code: codeConflict},
+		{name: "cancel", fn: "ctkt", arg: `{"thid":"T1","ticketid":"` + ids[1] + `"}`},
+		{name: "cancelled ticket cancelled again", fn: "ctkt", arg: `{"thid":"T1","ticketid":"` + ids[1] + `"}`, code: codeConflict},
+		{name: "cancelled ticket redeemed", fn: "redeem", arg: `{"thid":"T1","ticketid":"` + ids[1] + `"}`, code: codeConflict},
+		{name: "cancel by the count", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":1}`},
+		{name: "only redeemed tickets left", fn: "cancel", arg: `{"thid":"T1","screen":"SC1","showcode":"1","count":1}`, code: codeConflict},
+	})
+
+	expected := []struct {
+		id       string
+		status   string
+		customer string
+	}{
+		{ids[0], ticketRedeemed, "CUST02"},
+		{ids[1], ticketCancelled, "CUST01"},
+		{ids[2], ticketCancelled, "CUST01"},
+	}
+	for _, e := range expected {
+		tr := Ticket{}
+		if !s.record(&tr, "Ticket", "T1", e.id) {
+			t.Errorf("Ticket %s is not saved", e.id)
+			continue
+		}
+		if tr.Status != e.status || tr.Customer != e.customer || tr.Price != 10000 {
+			t.Errorf("Ticket %s :%+v, expected %s for %s", e.id, tr, e.status, e.customer)
+		}
+	}
+	tkt := Tickets{}
+	s.record(&tkt, "Tickets", "T1", "SC1", date0, "1")
+	if tkt.TicketsSold != 1 {
+		t.Errorf("Tickets sold :%d, expected 1", tkt.TicketsSold)
+	}
+}
+
+func TestSeatTicketRecords(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	s.mustInvoke("asm", `{"thid":"T1","screen":"SC1","rows":[{"row":"A","seats":4,"category":"Gold"}]}`)
+	ids := ticketIDs(s.mustInvoke("sells", `{"thid":"T1","screen":"SC1","showcode":"2","seats":["A3","A1"],"price":10000}`))
+
+	for n, seat := range []string{"A3", "A1"} {
+		tr := Ticket{}
+		if !s.record(&tr, "Ticket", "T1", ids[n]) || tr.Seat != seat || tr.Category != "Gold" || tr.Status != ticketSold {
+			t.Errorf("Ticket %s of the seat %s :%+v", ids[n], seat, tr)
+		}
+	}
+
+	// Cancelling a seated ticket makes the seat available again
+	s.mustInvoke("ctkt", `{"thid":"T1","ticketid":"`+ids[0]+`"}`)
+	runCases(t, s, []invokeCase{
+		{name: "seat of the cancelled ticket", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"2","seats":["A3"],"price":10000}`},
+		{name: "seat of a sold ticket", fn: "sells", arg: `{"thid":"T1","screen":"SC1","showcode":"2","seats":["A1"],"price":10000}`, code: codeConflict},
+	})
+}
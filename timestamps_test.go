@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestLedgerTimestamps(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+
+	runCases(t, s, []invokeCase{
+		{name: "create time by the client", fn: "athd", arg: `{"thid":"T2","maxsoda":200,"sph":{"SC1":4},"cts":"2020-12-02T00:00:00Z"}`, code: codeInvalidInput},
+		{name: "update time by the client", fn: "uthd", arg: `{"thid":"T1","maxsoda":100,"uts":"1606867200"}`, code: codeInvalidInput},
+		{name: "update time in a nested object", fn: "asd", arg: `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"` + date1 + `","shows":[{"showcode":"1","start":1709366400,"uts":"1709366400"}]}`, code: codeInvalidInput},
+		{name: "writer by the client", fn: "sell", arg: `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000,"modby":"someone"}`, code: codeInvalidInput},
+		{name: "update an hour later", fn: "uthd", arg: `{"thid":"T1","maxsoda":100}`, at: day0 + 3600},
+	})
+
+	td := TheatreDetails{}
+	s.record(&td, "TheatreDetails", "T1")
+	if td.CreateTs != "2024-03-01T00:00:00Z" || td.UpdateTs != "2024-03-01T01:00:00Z" {
+		t.Errorf("Theatre created at %q and updated at %q, expected the transaction times", td.CreateTs, td.UpdateTs)
+	}
+	result := s.mustInvoke("gth", `{"thid":"T1"}`)
+	if result["cts"] != td.CreateTs || result["uts"] != td.UpdateTs {
+		t.Errorf("Theatre queried :%v, expected the timestamps in RFC3339", result)
+	}
+}
+
+func TestLedgerTimestampsOfLegacyRecords(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	legacy := []struct {
+		showCode string
+		cts      string
+		expected string
+	}{
+		{"1", "1709200000", "2024-02-29T09:46:40Z"},
+		{"2", "2024-02-29T00:00:00Z", "2024-02-29T00:00:00Z"},
+	}
+	for _, l := range legacy {
+		key, _ := s.CreateCompositeKey("Tickets", []string{"T1", "SC1", date0, l.showCode})
+		s.putLegacy(key, `{"obj":"Tickets","thid":"T1","screen":"SC1","showdate":"`+date0+`","showcode":"`+l.showCode+
+			`","ticketsold":1,"pcsold":1,"watersold":1,"cts":"`+l.cts+`","uts":"`+l.cts+`"}`)
+	}
+	s.now = day0 + 7200
+
+	// Create time provided by the client in the epoch seconds is converted on the first save
+	for _, l := range legacy {
+		s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"`+l.showCode+`","ticketsold":1,"price":10000}`)
+		tkt := Tickets{}
+		s.record(&tkt, "Tickets", "T1", "SC1", date0, l.showCode)
+		if tkt.CreateTs != l.expected || tkt.UpdateTs != "2024-03-01T02:00:00Z" || tkt.TicketsSold != 2 {
+			t.Errorf("Tickets of the show %s saved with the create time %s :%+v, expected %s", l.showCode, l.cts, tkt, l.expected)
+		}
+	}
+}
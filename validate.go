@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Violation is an input field failing the validation of the function
+type Violation struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+	Code   string `json:"code,omitempty"` // Code of the error, if the field is rejected by a ledger check
+}
+
+// inputRule is the validation of the input of a function
+type inputRule struct {
+	types    []interface{} // Input structs of the function. Fields not in the structs are rejected
+	required []string      // Fields that must be provided with a non-empty value
+	exclude  []string      // Fields of the structs set by the chaincode which can not be provided
+	showCode bool          // Showcode must be added for the screen on the show date
+}
+
+// Fields set by the chaincode on every record
+var recordFields = []string{"cts", "uts", "modby", "modmsp"}
+
+// Formats of the input fields. Fields are checked wherever they appear in the input, including the nested objects
+var (
+	idFormat     = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	txRefFormat  = regexp.MustCompile(`^[A-Za-z0-9-]{1,128}$`)
+	rowFormat    = regexp.MustCompile(`^[A-Za-z]{1,3}$`)
+	clientFormat = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+	fieldFormats = map[string]func(string) string{
+		"thid":        matchFormat(idFormat, "letters, digits, _ and - upto 64 characters"),
+		"screen":      matchFormat(idFormat, "letters, digits, _ and - upto 64 characters"),
+		"showcode":    matchFormat(idFormat, "letters, digits, _ and - upto 64 characters"),
+		"code":        matchFormat(idFormat, "letters, digits, _ and - upto 64 characters"),
+		"coupon":      matchFormat(idFormat, "letters, digits, _ and - upto 64 characters"),
+		"item":        matchFormat(idFormat, "letters, digits, _ and - upto 64 characters"),
+		"inventoryid": matchFormat(idFormat, "letters, digits, _ and - upto 64 characters"),
+		"customer":    matchFormat(clientFormat, "letters, digits, _, ., @ and - upto 64 characters"),
+		"seats":       matchFormat(idFormat, "letters, digits, _ and - upto 64 characters"),
+		"ticketid":    matchFormat(txRefFormat, "letters, digits and - upto 128 characters"),
+		"holdid":      matchFormat(txRefFormat, "letters, digits and - upto 128 characters"),
+		"drawid":      matchFormat(txRefFormat, "letters, digits and - upto 128 characters"),
+		"row":         matchFormat(rowFormat, "upto 3 letters"),
+		"showdate":    dateValue,
+		"bizdate":     dateValue,
+		"fromdate":    dateValue,
+		"todate":      dateValue,
+		"moviename":   nameValue,
+	}
+)
+
+// inputRules has the validation of the input of each function
+var inputRules = map[string]inputRule{
+	"athd":   {types: []interface{}{TheatreDetails{}}, required: []string{"thid", "sph"}, exclude: []string{"obj", "bizdate", "ownermsp", "inactive", "inactivescreens"}},
+	"uthd":   {types: []interface{}{TheatreUpdate{}}, required: []string{"thid"}},
+	"dact":   {types: []interface{}{TheatreStatus{}}, required: []string{"thid"}},
+	"ract":   {types: []interface{}{TheatreStatus{}}, required: []string{"thid"}},
+	"asd":    {types: []interface{}{ShowDetails{}}, required: []string{"thid", "screen", "moviename"}, exclude: []string{"obj"}},
+	"asm":    {types: []interface{}{SeatMap{}}, required: []string{"thid", "screen", "rows"}, exclude: []string{"obj"}},
+	"asp":    {types: []interface{}{SodaPromotion{}}, required: []string{"thid"}, exclude: []string{"obj", "changedby", "changedat", "seedhash"}},
+	"cep":    {types: []interface{}{EndorsementChange{}}, required: []string{"thid"}},
+	"mig":    {types: []interface{}{MigrationRequest{}}, required: []string{"thid"}},
+	"rst":    {types: []interface{}{ResetRequest{}}, required: []string{"thid"}},
+	"rcnt":   {types: []interface{}{RecountRequest{}}, required: []string{"thid"}},
+	"sell":   {types: []interface{}{Tickets{}, TicketSale{}}, required: []string{"thid", "screen", "showcode", "ticketsold"}, exclude: []string{"obj", "pcsold", "watersold", "sodasold"}, showCode: true},
+	"sells":  {types: []interface{}{SeatSale{}}, required: []string{"thid", "screen", "showcode", "seats"}, showCode: true},
+	"hold":   {types: []interface{}{HoldRequest{}}, required: []string{"thid", "screen", "showcode"}, exclude: []string{"holdid"}, showCode: true},
+	"chold":  {types: []interface{}{HoldRequest{}}, required: []string{"thid", "screen", "showcode", "holdid"}},
+	"rhold":  {types: []interface{}{HoldRequest{}}, required: []string{"thid", "screen", "showcode", "holdid"}},
+	"sweep":  {types: []interface{}{HoldRequest{}}, required: []string{"thid", "screen", "showcode"}},
+	"cancel": {types: []interface{}{Cancellation{}}, required: []string{"thid", "screen", "showcode"}},
+	"gtkt":   {types: []interface{}{TicketRequest{}}, required: []string{"thid", "ticketid"}},
+	"ctkt":   {types: []interface{}{TicketRequest{}}, required: []string{"thid", "ticketid"}},
+	"xfer":   {types: []interface{}{TicketRequest{}}, required: []string{"thid", "ticketid", "customer"}},
+	"redeem": {types: []interface{}{TicketRequest{}}, required: []string{"thid", "ticketid"}},
+	"exs":    {types: []interface{}{SodaInventory{}, SodaExchange{}}, required: []string{"thid", "inventoryid", "ticketid"}, exclude: []string{"obj", "soda"}},
+	"vdraw":  {types: []interface{}{DrawRequest{}}, required: []string{"thid", "drawid"}},
+	"gss":    {types: []interface{}{RecordQuery{}}, required: []string{"selector"}},
+	"gssp":   {types: []interface{}{RecordQuery{}}, required: []string{"selector"}},
+	"gth":    {types: []interface{}{TheatreQuery{}}, required: []string{"thid"}},
+	"gscr":   {types: []interface{}{TheatreQuery{}}, required: []string{"thid"}},
+	"gshw":   {types: []interface{}{TheatreQuery{}}, required: []string{"thid", "screen"}},
+	"gsr":    {types: []interface{}{TheatreQuery{}}, required: []string{"thid", "screen", "showcode"}},
+	"gsst":   {types: []interface{}{TheatreQuery{}}, required: []string{"thid"}},
+	"srch":   {types: []interface{}{ShowSearch{}}, required: []string{"moviename"}},
+	"hist":   {types: []interface{}{recordRef{}}, required: []string{"obj", "thid"}},
+	"apl":    {types: []interface{}{PriceList{}}, required: []string{"thid", "screen", "prices"}, exclude: []string{"obj"}},
+	"acpn":   {types: []interface{}{Coupon{}}, required: []string{"thid", "code", "discount"}, exclude: []string{"obj", "used"}},
+	"cart":   {types: []interface{}{Cart{}}, required: []string{"thid"}},
+	"atax":   {types: []interface{}{TaxConfig{}}, required: []string{"thid"}, exclude: []string{"obj"}},
+	"ginv":   {types: []interface{}{InvoiceQuery{}}, required: []string{"thid", "fromdate", "todate"}},
+	"quote":  {types: []interface{}{PriceQuote{}}, required: []string{"thid", "screen", "showcode"}, showCode: true},
+}
+
+// matchFormat checks the value against the pattern
+func matchFormat(pattern *regexp.Regexp, expected string) func(string) string {
+	return func(value string) string {
+		if !pattern.MatchString(value) {
+			return "Expected " + expected
+		}
+		return ""
+	}
+}
+
+// dateValue checks the value is a date in YYYY-MM-DD format
+func dateValue(value string) string {
+	if _, err := time.Parse(dateFormat, value); err != nil {
+		return "Expected a date in YYYY-MM-DD format"
+	}
+	return ""
+}
+
+// nameValue checks the value is a printable name of upto 100 characters
+func nameValue(value string) string {
+	if strings.TrimSpace(value) == "" || len(value) > 100 {
+		return "Expected a name of 1 to 100 characters"
+	}
+	for _, r := range value {
+		if !unicode.IsPrint(r) {
+			return "Expected a name without control characters"
+		}
+	}
+	return ""
+}
+
+// jsonFields returns the json fields of the struct along with the fields of the embedded structs
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// fieldTypes returns the types of the json fields of the struct along with the fields of the embedded structs
+func fieldTypes(t reflect.Type) map[string]reflect.Type {
+	types := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for name, typ := range fieldTypes(field.Type) {
+				types[name] = typ
+			}
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			types[name] = field.Type
+		}
+	}
+	return types
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// checkValue decodes the json value into the type. Objects and lists are decoded field by field and item by item so
+// that the unknown fields and the invalid values of all the nested objects are found, instead of the first one
+func checkValue(path string, typ reflect.Type, value json.RawMessage, violations []Violation) []Violation {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	mismatch := Violation{Field: path, Reason: "Expected a value of type " + typ.String()}
+	if reflect.PtrTo(typ).Implements(unmarshalerType) {
+		if json.Unmarshal(value, reflect.New(typ).Interface()) != nil {
+			violations = append(violations, mismatch)
+		}
+		return violations
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		fields := map[string]json.RawMessage{}
+		if json.Unmarshal(value, &fields) != nil {
+			return append(violations, mismatch)
+		}
+		types := fieldTypes(typ)
+		for field, fieldValue := range fields {
+			fieldType, found := types[field]
+			if !found {
+				violations = append(violations, Violation{Field: path + "." + field, Reason: "Field is not allowed"})
+				continue
+			}
+			violations = checkValue(path+"."+field, fieldType, fieldValue, violations)
+		}
+		return violations
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		var items []json.RawMessage
+		if json.Unmarshal(value, &items) != nil {
+			return append(violations, mismatch)
+		}
+		for _, item := range items {
+			violations = checkValue(path+"[]", typ.Elem(), item, violations)
+		}
+		return violations
+	case reflect.Map:
+		entries := map[string]json.RawMessage{}
+		if typ.Key().Kind() != reflect.String || json.Unmarshal(value, &entries) != nil {
+			break
+		}
+		for key, entry := range entries {
+			violations = checkValue(path+"."+key, typ.Elem(), entry, violations)
+		}
+		return violations
+	case reflect.Interface:
+		return violations
+	}
+	if json.Unmarshal(value, reflect.New(typ).Interface()) != nil {
+		violations = append(violations, mismatch)
+	}
+	return violations
+}
+
+// isEmpty checks if the json value is null, an empty string, an empty list or an empty object
+func isEmpty(value json.RawMessage) bool {
+	switch strings.TrimSpace(string(value)) {
+	case "", "null", `""`, "[]", "{}":
+		return true
+	}
+	return false
+}
+
+// checkFormats checks the formats of the fields of the value and of its nested objects
+func checkFormats(path string, value interface{}, violations []Violation) []Violation {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, fieldValue := range v {
+			fieldPath := field
+			if path != "" {
+				fieldPath = path + "." + field
+			}
+			if format, found := fieldFormats[field]; found {
+				violations = checkFormat(fieldPath, fieldValue, format, violations)
+			}
+			violations = checkFormats(fieldPath, fieldValue, violations)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if _, isObject := item.(map[string]interface{}); isObject {
+				violations = checkFormats(path+"[]", item, violations)
+			}
+		}
+	}
+	return violations
+}
+
+// checkFormat checks the format of a string field or of the strings of a list field
+func checkFormat(path string, value interface{}, format func(string) string, violations []Violation) []Violation {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return violations
+		}
+		if reason := format(v); reason != "" {
+			violations = append(violations, Violation{Field: path, Reason: reason})
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s, isString := item.(string); isString {
+				violations = checkFormat(path+"[]", s, format, violations)
+			}
+		}
+	}
+	return violations
+}
+
+// validateInput validates the input of the function against its rules without reading the ledger. All the
+// violations are returned together
+func validateInput(fn string, args []string) error {
+	rule, found := inputRules[fn]
+	if !found {
+		return nil
+	}
+	if len(args) != 1 {
+		return &codedError{code: codeInvalidInput, message: "Invalid Number of argumnets provided for transaction"}
+	}
+
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal([]byte(args[0]), &fields)
+	if err != nil {
+		return &codedError{code: codeInvalidInput, message: "Invalid json provided as input"}
+	}
+
+	var violations []Violation
+	allowed := map[string]bool{}
+	for _, t := range rule.types {
+		for _, field := range jsonFields(reflect.TypeOf(t)) {
+			allowed[field] = true
+		}
+	}
+	for _, field := range append(rule.exclude, recordFields...) {
+		allowed[field] = false
+	}
+	for field := range fields {
+		if !allowed[field] {
+			violations = append(violations, Violation{Field: field, Reason: "Field is not allowed"})
+		}
+	}
+	for _, field := range rule.required {
+		if isEmpty(fields[field]) {
+			violations = append(violations, Violation{Field: field, Reason: "Field is required"})
+		}
+	}
+
+	// Fields of each input struct are decoded to find the unknown fields of the nested objects and the invalid values.
+	// A field shared by the input structs is reported once
+	checked := map[string]bool{}
+	for _, t := range rule.types {
+		for field, typ := range fieldTypes(reflect.TypeOf(t)) {
+			value, found := fields[field]
+			if !found || !allowed[field] || checked[field] {
+				continue
+			}
+			checked[field] = true
+			violations = checkValue(field, typ, value, violations)
+		}
+	}
+
+	var value interface{}
+	if json.Unmarshal([]byte(args[0]), &value) == nil {
+		violations = checkFormats("", value, violations)
+	}
+
+	return violationsError(violations)
+}
+
+// violationsError returns the error with the violations sorted by the field, so that all the peers return the same
+// response. Returns nil if there are no violations
+func violationsError(violations []Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Field != violations[j].Field {
+			return violations[i].Field < violations[j].Field
+		}
+		return violations[i].Reason < violations[j].Reason
+	})
+	return &codedError{code: codeInvalidInput, message: "Invalid input provided for the transaction", violations: violations}
+}
+
+// validateShowCode checks that the showcode of the input is added for the screen on the show date. Validated after
+// the input as the show details are read from the ledger
+func validateShowCode(stub shim.ChaincodeStubInterface, fn string, args []string) error {
+	if !inputRules[fn].showCode {
+		return nil
+	}
+	var ref struct {
+		TheatreID string `json:"thid"`
+		Screen    string `json:"screen"`
+		ShowDate  string `json:"showdate"`
+		ShowCode  string `json:"showcode"`
+	}
+	if json.Unmarshal([]byte(args[0]), &ref) != nil {
+		return nil
+	}
+	dt, err := resolveShowDate(stub, ref.TheatreID, ref.ShowDate)
+	if err != nil {
+		return err
+	}
+	sd, err := getShowDetailsOfScreen(stub, ref.TheatreID, ref.Screen, dt)
+	if err != nil {
+		return err
+	}
+	if sd == nil {
+		return violationsError([]Violation{{Field: "screen", Reason: "No shows are added for the screen on " + dt}})
+	}
+	if _, found := sd.show(ref.ShowCode); !found {
+		return violationsError([]Violation{{Field: "showcode", Reason: "Showcode is not added for the screen on " + dt}})
+	}
+	return nil
+}
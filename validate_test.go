@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// violationFields returns the fields of the violations of the error
+func violationFields(err error) string {
+	ce, ok := err.(*codedError)
+	if !ok {
+		return ""
+	}
+	fields := []string{}
+	for _, v := range ce.violations {
+		fields = append(fields, v.Field)
+	}
+	return strings.Join(fields, " ")
+}
+
+func TestValidateInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		fn     string
+		arg    string
+		fields string
+	}{
+		{"valid sale", "sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"price":10000,"customer":"cust.01@mail"}`, ""},
+		{"function without rules", "unknown", `{"any":1}`, ""},
+		{"all required fields missing", "sell", `{}`, "screen showcode thid ticketsold"},
+		{"empty values", "sells", `{"thid":"","screen":"SC1","showcode":"1","seats":[]}`, "seats thid"},
+		{"unknown field", "sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"discount":10}`, "discount"},
+		{"field set by the chaincode", "sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":2,"pcsold":2}`, "pcsold"},
+		{"blank movie name", "asd", `{"thid":"T1","screen":"SC1","moviename":"  ","showcode":["1"]}`, "moviename"},
+		{"inventory with a space", "exs", `{"thid":"T1","inventoryid":"ES 13","ticketid":"tx1-1"}`, "inventoryid"},
+		{"theatre ID over 64 characters", "gth", `{"thid":"` + strings.Repeat("T", 65) + `"}`, "thid"},
+		{"invalid date", "gsr", `{"thid":"T1","screen":"SC1","showcode":"1","showdate":"2024-02-30"}`, "showdate"},
+		{"count of a wrong type", "sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":"two"}`, "ticketsold"},
+		{"negative count", "sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":-1}`, "ticketsold"},
+		{"unknown field of a nested object", "asd", `{"thid":"T1","screen":"SC1","moviename":"Lucy","shows":[{"showcode":"1","start":1709287200,"hall":"A"}]}`, "shows[].hall"},
+		{"invalid item of a list", "sells", `{"thid":"T1","screen":"SC1","showcode":"1","seats":["A1","A 2"]}`, "seats[]"},
+		{"all the violations together", "sell", `{"thid":"T 1","screen":"","showcode":"1","ticketsold":2,"cts":"x"}`, "cts screen thid"},
+	}
+	for _, tt := range tests {
+		err := validateInput(tt.fn, []string{tt.arg})
+		if tt.fields == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if errorCode(err) != codeInvalidInput || violationFields(err) != tt.fields {
+			t.Errorf("%s: violations of %v, expected %q", tt.name, err, tt.fields)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		args []string
+	}{
+		{"invalid json", []string{`{"thid":`}},
+		{"not an object", []string{`["T1"]`}},
+		{"no arguments", []string{}},
+		{"two arguments", []string{`{"thid":"T1"}`, `{"thid":"T2"}`}},
+	}
+	for _, in := range invalid {
+		if err := validateInput("gth", in.args); errorCode(err) != codeInvalidInput {
+			t.Errorf("%s: validated with %v, expected %s", in.name, err, codeInvalidInput)
+		}
+	}
+}
+
+func TestValidateShowCode(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+
+	tests := []struct {
+		name  string
+		fn    string
+		arg   string
+		field string
+	}{
+		{"showcode not added", "sell", `{"thid":"T1","screen":"SC1","showcode":"3","ticketsold":1,"price":10000}`, "showcode"},
+		{"screen without shows", "hold", `{"thid":"T1","screen":"SC2","showcode":"1","count":1}`, "screen"},
+		{"day without shows", "sells", `{"thid":"T1","screen":"SC1","showdate":"` + date1 + `","showcode":"1","seats":["A1"]}`, "screen"},
+		{"invalid input of an unknown theatre", "sell", `{"thid":"T9","screen":"SC1","showcode":"1","ticketsold":"one"}`, "ticketsold"},
+	}
+	for _, tt := range tests {
+		r := s.invoke(tt.fn, tt.arg)
+		er := ErrorResponse{}
+		json.Unmarshal([]byte(r.Message), &er)
+		if er.Code != codeInvalidInput || len(er.Violations) != 1 || er.Violations[0].Field != tt.field {
+			t.Errorf("%s: response %s, expected the violation of %s", tt.name, r.Message, tt.field)
+		}
+	}
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"2","ticketsold":1,"price":10000}`)
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// TheatreQuery is the input of the typed queries of a theatre
+type TheatreQuery struct {
+	TheatreID   string `json:"thid"`        // Alphanumeric
+	Screen      string `json:"screen"`      // Alphanumeric. Required for the shows of a screen
+	ShowDate    string `json:"showdate"`    // YYYY-MM-DD. Defaults to the current date of the theatre for the seats remaining
+	ShowCode    string `json:"showcode"`    // Required for the seats remaining
+	InventoryID string `json:"inventoryid"` // All the inventories of the theatre if not provided
+}
+
+// ScreenView is a screen of a theatre with its capacity and the dates shows are added for
+type ScreenView struct {
+	TheatreID string   `json:"thid"`
+	Screen    string   `json:"screen"`
+	Capacity  int      `json:"capacity"`  // Max seats of the screen
+	ShowDates []string `json:"showdates"` // Dates shows are added for, in the ascending order
+}
+
+// ShowAvailability is the seats remaining for a show
+type ShowAvailability struct {
+	TheatreID string `json:"thid"`
+	Screen    string `json:"screen"`
+	ShowDate  string `json:"showdate"`
+	ShowCode  string `json:"showcode"`
+	MovieName string `json:"moviename"`
+	StartTime int64  `json:"start"`     // epoch format. 0 if the start time is not added
+	Format    string `json:"format"`    // 2D, 3D or IMAX
+	Capacity  int    `json:"capacity"`  // Max seats of the screen
+	Sold      int    `json:"sold"`      // Tickets sold for the show
+	Held      int    `json:"held"`      // Tickets on live holds
+	Remaining int    `json:"remaining"` // Tickets available for sale
+}
+
+// SodaStock is the soda remaining in an inventory for the current business day
+type SodaStock struct {
+	TheatreID    string `json:"thid"`
+	InventoryID  string `json:"inventoryid"`
+	BusinessDate string `json:"bizdate"`   // Current business day of the theatre
+	MaxSoda      int    `json:"maxsoda"`   // Max soda per day
+	Sold         int    `json:"sold"`      // Soda exchanged on the business day
+	Remaining    int    `json:"remaining"` // Soda available for exchange
+}
+
+// parseTheatreQuery validates the input of the typed queries and fetches the theatre details
+func parseTheatreQuery(stub shim.ChaincodeStubInterface, fn string, args []string) (TheatreQuery, *TheatreDetails, *pb.Response) {
+	var query TheatreQuery
+
+	if len(args) != 1 {
+		resp := errorResponse(fn, codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+		return query, nil, &resp
+	}
+
+	err := json.Unmarshal([]byte(args[0]), &query)
+	if err != nil || query.TheatreID == "" {
+		resp := errorResponse(fn, codeInvalidInput, args[0], "Invalid json provided as input")
+		return query, nil, &resp
+	}
+
+	td, err := getTheatreDetails(stub, query.TheatreID)
+	if err == nil && td == nil {
+		err = newError(codeNotFound, "Theatre details does not exists for :%s", query.TheatreID)
+	}
+	if err != nil {
+		resp := failedResponse(fn, query.TheatreID, err)
+		return query, nil, &resp
+	}
+	return query, td, nil
+}
+
+// showAvailability computes the seats remaining for a show at the given time
+func showAvailability(stub shim.ChaincodeStubInterface, td TheatreDetails, sd ShowDetails, show Show, now int64) (ShowAvailability, error) {
+	av := ShowAvailability{
+		TheatreID: td.TheatreID,
+		Screen:    sd.Screen,
+		ShowDate:  sd.ShowDate,
+		ShowCode:  show.ShowCode,
+		MovieName: show.MovieName,
+		StartTime: show.StartTime,
+		Format:    show.Format,
+		Capacity:  int(td.SeatsPerHall[sd.Screen]),
+	}
+	tkt, err := getTickets(stub, td.TheatreID, sd.Screen, sd.ShowDate, show.ShowCode)
+	if err != nil {
+		return av, err
+	}
+	if tkt != nil {
+		av.Sold = int(tkt.TicketsSold)
+	}
+	holds, err := getShowHolds(stub, td.TheatreID, sd.Screen, sd.ShowDate, show.ShowCode)
+	if err != nil {
+		return av, err
+	}
+	av.Held = holds.liveCount(now)
+	av.Remaining = av.Capacity - av.Sold - av.Held
+	if av.Remaining < 0 {
+		av.Remaining = 0
+	}
+	return av, nil
+}
+
+// sodaStock computes the soda remaining in an inventory
+func sodaStock(td TheatreDetails, invid string, sold int) SodaStock {
+	stock := SodaStock{
+		TheatreID:    td.TheatreID,
+		InventoryID:  invid,
+		BusinessDate: td.BusinessDate,
+		MaxSoda:      int(td.MaxSodaPerDay),
+		Sold:         sold,
+	}
+	stock.Remaining = stock.MaxSoda - stock.Sold
+	if stock.Remaining < 0 {
+		stock.Remaining = 0
+	}
+	return stock
+}
+
+// Get the theatre details
+func (s *ShowsManagement) getTheatre(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	_, td, errResp := parseTheatreQuery(stub, "getTheatre", args)
+	if errResp != nil {
+		return *errResp
+	}
+
+	respjson, _ := json.Marshal(td)
+	return shim.Success(respjson)
+}
+
+// Get the screens of a theatre with the capacity and the dates shows are added for
+func (s *ShowsManagement) getScreens(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	query, td, errResp := parseTheatreQuery(stub, "getScreens", args)
+	if errResp != nil {
+		return *errResp
+	}
+
+	screens := []ScreenView{}
+	for sc, capacity := range td.SeatsPerHall {
+		screens = append(screens, ScreenView{TheatreID: td.TheatreID, Screen: sc, Capacity: int(capacity), ShowDates: []string{}})
+	}
+	sort.Slice(screens, func(i, j int) bool { return screens[i].Screen < screens[j].Screen })
+
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("ShowDetails", []string{query.TheatreID})
+	if err != nil {
+		_logger.Errorf("getScreens:GetStateByPartialCompositeKey is Failed :" + string(err.Error()))
+		return errorResponse("getScreens", errorCode(err), query.TheatreID, "Unable to get the screens")
+	}
+	defer resultsIterator.Close()
+
+	// Keys are returned in the order of the screen and the date
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			_logger.Errorf("getScreens:Query iteration is Failed :" + string(err.Error()))
+			return errorResponse("getScreens", errorCode(err), query.TheatreID, "Unable to get the screens")
+		}
+		_, ids, err := stub.SplitCompositeKey(record.Key)
+		if err != nil || len(ids) != 3 {
+			continue
+		}
+		for i := range screens {
+			if screens[i].Screen == ids[1] {
+				screens[i].ShowDates = append(screens[i].ShowDates, ids[2])
+			}
+		}
+	}
+
+	respjson, _ := json.Marshal(screens)
+	return shim.Success(respjson)
+}
+
+// Get the show details of a screen. Shows of all the dates are returned if the show date is not provided
+func (s *ShowsManagement) getScreenShows(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	query, _, errResp := parseTheatreQuery(stub, "getScreenShows", args)
+	if errResp != nil {
+		return *errResp
+	}
+	if query.Screen == "" {
+		return errorResponse("getScreenShows", codeInvalidInput, query.TheatreID, "Screen is required to get the shows")
+	}
+
+	ids := []string{query.TheatreID, query.Screen}
+	if query.ShowDate != "" {
+		ids = append(ids, query.ShowDate)
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("ShowDetails", ids)
+	if err != nil {
+		_logger.Errorf("getScreenShows:GetStateByPartialCompositeKey is Failed :" + string(err.Error()))
+		return errorResponse("getScreenShows", errorCode(err), query.TheatreID, "Unable to get the shows")
+	}
+	defer resultsIterator.Close()
+
+	shows := []ShowDetails{}
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			_logger.Errorf("getScreenShows:Query iteration is Failed :" + string(err.Error()))
+			return errorResponse("getScreenShows", errorCode(err), query.TheatreID, "Unable to get the shows")
+		}
+		sd := ShowDetails{}
+		err = json.Unmarshal(record.Value, &sd)
+		if err != nil {
+			return errorResponse("getScreenShows", codeLedgerError, query.TheatreID, "Existing show details Unmarshalling error")
+		}
+		shows = append(shows, sd)
+	}
+
+	respjson, _ := json.Marshal(shows)
+	return shim.Success(respjson)
+}
+
+// Get the seats remaining for a show
+func (s *ShowsManagement) getSeatsRemaining(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	query, td, errResp := parseTheatreQuery(stub, "getSeatsRemaining", args)
+	if errResp != nil {
+		return *errResp
+	}
+
+	dt, err := showDateOrToday(stub, *td, query.ShowDate)
+	var sd *ShowDetails
+	if err == nil {
+		sd, err = getShowDetailsOfScreen(stub, query.TheatreID, query.Screen, dt)
+	}
+	if err == nil && sd == nil {
+		err = newError(codeNotFound, "Show details does not exists for the given details")
+	}
+	var av ShowAvailability
+	if err == nil {
+		show, found := sd.show(query.ShowCode)
+		if !found {
+			err = newError(codeNotFound, "Showcode %s does not exists for the screen", query.ShowCode)
+		} else {
+			var now int64
+			now, err = txTime(stub)
+			if err == nil {
+				av, err = showAvailability(stub, *td, *sd, show, now)
+			}
+		}
+	}
+	if err != nil {
+		return failedResponse("getSeatsRemaining", query.TheatreID, err)
+	}
+
+	respjson, _ := json.Marshal(av)
+	return shim.Success(respjson)
+}
+
+// Get the soda remaining today in an inventory, or in all the inventories of the theatre
+func (s *ShowsManagement) getSodaStock(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	query, td, errResp := parseTheatreQuery(stub, "getSodaStock", args)
+	if errResp != nil {
+		return *errResp
+	}
+
+	ids := []string{query.TheatreID}
+	if query.InventoryID != "" {
+		ids = append(ids, query.InventoryID)
+	}
+	resultsIterator, err := stub.GetStateByPartialCompositeKey("SodaInventory", ids)
+	if err != nil {
+		_logger.Errorf("getSodaStock:GetStateByPartialCompositeKey is Failed :" + string(err.Error()))
+		return errorResponse("getSodaStock", errorCode(err), query.TheatreID, "Unable to get the soda stock")
+	}
+	defer resultsIterator.Close()
+
+	stocks := []SodaStock{}
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			_logger.Errorf("getSodaStock:Query iteration is Failed :" + string(err.Error()))
+			return errorResponse("getSodaStock", errorCode(err), query.TheatreID, "Unable to get the soda stock")
+		}
+		soda := SodaInventory{}
+		err = json.Unmarshal(record.Value, &soda)
+		if err != nil {
+			return errorResponse("getSodaStock", codeLedgerError, query.TheatreID, "Existing inventory details Unmarshalling error")
+		}
+		stocks = append(stocks, sodaStock(*td, soda.InventoryID, int(soda.SodaSold)))
+	}
+
+	// Inventory is added on the first soda exchange
+	if query.InventoryID != "" && len(stocks) == 0 {
+		stocks = append(stocks, sodaStock(*td, query.InventoryID, 0))
+	}
+
+	respjson, _ := json.Marshal(stocks)
+	return shim.Success(respjson)
+}
+
+// ShowSearch is the input to search the shows of a movie across the theatres
+type ShowSearch struct {
+	MovieName string `json:"moviename"` // Required
+	ShowDate  string `json:"showdate"`  // YYYY-MM-DD. All the dates if not provided
+	From      int64  `json:"from"`      // epoch format. Shows starting at or after the time, if provided
+	To        int64  `json:"to"`        // epoch format. Shows starting before the time, if provided
+}
+
+// Search the shows of a movie across all the theatres. Shows are returned with the seats remaining, sorted by start time
+func (s *ShowsManagement) searchShows(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+
+	if len(args) != 1 {
+		return errorResponse("searchShows", codeInvalidInput, strconv.Itoa(len(args)), "Invalid Number of argumnets provided for transaction")
+	}
+
+	var search ShowSearch
+	err := json.Unmarshal([]byte(args[0]), &search)
+	if err != nil || search.MovieName == "" {
+		return errorResponse("searchShows", codeInvalidInput, args[0], "Invalid json provided as input. Movie name is required")
+	}
+
+	// Movie name of every show is set when the shows are added
+	selector := map[string]interface{}{
+		"obj":   "ShowDetails",
+		"shows": map[string]interface{}{"$elemMatch": map[string]string{"moviename": search.MovieName}},
+	}
+	if search.ShowDate != "" {
+		selector["showdate"] = search.ShowDate
+	}
+	query, _ := json.Marshal(map[string]interface{}{"selector": selector})
+
+	resultsIterator, err := stub.GetQueryResult(string(query))
+	if err != nil {
+		_logger.Errorf("searchShows:GetQueryResult is Failed :" + string(err.Error()))
+		return errorResponse("searchShows", errorCode(err), search.MovieName, "Unable to search the shows")
+	}
+	defer resultsIterator.Close()
+
+	now, err := txTime(stub)
+	if err != nil {
+		_logger.Errorf("searchShows:GetTxTimestamp is Failed :" + string(err.Error()))
+		return errorResponse("searchShows", errorCode(err), search.MovieName, "Unable to search the shows")
+	}
+
+	theatres := make(map[string]*TheatreDetails)
+	shows := []ShowAvailability{}
+	for resultsIterator.HasNext() {
+		record, err := resultsIterator.Next()
+		if err != nil {
+			_logger.Errorf("searchShows:Query iteration is Failed :" + string(err.Error()))
+			return errorResponse("searchShows", errorCode(err), search.MovieName, "Unable to search the shows")
+		}
+		sd := ShowDetails{}
+		err = json.Unmarshal(record.Value, &sd)
+		if err != nil {
+			return errorResponse("searchShows", codeLedgerError, search.MovieName, "Existing show details Unmarshalling error")
+		}
+
+		td, found := theatres[sd.TheatreID]
+		if !found {
+			td, err = getTheatreDetails(stub, sd.TheatreID)
+			if err != nil {
+				return failedResponse("searchShows", sd.TheatreID, err)
+			}
+			theatres[sd.TheatreID] = td
+		}
+		if td == nil {
+			continue
+		}
+
+		shows, err = appendShowAvailability(stub, shows, *td, sd, search, now)
+		if err != nil {
+			return failedResponse("searchShows", sd.TheatreID, err)
+		}
+	}
+
+	sort.SliceStable(shows, func(i, j int) bool {
+		if shows[i].StartTime != shows[j].StartTime {
+			return shows[i].StartTime < shows[j].StartTime
+		}
+		if shows[i].TheatreID != shows[j].TheatreID {
+			return shows[i].TheatreID < shows[j].TheatreID
+		}
+		return shows[i].Screen < shows[j].Screen
+	})
+
+	respjson, _ := json.Marshal(shows)
+	return shim.Success(respjson)
+}
+
+// appendShowAvailability adds the shows of the movie within the time window of the search
+func appendShowAvailability(stub shim.ChaincodeStubInterface, shows []ShowAvailability, td TheatreDetails, sd ShowDetails, search ShowSearch, now int64) ([]ShowAvailability, error) {
+	// Shows of the deactivated theatres and screens are not available for sale
+	if td.salesOpen(sd.Screen) != nil {
+		return shows, nil
+	}
+	for _, show := range sd.Shows {
+		if show.MovieName != search.MovieName {
+			continue
+		}
+		if (search.From > 0 && show.StartTime < search.From) || (search.To > 0 && show.StartTime >= search.To) {
+			continue
+		}
+		av, err := showAvailability(stub, td, sd, show, now)
+		if err != nil {
+			return shows, err
+		}
+		shows = append(shows, av)
+	}
+	return shows, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTheatreViews(t *testing.T) {
+	s := newTestStub(t)
+	s.mustInvoke("athd", `{"thid":"T1","maxsoda":200,"sph":{"SC1":4,"SC2":6}}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"`+date1+`","showcode":["1","2"]}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"`+date0+`","shows":[{"showcode":"1","start":1709287200,"format":"3D"}]}`)
+	s.mustInvoke("sell", `{"thid":"T1","screen":"SC1","showcode":"1","ticketsold":1,"price":10000}`)
+	s.mustInvoke("hold", `{"thid":"T1","screen":"SC1","showcode":"1","count":1}`)
+
+	runCases(t, s, []invokeCase{
+		{name: "unknown theatre", fn: "gth", arg: `{"thid":"T9"}`, code: codeNotFound, role: "guest"},
+		{name: "screens of an unknown theatre", fn: "gscr", arg: `{"thid":"T9"}`, code: codeNotFound},
+		{name: "shows without a screen", fn: "gshw", arg: `{"thid":"T1"}`, code: codeInvalidInput},
+		{name: "seats of an unknown showcode", fn: "gsr", arg: `{"thid":"T1","screen":"SC1","showcode":"9"}`, code: codeNotFound},
+		{name: "seats of a day without shows", fn: "gsr", arg: `{"thid":"T1","screen":"SC2","showcode":"1"}`, code: codeNotFound},
+		{name: "soda of an unknown theatre", fn: "gsst", arg: `{"thid":"T9"}`, code: codeNotFound},
+	})
+
+	td := TheatreDetails{}
+	s.result("gth", `{"thid":"T1"}`, &td)
+	if td.SeatsPerHall["SC2"] != 6 || td.OwnerMSP != testMSP {
+		t.Errorf("Theatre :%+v", td)
+	}
+
+	screens := []ScreenView{}
+	s.result("gscr", `{"thid":"T1"}`, &screens)
+	if len(screens) != 2 || screens[0].Screen != "SC1" || strings.Join(screens[0].ShowDates, ",") != date0+","+date1 || screens[1].Capacity != 6 || len(screens[1].ShowDates) != 0 {
+		t.Errorf("Screens :%+v", screens)
+	}
+
+	tests := []struct {
+		arg   string
+		shows int
+	}{
+		{`{"thid":"T1","screen":"SC1"}`, 2},
+		{`{"thid":"T1","screen":"SC1","showdate":"` + date1 + `"}`, 1},
+		{`{"thid":"T1","screen":"SC2"}`, 0},
+	}
+	for _, tt := range tests {
+		shows := []ShowDetails{}
+		s.result("gshw", tt.arg, &shows)
+		if len(shows) != tt.shows {
+			t.Errorf("Shows of %s :%d, expected %d", tt.arg, len(shows), tt.shows)
+		}
+	}
+
+	seats := ShowAvailability{}
+	s.result("gsr", `{"thid":"T1","screen":"SC1","showcode":"1"}`, &seats)
+	if seats.Capacity != 4 || seats.Sold != 1 || seats.Held != 1 || seats.Remaining != 2 || seats.Format != format3D {
+		t.Errorf("Seats remaining :%+v", seats)
+	}
+
+	stocks := []SodaStock{}
+	s.result("gsst", `{"thid":"T1","inventoryid":"ES13"}`, &stocks)
+	if len(stocks) != 1 || stocks[0].Remaining != 200 || stocks[0].BusinessDate != date0 {
+		t.Errorf("Soda stock :%+v", stocks)
+	}
+}
+
+func TestSearchShows(t *testing.T) {
+	s := newTestStub(t)
+	s.addTheatre("T1", "")
+	s.mustInvoke("athd", `{"thid":"T2","maxsoda":200,"sph":{"SC1":4,"SC2":4}}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T2","showdate":"`+date0+`","shows":[{"showcode":"1","start":1709290800},{"showcode":"2","start":1709298000,"moviename":"Tenet"}]}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC2","thid":"T2","showdate":"`+date0+`","shows":[{"showcode":"1","start":1709287200}]}`)
+	s.mustInvoke("asd", `{"moviename":"Lucy","screen":"SC1","thid":"T1","showdate":"`+date1+`","shows":[{"showcode":"1","start":1709377200}]}`)
+	s.mustInvoke("sell", `{"thid":"T2","screen":"SC1","showcode":"1","ticketsold":3,"price":10000}`)
+
+	runCases(t, s, []invokeCase{
+		{name: "no movie", fn: "srch", arg: `{"showdate":"` + date0 + `"}`, code: codeInvalidInput, role: "guest"},
+	})
+
+	tests := []struct {
+		name     string
+		arg      string
+		expected string // theatre/screen/showcode/remaining of the shows in the order of the start time
+	}{
+		{"all the dates", `{"moviename":"Lucy"}`, "T1/SC1/1/4 T2/SC2/1/4 T2/SC1/1/1 T1/SC1/2/4 T1/SC1/1/4"},
+		{"a day", `{"moviename":"Lucy","showdate":"` + date1 + `"}`, "T1/SC1/1/4"},
+		{"a time window", `{"moviename":"Lucy","from":1709287200,"to":1709323200}`, "T1/SC1/1/4 T2/SC2/1/4 T2/SC1/1/1"},
+		{"another movie", `{"moviename":"Tenet"}`, "T2/SC1/2/4"},
+		{"unknown movie", `{"moviename":"Dune"}`, ""},
+	}
+	for _, tt := range tests {
+		shows := []ShowAvailability{}
+		s.result("srch", tt.arg, &shows)
+		found := []string{}
+		for _, show := range shows {
+			found = append(found, show.TheatreID+"/"+show.Screen+"/"+show.ShowCode+"/"+strconv.Itoa(show.Remaining))
+		}
+		if strings.Join(found, " ") != tt.expected {
+			t.Errorf("%s: shows %q, expected %q", tt.name, strings.Join(found, " "), tt.expected)
+		}
+	}
+
+	// Shows of the deactivated screens are not available
+	s.mustInvoke("dact", `{"thid":"T2","screen":"SC2"}`)
+	shows := []ShowAvailability{}
+	s.result("srch", `{"moviename":"Lucy","showdate":"`+date0+`"}`, &shows)
+	for _, show := range shows {
+		if show.TheatreID == "T2" && show.Screen == "SC2" {
+			t.Errorf("Show of the deactivated screen is found :%+v", show)
+		}
+	}
+}